@@ -0,0 +1,456 @@
+// Package backup saves the configuration of a project into a directory of json
+// files, and restores it into the same or another project, possibly in another
+// region.
+//
+// A backup directory holds a backup.json describing the backup, a directory per
+// kind of resource with one file per resource, and a single file for the
+// machine group bindings, the project policy and the project tags:
+//
+//	backup.json
+//	logstores/access-log.json
+//	indexes/access-log.json
+//	configs/nginx-access.json
+//	machinegroups/web.json
+//	bindings.json
+//	policy.json
+//	tags.json
+//	...
+//
+// Restoring into another project rewrites the project and the renamed
+// resources referenced by the restored resources:
+//
+//	meta, err := backup.Backup(ctx, client, "my-project", "./my-project", backup.Options{})
+//	err = backup.Restore(ctx, otherRegionClient, "./my-project", backup.RestoreOptions{
+//		Project: "my-project-copy",
+//		Renames: map[string]string{"access-log": "access-log-copy"},
+//	})
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+)
+
+// FormatVersion is the version of the backup layout written by Backup, Restore
+// refuses backups written with a newer version.
+const FormatVersion = 1
+
+const (
+	metadataFile = "backup.json"
+	fileExt      = ".json"
+)
+
+// Metadata describes a backup, it is written in backup.json once every
+// resource is saved, so a directory without it holds an incomplete backup.
+type Metadata struct {
+	FormatVersion int    `json:"formatVersion"`
+	Project       string `json:"project"`
+	Description   string `json:"description"`
+	Region        string `json:"region"`
+	Kinds         []Kind `json:"kinds"`
+	CreateTime    int64  `json:"createTime"`
+}
+
+// Options selects what is saved by Backup.
+type Options struct {
+	// Kinds to save, all kinds if empty
+	Kinds []Kind
+}
+
+// RestoreOptions selects what is restored by Restore and where.
+type RestoreOptions struct {
+	// Project to restore into, the project of the backup if empty.
+	// It is created if it does not exist.
+	Project string
+	// Renames maps the names of the saved resources to the names they are
+	// restored with, references to renamed resources are rewritten as well.
+	Renames map[string]string
+	// Kinds to restore, all kinds of the backup if empty
+	Kinds []Kind
+	// Overwrite updates the resources that already exist, they are left
+	// untouched otherwise.
+	Overwrite bool
+}
+
+// Backup saves the configuration of project into dir, which is created if needed,
+// the saved kinds of a previous backup in dir are replaced.
+func Backup(ctx context.Context, client sls.ClientInterface, project, dir string, opts Options) (*Metadata, error) {
+	client = sls.BindContext(client, ctx)
+	p, err := client.GetProject(project)
+	if err != nil {
+		return nil, err
+	}
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = AllKinds
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// machine group bindings are saved along with the groups when both are requested
+	var groups []string
+	for _, h := range handlers {
+		if !hasKind(kinds, h.kind) {
+			continue
+		}
+		names, err := backupKind(ctx, client, project, dir, h)
+		if err != nil {
+			return nil, fmt.Errorf("backup %s: %w", h.kind, err)
+		}
+		if h.kind == KindMachineGroup {
+			groups = names
+		}
+	}
+	if hasKind(kinds, KindBinding) {
+		if groups == nil {
+			if groups, err = sls.NewMachineGroupPager(client, project, listPageSize).All(ctx); err != nil {
+				return nil, fmt.Errorf("backup %s: %w", KindBinding, err)
+			}
+		}
+		bindings := make(map[string][]string, len(groups))
+		for _, group := range groups {
+			configs, err := client.GetAppliedConfigs(project, group)
+			if err != nil {
+				return nil, fmt.Errorf("backup %s of %s: %w", KindBinding, group, err)
+			}
+			if len(configs) > 0 {
+				bindings[group] = configs
+			}
+		}
+		if err := writeJSON(filepath.Join(dir, string(KindBinding)+fileExt), bindings); err != nil {
+			return nil, err
+		}
+	}
+	if hasKind(kinds, KindPolicy) {
+		policy, err := client.GetProjectPolicy(project)
+		if err != nil {
+			return nil, fmt.Errorf("backup %s: %w", KindPolicy, err)
+		}
+		policyFile := filepath.Join(dir, string(KindPolicy)+fileExt)
+		if policy != "" {
+			if err := writeRaw(policyFile, []byte(policy)); err != nil {
+				return nil, err
+			}
+		} else if err := os.Remove(policyFile); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	if hasKind(kinds, KindTag) {
+		tags, err := sls.NewTagResourcePager(client, project, "project", []string{project}, nil).All(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("backup %s: %w", KindTag, err)
+		}
+		resourceTags := make([]sls.ResourceTag, 0, len(tags))
+		for _, tag := range tags {
+			resourceTags = append(resourceTags, sls.ResourceTag{Key: tag.TagKey, Value: tag.TagValue})
+		}
+		if err := writeJSON(filepath.Join(dir, string(KindTag)+fileExt), resourceTags); err != nil {
+			return nil, err
+		}
+	}
+
+	meta := &Metadata{
+		FormatVersion: FormatVersion,
+		Project:       project,
+		Description:   p.Description,
+		Region:        p.Region,
+		Kinds:         kinds,
+		CreateTime:    time.Now().Unix(),
+	}
+	if err := writeJSON(filepath.Join(dir, metadataFile), meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// backupKind saves the resources of a kind and returns their names, they are
+// written into a temporary directory which then replaces the directory of the
+// kind, so resources deleted since a previous backup into dir are not kept
+func backupKind(ctx context.Context, client sls.ClientInterface, project, dir string, h *handler) ([]string, error) {
+	names, err := h.list(ctx, client, project)
+	if err != nil {
+		return nil, err
+	}
+	kindDir := filepath.Join(dir, string(h.kind))
+	tmpDir := kindDir + ".tmp"
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	saved := make([]string, 0, len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		doc, err := h.get(client, project, name)
+		if err != nil {
+			return nil, fmt.Errorf("get %s: %w", name, err)
+		}
+		if doc == nil {
+			continue
+		}
+		if err := writeRaw(filepath.Join(tmpDir, url.PathEscape(name)+fileExt), doc); err != nil {
+			return nil, err
+		}
+		saved = append(saved, name)
+	}
+	if err := os.RemoveAll(kindDir); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpDir, kindDir); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// ReadMetadata reads the metadata of the backup in dir.
+func ReadMetadata(dir string) (*Metadata, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, metadataFile))
+	if err != nil {
+		return nil, err
+	}
+	meta := &Metadata{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", metadataFile, err)
+	}
+	if meta.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported backup format version %d", meta.FormatVersion)
+	}
+	return meta, nil
+}
+
+// Restore restores the backup in dir, see RestoreOptions.
+func Restore(ctx context.Context, client sls.ClientInterface, dir string, opts RestoreOptions) error {
+	client = sls.BindContext(client, ctx)
+	meta, err := ReadMetadata(dir)
+	if err != nil {
+		return err
+	}
+	r := &restorer{
+		client:  client,
+		dir:     dir,
+		opts:    opts,
+		source:  meta.Project,
+		project: opts.Project,
+	}
+	if r.project == "" {
+		r.project = meta.Project
+	}
+	if _, err := client.GetProject(r.project); errors.Is(err, sls.ErrNotFound) {
+		if _, err := client.CreateProject(r.project, meta.Description); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = meta.Kinds
+	}
+	for _, kind := range AllKinds {
+		if !hasKind(kinds, kind) || !hasKind(meta.Kinds, kind) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := r.restoreKind(ctx, kind); err != nil {
+			return fmt.Errorf("restore %s: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+type restorer struct {
+	client  sls.ClientInterface
+	dir     string
+	opts    RestoreOptions
+	source  string // project of the backup
+	project string // project restored into
+}
+
+func (r *restorer) restoreKind(ctx context.Context, kind Kind) error {
+	switch kind {
+	case KindBinding:
+		var bindings map[string][]string
+		if err := r.readJSON(string(kind)+fileExt, &bindings); err != nil {
+			return err
+		}
+		for group, configs := range bindings {
+			for _, config := range configs {
+				if err := r.client.ApplyConfigToMachineGroup(r.project, r.rename(config), r.rename(group)); err != nil {
+					return fmt.Errorf("apply %s to %s: %w", config, group, err)
+				}
+			}
+		}
+		return nil
+	case KindPolicy:
+		policy, err := ioutil.ReadFile(filepath.Join(r.dir, string(kind)+fileExt))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return r.client.UpdateProjectPolicy(r.project, string(policy))
+	case KindTag:
+		var tags []sls.ResourceTag
+		if err := r.readJSON(string(kind)+fileExt, &tags); err != nil || len(tags) == 0 {
+			return err
+		}
+		return r.client.TagResources(r.project, sls.NewProjectTags(r.project, tags))
+	}
+
+	h := handlerOf(kind)
+	files, err := ioutil.ReadDir(filepath.Join(r.dir, string(kind)))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileExt) {
+			continue
+		}
+		name, err := url.PathUnescape(strings.TrimSuffix(file.Name(), fileExt))
+		if err != nil {
+			return fmt.Errorf("invalid file name %s: %w", file.Name(), err)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(r.dir, string(kind), file.Name()))
+		if err != nil {
+			return err
+		}
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parse %s: %w", file.Name(), err)
+		}
+		data, err = json.Marshal(r.rewrite(doc, h))
+		if err != nil {
+			return err
+		}
+		name = r.rename(name)
+		err = h.create(r.client, r.project, name, data)
+		if errors.Is(err, sls.ErrAlreadyExists) {
+			if !r.opts.Overwrite {
+				continue
+			}
+			err = h.update(r.client, r.project, name, data)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// rewrite renames the references to renamed resources and to the source project in doc
+func (r *restorer) rewrite(doc interface{}, h *handler) interface{} {
+	for _, path := range h.names {
+		doc = rewritePath(doc, strings.Split(path, "."), r.rename)
+	}
+	for _, path := range h.projects {
+		doc = rewritePath(doc, strings.Split(path, "."), func(project string) string {
+			if project == r.source {
+				return r.project
+			}
+			return project
+		})
+	}
+	return doc
+}
+
+// rewritePath replaces the strings at path in doc with their rewritten value
+func rewritePath(doc interface{}, path []string, rewrite func(string) string) interface{} {
+	if len(path) == 0 {
+		if s, ok := doc.(string); ok {
+			return rewrite(s)
+		}
+		return doc
+	}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if value, ok := v[path[0]]; ok {
+			v[path[0]] = rewritePath(value, path[1:], rewrite)
+		}
+	case []interface{}:
+		if path[0] == "*" {
+			for i, value := range v {
+				v[i] = rewritePath(value, path[1:], rewrite)
+			}
+		}
+	}
+	return doc
+}
+
+func (r *restorer) rename(name string) string {
+	if renamed, ok := r.opts.Renames[name]; ok {
+		return renamed
+	}
+	return name
+}
+
+func (r *restorer) readJSON(file string, v interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(r.dir, file))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", file, err)
+	}
+	return nil
+}
+
+func handlerOf(kind Kind) *handler {
+	for _, h := range handlers {
+		if h.kind == kind {
+			return h
+		}
+	}
+	return nil
+}
+
+func hasKind(kinds []Kind, kind Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeRaw(path, data)
+}
+
+// writeRaw writes the json in data indented, so that backups diff well
+func writeRaw(path string, data []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
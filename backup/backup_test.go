@@ -0,0 +1,142 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/aliyun/aliyun-log-go-sdk/slstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	server := slstest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	ctx := context.Background()
+	_, err := client.CreateProject("my-project", "access logs")
+	require.NoError(t, err)
+	require.NoError(t, client.CreateLogStore("my-project", "access-log", 30, 2, false, 0))
+	require.NoError(t, client.CreateLogStore("my-project", "error-log", 7, 1, false, 0))
+	require.NoError(t, client.CreateIndex("my-project", "access-log", sls.Index{
+		Line: &sls.IndexLine{Token: []string{",", " "}},
+	}))
+
+	dir := t.TempDir()
+	kinds := []Kind{KindLogStore, KindIndex}
+	meta, err := Backup(ctx, client, "my-project", dir, Options{Kinds: kinds})
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, meta.FormatVersion)
+	assert.Equal(t, "access logs", meta.Description)
+	assert.FileExists(t, filepath.Join(dir, "logstores", "error-log.json"))
+	assert.FileExists(t, filepath.Join(dir, "indexes", "access-log.json"))
+	_, err = os.Stat(filepath.Join(dir, "indexes", "error-log.json"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, Restore(ctx, client, dir, RestoreOptions{
+		Project: "my-project-copy",
+		Renames: map[string]string{"access-log": "access-log-copy"},
+	}))
+	project, err := client.GetProject("my-project-copy")
+	require.NoError(t, err)
+	assert.Equal(t, "access logs", project.Description)
+	logstores, err := client.ListLogStore("my-project-copy")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"access-log-copy", "error-log"}, logstores)
+	logstore, err := client.GetLogStore("my-project-copy", "access-log-copy")
+	require.NoError(t, err)
+	assert.Equal(t, 30, logstore.TTL)
+	assert.Equal(t, 2, logstore.ShardCount)
+	index, err := client.GetIndex("my-project-copy", "access-log-copy")
+	require.NoError(t, err)
+	assert.Equal(t, []string{",", " "}, index.Line.Token)
+
+	// existing resources are kept unless overwritten
+	require.NoError(t, client.UpdateLogStore("my-project", "error-log", 14, 1))
+	_, err = Backup(ctx, client, "my-project", dir, Options{Kinds: kinds})
+	require.NoError(t, err)
+	require.NoError(t, Restore(ctx, client, dir, RestoreOptions{Project: "my-project-copy", Kinds: []Kind{KindLogStore}}))
+	logstore, err = client.GetLogStore("my-project-copy", "error-log")
+	require.NoError(t, err)
+	assert.Equal(t, 7, logstore.TTL)
+	require.NoError(t, Restore(ctx, client, dir, RestoreOptions{Project: "my-project-copy", Kinds: []Kind{KindLogStore}, Overwrite: true}))
+	logstore, err = client.GetLogStore("my-project-copy", "error-log")
+	require.NoError(t, err)
+	assert.Equal(t, 14, logstore.TTL)
+}
+
+func TestBackupTwiceAfterDelete(t *testing.T) {
+	server := slstest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	ctx := context.Background()
+	_, err := client.CreateProject("my-project", "")
+	require.NoError(t, err)
+	require.NoError(t, client.CreateLogStore("my-project", "access-log", 30, 1, false, 0))
+	require.NoError(t, client.CreateLogStore("my-project", "error-log", 7, 1, false, 0))
+
+	dir := t.TempDir()
+	opts := Options{Kinds: []Kind{KindLogStore}}
+	_, err = Backup(ctx, client, "my-project", dir, opts)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "logstores", "error-log.json"))
+
+	require.NoError(t, client.DeleteLogStore("my-project", "error-log"))
+	_, err = Backup(ctx, client, "my-project", dir, opts)
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "logstores", "error-log.json"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "logstores.tmp"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, Restore(ctx, client, dir, RestoreOptions{Project: "my-project-copy"}))
+	logstores, err := client.ListLogStore("my-project-copy")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"access-log"}, logstores)
+}
+
+func TestRestoreRewrite(t *testing.T) {
+	r := &restorer{
+		opts:    RestoreOptions{Renames: map[string]string{"access-log": "access-log-copy"}},
+		source:  "my-project",
+		project: "my-project-copy",
+	}
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"name": "access-log",
+		"configuration": {
+			"logstore": "access-log",
+			"parameters": {"name": "access-log", "project": "my-project"},
+			"sinks": [{"name": "access-log", "project": "my-project", "logstore": "access-log"}]
+		}
+	}`), &doc))
+	data, err := json.Marshal(r.rewrite(doc, handlerOf(KindETL)))
+	require.NoError(t, err)
+	// only the known references are rewritten, not every field named name or project
+	assert.JSONEq(t, `{
+		"name": "access-log-copy",
+		"configuration": {
+			"logstore": "access-log-copy",
+			"parameters": {"name": "access-log", "project": "my-project"},
+			"sinks": [{"name": "access-log", "project": "my-project-copy", "logstore": "access-log-copy"}]
+		}
+	}`, string(data))
+}
+
+func TestBackupContext(t *testing.T) {
+	server := slstest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	_, err := client.CreateProject("my-project", "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Backup(ctx, client, "my-project", t.TempDir(), Options{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "context canceled")
+}
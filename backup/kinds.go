@@ -0,0 +1,282 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+)
+
+// Kind is a kind of resource in a backup, resources of a kind are saved in the
+// directory named after it, one json file per resource.
+type Kind string
+
+const (
+	KindLogStore     Kind = "logstores"
+	KindIndex        Kind = "indexes" // files are named after their logstore
+	KindMachineGroup Kind = "machinegroups"
+	KindConfig       Kind = "configs"
+	KindDashboard    Kind = "dashboards"
+	KindSavedSearch  Kind = "savedsearches"
+	KindAlert        Kind = "alerts"
+	KindScheduledSQL Kind = "scheduledsqls"
+	KindExport       Kind = "exports"
+	KindIngestion    Kind = "ingestions"
+	KindETL          Kind = "etls"
+	// KindBinding, KindPolicy and KindTag are saved in a single file named after the kind
+	KindBinding Kind = "bindings"
+	KindPolicy  Kind = "policy"
+	KindTag     Kind = "tags"
+)
+
+// AllKinds lists every kind in the order they are restored.
+var AllKinds = []Kind{
+	KindLogStore, KindIndex, KindMachineGroup, KindConfig, KindBinding, KindDashboard, KindSavedSearch,
+	KindAlert, KindScheduledSQL, KindExport, KindIngestion, KindETL, KindPolicy, KindTag,
+}
+
+const listPageSize = 500
+
+// handler saves and restores the resources of a kind, which are saved one file per resource
+type handler struct {
+	kind Kind
+	list func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error)
+	// get returns the resource marshalled as json, nil if it does not exist
+	get    func(c sls.ClientInterface, project, name string) ([]byte, error)
+	create func(c sls.ClientInterface, project, name string, doc []byte) error
+	update func(c sls.ClientInterface, project, name string, doc []byte) error
+	// names are the paths of the fields holding the name of a resource of the project,
+	// and projects those holding the name of a project, "*" matches every element of an array
+	names    []string
+	projects []string
+}
+
+var handlers = []*handler{
+	{
+		kind:  KindLogStore,
+		names: []string{"logstoreName"},
+		list:  listLogStores,
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return marshal(c.GetLogStore(project, name))
+		},
+		create: typed(func(c sls.ClientInterface, project string, logstore *sls.LogStore) error {
+			return c.CreateLogStoreV2(project, logstore)
+		}),
+		update: typed(func(c sls.ClientInterface, project string, logstore *sls.LogStore) error {
+			return c.UpdateLogStoreV2(project, logstore)
+		}),
+	},
+	{
+		kind: KindIndex,
+		list: listLogStores,
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return raw(c.GetIndexString(project, name))
+		},
+		create: func(c sls.ClientInterface, project, name string, doc []byte) error {
+			return c.CreateIndexString(project, name, string(doc))
+		},
+		update: func(c sls.ClientInterface, project, name string, doc []byte) error {
+			return c.UpdateIndexString(project, name, string(doc))
+		},
+	},
+	{
+		kind:  KindMachineGroup,
+		names: []string{"groupName"},
+		list: func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error) {
+			return sls.NewMachineGroupPager(c, project, listPageSize).All(ctx)
+		},
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return marshal(c.GetMachineGroup(project, name))
+		},
+		create: typed(func(c sls.ClientInterface, project string, group *sls.MachineGroup) error {
+			return c.CreateMachineGroup(project, group)
+		}),
+		update: typed(func(c sls.ClientInterface, project string, group *sls.MachineGroup) error {
+			return c.UpdateMachineGroup(project, group)
+		}),
+	},
+	{
+		kind:     KindConfig,
+		names:    []string{"configName", "outputDetail.logstoreName"},
+		projects: []string{"outputDetail.projectName"},
+		list: func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error) {
+			return sls.NewConfigPager(c, project, listPageSize).All(ctx)
+		},
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return raw(c.GetConfigString(project, name))
+		},
+		create: func(c sls.ClientInterface, project, name string, doc []byte) error {
+			return c.CreateConfigString(project, string(doc))
+		},
+		update: func(c sls.ClientInterface, project, name string, doc []byte) error {
+			return c.UpdateConfigString(project, name, string(doc))
+		},
+	},
+	{
+		kind:  KindDashboard,
+		names: []string{"dashboardName", "charts.*.search.logstore"},
+		list: func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error) {
+			return sls.NewDashboardPager(c, project, "", listPageSize).All(ctx)
+		},
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return raw(c.GetDashboardString(project, name))
+		},
+		create: func(c sls.ClientInterface, project, name string, doc []byte) error {
+			return c.CreateDashboardString(project, string(doc))
+		},
+		update: func(c sls.ClientInterface, project, name string, doc []byte) error {
+			return c.UpdateDashboardString(project, name, string(doc))
+		},
+	},
+	{
+		kind:  KindSavedSearch,
+		names: []string{"savedsearchName", "logstore"},
+		list: func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error) {
+			return sls.NewSavedSearchPager(c, project, "", listPageSize).All(ctx)
+		},
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return marshal(c.GetSavedSearch(project, name))
+		},
+		create: typed(func(c sls.ClientInterface, project string, savedSearch *sls.SavedSearch) error {
+			return c.CreateSavedSearch(project, savedSearch)
+		}),
+		update: typed(func(c sls.ClientInterface, project string, savedSearch *sls.SavedSearch) error {
+			return c.UpdateSavedSearch(project, savedSearch)
+		}),
+	},
+	{
+		kind: KindAlert,
+		names: []string{"name", "configuration.dashboard", "configuration.queryList.*.logStore",
+			"configuration.queryList.*.store", "configuration.sinkEventStore.eventStore"},
+		projects: []string{"configuration.queryList.*.project", "configuration.sinkEventStore.project"},
+		list: func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error) {
+			alerts, err := sls.NewAlertPager(c, project, "", "", listPageSize).All(ctx)
+			return namesOf(alerts, func(a *sls.Alert) string { return a.Name }), err
+		},
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return raw(c.GetAlertString(project, name))
+		},
+		create: func(c sls.ClientInterface, project, name string, doc []byte) error {
+			return c.CreateAlertString(project, string(doc))
+		},
+		update: func(c sls.ClientInterface, project, name string, doc []byte) error {
+			return c.UpdateAlertString(project, name, string(doc))
+		},
+	},
+	{
+		kind:     KindScheduledSQL,
+		names:    []string{"name", "configuration.sourceLogstore", "configuration.destLogstore"},
+		projects: []string{"configuration.destProject"},
+		list: func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error) {
+			jobs, err := sls.NewScheduledSQLPager(c, project, "", "", listPageSize).All(ctx)
+			return namesOf(jobs, func(j *sls.ScheduledSQL) string { return j.Name }), err
+		},
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return marshal(c.GetScheduledSQL(project, name))
+		},
+		create: typed(func(c sls.ClientInterface, project string, job *sls.ScheduledSQL) error {
+			return c.CreateScheduledSQL(project, job)
+		}),
+		update: typed(func(c sls.ClientInterface, project string, job *sls.ScheduledSQL) error {
+			return c.UpdateScheduledSQL(project, job)
+		}),
+	},
+	{
+		kind:  KindExport,
+		names: []string{"name", "configuration.logstore"},
+		list: func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error) {
+			exports, err := sls.NewExportPager(c, project, "", "", "", listPageSize).All(ctx)
+			return namesOf(exports, func(e *sls.Export) string { return e.Name }), err
+		},
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return marshal(c.GetExport(project, name))
+		},
+		create: typed(func(c sls.ClientInterface, project string, export *sls.Export) error {
+			return c.CreateExport(project, export)
+		}),
+		update: typed(func(c sls.ClientInterface, project string, export *sls.Export) error {
+			return c.UpdateExport(project, export)
+		}),
+	},
+	{
+		kind:  KindIngestion,
+		names: []string{"name", "configuration.logstore"},
+		list: func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error) {
+			ingestions, err := sls.NewIngestionPager(c, project, "", "", "", listPageSize).All(ctx)
+			return namesOf(ingestions, func(i *sls.Ingestion) string { return i.Name }), err
+		},
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return marshal(c.GetIngestion(project, name))
+		},
+		create: typed(func(c sls.ClientInterface, project string, ingestion *sls.Ingestion) error {
+			return c.CreateIngestion(project, ingestion)
+		}),
+		update: typed(func(c sls.ClientInterface, project string, ingestion *sls.Ingestion) error {
+			return c.UpdateIngestion(project, ingestion)
+		}),
+	},
+	{
+		kind:     KindETL,
+		names:    []string{"name", "configuration.logstore", "configuration.sinks.*.logstore"},
+		projects: []string{"configuration.sinks.*.project"},
+		list: func(ctx context.Context, c sls.ClientInterface, project string) ([]string, error) {
+			etls, err := sls.NewETLPager(c, project, listPageSize).All(ctx)
+			return namesOf(etls, func(e *sls.ETL) string { return e.Name }), err
+		},
+		get: func(c sls.ClientInterface, project, name string) ([]byte, error) {
+			return marshal(c.GetETL(project, name))
+		},
+		create: typed(func(c sls.ClientInterface, project string, etl *sls.ETL) error {
+			return c.CreateETL(project, *etl)
+		}),
+		update: typed(func(c sls.ClientInterface, project string, etl *sls.ETL) error {
+			return c.UpdateETL(project, *etl)
+		}),
+	},
+}
+
+func listLogStores(_ context.Context, c sls.ClientInterface, project string) ([]string, error) {
+	return c.ListLogStore(project)
+}
+
+// marshal returns v as json, nil if it does not exist
+func marshal[T any](v *T, err error) ([]byte, error) {
+	if errors.Is(err, sls.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// raw returns the json returned by a Get*String API, nil if it does not exist
+func raw(doc string, err error) ([]byte, error) {
+	if errors.Is(err, sls.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(doc), nil
+}
+
+// typed adapts a create or update API taking a model to json documents
+func typed[T any](fn func(c sls.ClientInterface, project string, v *T) error) func(c sls.ClientInterface, project, name string, doc []byte) error {
+	return func(c sls.ClientInterface, project, _ string, doc []byte) error {
+		v := new(T)
+		if err := json.Unmarshal(doc, v); err != nil {
+			return err
+		}
+		return fn(c, project, v)
+	}
+}
+
+func namesOf[T any](items []T, name func(T) string) []string {
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, name(item))
+	}
+	return names
+}
@@ -1,6 +1,7 @@
 package sls
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -56,6 +57,11 @@ type Error struct {
 	Code      string `json:"errorCode"`
 	Message   string `json:"errorMessage"`
 	RequestID string `json:"requestID"`
+
+	// retryAfter is parsed from the Retry-After response header, see RetryAfter
+	retryAfter time.Duration
+	// cause is the error wrapped by NewClientError
+	cause error
 }
 
 func IsDebugLevelMatched(level int) bool {
@@ -74,9 +80,22 @@ func NewClientError(err error) *Error {
 	clientError.HTTPCode = -1
 	clientError.Code = "ClientError"
 	clientError.Message = err.Error()
+	clientError.cause = err
 	return clientError
 }
 
+// WrapError returns an *Error whose Message is err.Error() and whose Unwrap returns err,
+// unlike NewClientError, HTTPCode and Code are left empty. err is returned as it is if it is an *Error.
+func WrapError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	if slsError, ok := err.(*Error); ok {
+		return slsError
+	}
+	return &Error{Message: err.Error(), cause: err}
+}
+
 func (e Error) String() string {
 	b, err := json.MarshalIndent(e, "", "    ")
 	if err != nil {
@@ -89,6 +108,16 @@ func (e Error) Error() string {
 	return e.String()
 }
 
+// Is reports whether the error code or http status code of e belongs to the category target, eg. ErrNotFound.
+func (e Error) Is(target error) bool {
+	return matchCategory(target, int(e.HTTPCode), e.Code)
+}
+
+// Unwrap returns the error wrapped by NewClientError, if any.
+func (e Error) Unwrap() error {
+	return e.cause
+}
+
 func IsTokenError(err error) bool {
 	if clientErr, ok := err.(*Error); ok {
 		if clientErr.HTTPCode == 401 {
@@ -118,6 +147,11 @@ type Client struct {
 	// be ignored
 	CommonHeaders map[string]string
 	InnerHeaders  map[string]string
+
+	// ctx is bound by WithContext, nil means context.Background()
+	ctx          context.Context
+	interceptors []Interceptor
+	retryPolicy  RetryPolicy
 }
 
 // repeated calls only create one http client
@@ -133,7 +167,21 @@ func (c *Client) initHttpClient() {
 	}
 }
 
+// ensureHttpClient calls initHttpClient under the write lock if needed,
+// so that concurrent first calls on a client do not race.
+func (c *Client) ensureHttpClient() {
+	c.accessKeyLock.RLock()
+	ready := c.RequestTimeOut != 0 && c.RetryTimeOut != 0 && c.HTTPClient != nil
+	c.accessKeyLock.RUnlock()
+	if !ready {
+		c.accessKeyLock.Lock()
+		c.initHttpClient()
+		c.accessKeyLock.Unlock()
+	}
+}
+
 func convert(c *Client, projName string) *LogProject {
+	c.ensureHttpClient()
 	c.accessKeyLock.RLock()
 	defer c.accessKeyLock.RUnlock()
 	return convertLocked(c, projName)
@@ -156,9 +204,54 @@ func convertLocked(c *Client, projName string) *LogProject {
 	p.innerHeaders = c.InnerHeaders
 	p.httpClient = c.HTTPClient
 	p.retryTimeout = c.RetryTimeOut
+	p.ctx = c.ctx
+	p.interceptors = c.interceptors
+	p.retryPolicy = c.retryPolicy
 	return p
 }
 
+// WithContext returns a copy of the client whose API calls are bound to ctx.
+// Cancelling ctx or reaching its deadline aborts the in-flight http request
+// and stops further retries, the retry timeout still applies.
+//
+// The returned client shares the http client and credentials provider with c,
+// but later changes made by the Set* methods of c are not reflected on it.
+func (c *Client) WithContext(ctx context.Context) ClientInterfaceWithContext {
+	if ctx == nil {
+		panic("nil context")
+	}
+	// create the http client before copying, so that the copies share it
+	c.ensureHttpClient()
+	c.accessKeyLock.RLock()
+	defer c.accessKeyLock.RUnlock()
+	return &Client{
+		Endpoint:            c.Endpoint,
+		AccessKeyID:         c.AccessKeyID,
+		AccessKeySecret:     c.AccessKeySecret,
+		SecurityToken:       c.SecurityToken,
+		UserAgent:           c.UserAgent,
+		RequestTimeOut:      c.RequestTimeOut,
+		RetryTimeOut:        c.RetryTimeOut,
+		HTTPClient:          c.HTTPClient,
+		Region:              c.Region,
+		AuthVersion:         c.AuthVersion,
+		credentialsProvider: c.credentialsProvider,
+		CommonHeaders:       c.CommonHeaders,
+		InnerHeaders:        c.InnerHeaders,
+		ctx:                 ctx,
+		interceptors:        c.interceptors,
+		retryPolicy:         c.retryPolicy,
+	}
+}
+
+// Context returns the context bound by WithContext, or context.Background().
+func (c *Client) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
 // Set credentialsProvider for client and returns the same client.
 func (c *Client) WithCredentialsProvider(provider CredentialsProvider) *Client {
 	c.credentialsProvider = provider
@@ -175,11 +268,32 @@ func (c *Client) SetHTTPClient(client *http.Client) {
 	c.HTTPClient = client
 }
 
+// Use appends interceptors to the client, every request sent by the client,
+// including each retry attempt, passes through them in the order they are added.
+// It should be called before the client is used by other goroutines.
+func (c *Client) Use(interceptors ...Interceptor) {
+	c.accessKeyLock.Lock()
+	// always copy, clients returned by WithContext may share the old slice
+	merged := make([]Interceptor, 0, len(c.interceptors)+len(interceptors))
+	merged = append(merged, c.interceptors...)
+	c.interceptors = append(merged, interceptors...)
+	c.accessKeyLock.Unlock()
+}
+
 // SetRetryTimeout set retry timeout
 func (c *Client) SetRetryTimeout(timeout time.Duration) {
 	c.RetryTimeOut = timeout
 }
 
+// SetRetryPolicy set the policy deciding which failed requests are retried and
+// when, nil restores DefaultRetryPolicy. A policy set by ContextWithRetryPolicy on
+// the context bound by WithContext takes precedence for that call.
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.accessKeyLock.Lock()
+	c.retryPolicy = policy
+	c.accessKeyLock.Unlock()
+}
+
 // SetAuthVersion set signature version that the client used
 func (c *Client) SetAuthVersion(version AuthVersionType) {
 	c.accessKeyLock.Lock()
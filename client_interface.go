@@ -1,6 +1,7 @@
 package sls
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -46,6 +47,24 @@ func CreateNormalInterfaceV2(endpoint string, credentialsProvider CredentialsPro
 	return client
 }
 
+// CreateNormalInterfaceWithContext create a normal client like CreateNormalInterfaceV2,
+// the returned client can be bound to a caller provided context by WithContext.
+//
+//	client := CreateNormalInterfaceWithContext(endpoint, provider)
+//	logstore, err := client.WithContext(ctx).GetLogStore(project, logstore)
+func CreateNormalInterfaceWithContext(endpoint string, credentialsProvider CredentialsProvider) ClientInterfaceWithContext {
+	return CreateNormalInterfaceV2(endpoint, credentialsProvider).(*Client)
+}
+
+// BindContext returns client bound to ctx if it implements ClientInterfaceWithContext,
+// otherwise client itself.
+func BindContext(client ClientInterface, ctx context.Context) ClientInterface {
+	if c, ok := client.(ClientInterfaceWithContext); ok {
+		return c.WithContext(ctx)
+	}
+	return client
+}
+
 type UpdateTokenFunction = util.UpdateTokenFunction
 
 // CreateTokenAutoUpdateClient create a TokenAutoUpdateClient,
@@ -64,8 +83,10 @@ func CreateTokenAutoUpdateClient(endpoint string, tokenUpdateFunc UpdateTokenFun
 	if err != nil {
 		return nil, err
 	}
+	credentials := newTokenCredentialsProvider(accessKeyID, accessKeySecret, securityToken)
 	tauc := &TokenAutoUpdateClient{
-		logClient:              CreateNormalInterface(endpoint, accessKeyID, accessKeySecret, securityToken),
+		logClient:              CreateNormalInterface(endpoint, accessKeyID, accessKeySecret, securityToken).(*Client).WithCredentialsProvider(credentials),
+		credentials:            credentials,
 		shutdown:               shutdown,
 		tokenUpdateFunc:        tokenUpdateFunc,
 		maxTryTimes:            3,
@@ -472,3 +493,40 @@ type ClientInterface interface {
 	// #################### AlertPub Msg  #####################
 	PublishAlertEvent(project string, alertResult []byte) error
 }
+
+// ClientInterfaceWithContext is a ClientInterface whose API calls can be bound to a
+// caller provided context, which drives cancellation, deadlines and retry abortion.
+type ClientInterfaceWithContext interface {
+	ClientInterface
+	// WithContext returns a client sharing the configuration of the current one,
+	// all API calls of the returned client are bound to ctx.
+	WithContext(ctx context.Context) ClientInterfaceWithContext
+	// Context returns the bound context, context.Background() if not bound.
+	Context() context.Context
+}
+
+// ClientInterfaceWithInterceptors is a ClientInterface whose requests can be
+// wrapped by interceptors, it is implemented by the clients created by this package.
+type ClientInterfaceWithInterceptors interface {
+	ClientInterface
+	// Use appends interceptors, every request sent by the client passes through them
+	Use(interceptors ...Interceptor)
+}
+
+// UseInterceptors appends interceptors to client if it implements ClientInterfaceWithInterceptors,
+// it returns false otherwise.
+func UseInterceptors(client ClientInterface, interceptors ...Interceptor) bool {
+	if c, ok := client.(ClientInterfaceWithInterceptors); ok {
+		c.Use(interceptors...)
+		return true
+	}
+	return false
+}
+
+// ClientInterfaceWithRetryPolicy is a ClientInterface whose RetryPolicy can be set,
+// it is implemented by the clients created by this package.
+type ClientInterfaceWithRetryPolicy interface {
+	ClientInterface
+	// SetRetryPolicy set the policy deciding which failed requests are retried and when
+	SetRetryPolicy(policy RetryPolicy)
+}
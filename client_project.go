@@ -117,7 +117,9 @@ func (c *Client) ListMachines(project, machineGroupName string) (ms []*Machine,
 			}
 			return
 		}
-		err = fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
+		errMsg.HTTPCode = int32(r.StatusCode)
+		errMsg.RequestID = r.Header.Get(RequestIDHeader)
+		err = errMsg
 		return
 	}
 
@@ -159,7 +161,9 @@ func (c *Client) ListMachinesV2(project, machineGroupName string, offset, size i
 			}
 			return
 		}
-		err = fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
+		errMsg.HTTPCode = int32(r.StatusCode)
+		errMsg.RequestID = r.Header.Get(RequestIDHeader)
+		err = errMsg
 		return
 	}
 
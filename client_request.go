@@ -3,6 +3,7 @@ package sls
 // request sends a request to SLS.
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -17,6 +18,26 @@ import (
 // request sends a request to alibaba cloud Log Service.
 // @note if error is nil, you must call http.Response.Body.Close() to finalize reader
 func (c *Client) request(project, method, uri string, headers map[string]string, body []byte) (*http.Response, error) {
+	c.accessKeyLock.RLock()
+	interceptors := c.interceptors
+	c.accessKeyLock.RUnlock()
+	if len(interceptors) == 0 {
+		return c.doRequest(c.Context(), project, method, uri, headers, body)
+	}
+	rt := chainInterceptors(interceptors, func(req *RoundTripRequest) (*http.Response, error) {
+		return c.doRequest(req.Context, req.Project, req.Method, req.URI, req.Headers, req.Body)
+	})
+	return rt(&RoundTripRequest{
+		Context: c.Context(),
+		Project: project,
+		Method:  method,
+		URI:     uri,
+		Headers: headers,
+		Body:    body,
+	})
+}
+
+func (c *Client) doRequest(ctx context.Context, project, method, uri string, headers map[string]string, body []byte) (*http.Response, error) {
 	// The caller should provide 'x-log-bodyrawsize' header
 	if _, ok := headers[HTTPHeaderBodyRawSize]; !ok {
 		return nil, fmt.Errorf("Can't find 'x-log-bodyrawsize' header")
@@ -105,7 +126,7 @@ func (c *Client) request(project, method, uri string, headers map[string]string,
 		urlStr = "http://"
 	}
 	urlStr += hostStr + uri
-	req, err := http.NewRequest(method, urlStr, reader)
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, reader)
 	if err != nil {
 		return nil, err
 	}
@@ -15,6 +15,7 @@ import (
 )
 
 func convertLogstore(c *Client, project, logstore string) *LogStore {
+	c.ensureHttpClient()
 	c.accessKeyLock.RLock()
 	proj := convertLocked(c, project)
 	c.accessKeyLock.RUnlock()
@@ -397,7 +398,9 @@ func (c *Client) ListSubStore(project, logstore string) (sortedSubStores []strin
 			}
 			return
 		}
-		err = fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
+		errMsg.HTTPCode = int32(r.StatusCode)
+		errMsg.RequestID = r.Header.Get(RequestIDHeader)
+		err = errMsg
 		return
 	}
 
@@ -443,7 +446,9 @@ func (c *Client) GetSubStore(project, logstore, name string) (sortedSubStore *Su
 			}
 			return
 		}
-		err = fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
+		errMsg.HTTPCode = int32(r.StatusCode)
+		errMsg.RequestID = r.Header.Get(RequestIDHeader)
+		err = errMsg
 		return
 	}
 	sortedSubStore = &SubStore{}
@@ -555,7 +560,9 @@ func (c *Client) GetSubStoreTTL(project, logstore string) (ttl int, err error) {
 			}
 			return
 		}
-		err = fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
+		errMsg.HTTPCode = int32(r.StatusCode)
+		errMsg.RequestID = r.Header.Get(RequestIDHeader)
+		err = errMsg
 		return
 	}
 
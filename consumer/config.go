@@ -53,6 +53,7 @@ type LogHubConfig struct {
 	//:param Region: region of sls endpoint, eg. cn-hangzhou, region must be set if AuthVersion is sls.AuthV4
 	//:param DisableRuntimeMetrics: disable runtime metrics, runtime metrics prints to local log.
 	//::param MaxIoWorkers: max io workers, default is 50. Smaller io workers will reduce memory usage, but may reduce throughput.
+	//:param Tracer: default nil, optional. If set, spans are created for fetching and processing logs of each shard, and every http request sent by the consumer.
 	Endpoint                  string
 	AccessKeyID               string
 	AccessKeySecret           string
@@ -85,6 +86,7 @@ type LogHubConfig struct {
 	Region                    string
 	DisableRuntimeMetrics     bool
 	MaxIoWorkers              int
+	Tracer                    sls.Tracer
 }
 
 const (
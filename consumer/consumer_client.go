@@ -1,6 +1,8 @@
 package consumerLibrary
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -53,6 +55,9 @@ func initConsumerClient(option LogHubConfig, logger log.Logger) *ConsumerClient
 	if option.Region != "" {
 		client.SetRegion(option.Region)
 	}
+	if option.Tracer != nil {
+		sls.UseInterceptors(client, sls.NewTracingInterceptor(option.Tracer))
+	}
 
 	consumerGroup := sls.ConsumerGroup{
 		ConsumerGroupName: option.ConsumerGroupName,
@@ -93,7 +98,7 @@ func (consumer *ConsumerClient) createConsumerGroup() error {
 		}
 	} else {
 		if err := consumer.client.CreateConsumerGroup(consumer.option.Project, consumer.option.Logstore, consumer.consumerGroup); err != nil {
-			if slsError, ok := err.(*sls.Error); !ok || slsError.Code != "ConsumerGroupAlreadyExist" {
+			if !errors.Is(err, sls.ErrAlreadyExists) {
 				return fmt.Errorf("create consumer group failed: %w", err)
 			}
 		}
@@ -139,8 +144,35 @@ func (consumer *ConsumerClient) getCursor(shardId int, from string) (string, err
 	return cursor, err
 }
 
-func (consumer *ConsumerClient) pullLogs(shardId int, cursor string) (gl *sls.LogGroupList, plm *sls.PullLogMeta, err error) {
-	plr := &sls.PullLogRequest{
+// pullLogs pulls and decodes the log groups of a shard, like the consumers of Processor did before views
+func (consumer *ConsumerClient) pullLogs(ctx context.Context, shardId int, cursor string) (gl *sls.LogGroupList, plm *sls.PullLogMeta, err error) {
+	plr := consumer.pullLogRequest(shardId, cursor)
+	client := consumer.contextClient(ctx)
+	err = consumer.retryPull(shardId, cursor, func() error {
+		gl, plm, err = client.PullLogsWithQuery(plr)
+		return err
+	})
+	return gl, plm, err
+}
+
+// pullLogsView pulls the log groups of a shard as a view, which is checked to be well framed
+func (consumer *ConsumerClient) pullLogsView(ctx context.Context, shardId int, cursor string) (view *sls.LogGroupListView, plm *sls.PullLogMeta, err error) {
+	plr := consumer.pullLogRequest(shardId, cursor)
+	client := consumer.contextClient(ctx)
+	err = consumer.retryPull(shardId, cursor, func() error {
+		var data []byte
+		data, plm, err = client.GetLogsBytesWithQuery(plr)
+		if err != nil {
+			return err
+		}
+		view, err = sls.ParseLogGroupListView(data, plm)
+		return err
+	})
+	return view, plm, err
+}
+
+func (consumer *ConsumerClient) pullLogRequest(shardId int, cursor string) *sls.PullLogRequest {
+	return &sls.PullLogRequest{
 		Project:          consumer.option.Project,
 		Logstore:         consumer.option.Logstore,
 		ShardID:          shardId,
@@ -149,8 +181,21 @@ func (consumer *ConsumerClient) pullLogs(shardId int, cursor string) (gl *sls.Lo
 		LogGroupMaxCount: consumer.option.MaxFetchLogGroupCount,
 		CompressType:     consumer.option.CompressType,
 	}
+}
+
+// contextClient binds the client to ctx only when tracing is enabled, so that
+// the client is not cloned for each pull otherwise
+func (consumer *ConsumerClient) contextClient(ctx context.Context) sls.ClientInterface {
+	if consumer.option.Tracer == nil {
+		return consumer.client
+	}
+	return sls.BindContext(consumer.client, ctx)
+}
+
+// retryPull calls pull at most 3 times until it succeeds and returns its last error
+func (consumer *ConsumerClient) retryPull(shardId int, cursor string, pull func() error) (err error) {
 	for retry := 0; retry < 3; retry++ {
-		gl, plm, err = consumer.client.PullLogsWithQuery(plr)
+		err = pull()
 		if err != nil {
 			slsError, ok := err.(*sls.Error)
 			if ok {
@@ -172,7 +217,7 @@ func (consumer *ConsumerClient) pullLogs(shardId int, cursor string) (gl *sls.Lo
 			}
 			time.Sleep(200 * time.Millisecond)
 		} else {
-			return gl, plm, nil
+			return nil
 		}
 	}
 	// If you can't retry the log three times, it will return to empty list and start pulling the log cursor,
@@ -1,12 +1,15 @@
 package consumerLibrary
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
 	sls "github.com/aliyun/aliyun-log-go-sdk"
 	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func InitOption() LogHubConfig {
@@ -98,3 +101,62 @@ func TestConsumerClient_updateConsumerGroup(t *testing.T) {
 	// clean
 	_ = oldClient.client.DeleteConsumerGroup(oldOption.Project, oldOption.Logstore, oldOption.ConsumerGroupName)
 }
+
+// pullClient returns data for pulls and counts the clients bound to a context
+type pullClient struct {
+	sls.ClientInterface
+	data  []byte
+	binds *int
+	pulls map[string]int
+}
+
+func (c *pullClient) WithContext(ctx context.Context) sls.ClientInterfaceWithContext {
+	*c.binds++
+	return c
+}
+
+func (c *pullClient) Context() context.Context {
+	return context.Background()
+}
+
+func (c *pullClient) GetLogsBytesWithQuery(plr *sls.PullLogRequest) ([]byte, *sls.PullLogMeta, error) {
+	c.pulls["bytes"]++
+	return c.data, &sls.PullLogMeta{NextCursor: "next"}, nil
+}
+
+func (c *pullClient) PullLogsWithQuery(plr *sls.PullLogRequest) (*sls.LogGroupList, *sls.PullLogMeta, error) {
+	c.pulls["decoded"]++
+	gl, err := sls.LogsBytesDecode(c.data)
+	return gl, &sls.PullLogMeta{NextCursor: "next"}, err
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string, attrs ...sls.Attribute) (context.Context, sls.Span) {
+	return ctx, noopSpan{}
+}
+
+func TestConsumerClientPullLogs(t *testing.T) {
+	data, err := proto.Marshal(&sls.LogGroupList{LogGroups: []*sls.LogGroup{{Topic: proto.String("a")}}})
+	require.NoError(t, err)
+	binds := 0
+	client := &pullClient{data: data, binds: &binds, pulls: map[string]int{}}
+	consumer := &ConsumerClient{option: InitOption(), client: client, logger: log.NewNopLogger()}
+
+	view, _, err := consumer.pullLogsView(context.Background(), 0, "cursor")
+	require.NoError(t, err)
+	n, err := view.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	gl, _, err := consumer.pullLogs(context.Background(), 0, "cursor")
+	require.NoError(t, err)
+	assert.Equal(t, "a", gl.LogGroups[0].GetTopic())
+	assert.Equal(t, map[string]int{"bytes": 1, "decoded": 1}, client.pulls)
+	// the client is only bound to the context when tracing is enabled
+	assert.Equal(t, 0, binds)
+
+	consumer.option.Tracer = nopTracer{}
+	_, _, err = consumer.pullLogsView(context.Background(), 0, "cursor")
+	require.NoError(t, err)
+	assert.Equal(t, 1, binds)
+}
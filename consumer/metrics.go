@@ -0,0 +1,116 @@
+package consumerLibrary
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aliyun/aliyun-log-go-sdk/internal"
+)
+
+// ShardStats is the cumulative statistics of a shard consumed by the worker,
+// it is kept after the shard is released to other consumers.
+type ShardStats struct {
+	Shard int
+
+	FetchCount         int64 // pull logs requests, including failed ones
+	FetchFailedCount   int64
+	FetchRawBytes      int64
+	FetchLatencySum    time.Duration
+	ProcessCount       int64 // calls of Processor.Process, including failed ones
+	ProcessFailedCount int64
+	ProcessLatencySum  time.Duration
+}
+
+// ConsumerStats is the cumulative statistics of a consumer worker.
+type ConsumerStats struct {
+	Project           string
+	Logstore          string
+	ConsumerGroupName string
+	ConsumerName      string
+	Shards            []ShardStats // sorted by shard id
+}
+
+// Stats returns the cumulative statistics of the worker, unlike the runtime metrics
+// printed to local log, it is never reset and is suitable for exporting to monitoring systems.
+func (consumerWorker *ConsumerWorker) Stats() ConsumerStats {
+	option := consumerWorker.client.option
+	stats := ConsumerStats{
+		Project:           option.Project,
+		Logstore:          option.Logstore,
+		ConsumerGroupName: option.ConsumerGroupName,
+		ConsumerName:      option.ConsumerName,
+	}
+	consumerWorker.shardMonitors.Range(func(key, value interface{}) bool {
+		total := &value.(*ShardMonitor).total
+		stats.Shards = append(stats.Shards, ShardStats{
+			Shard:              key.(int),
+			FetchCount:         total.fetchLogHistogram.Count.Load(),
+			FetchFailedCount:   total.fetchReqFailedCount.Load(),
+			FetchRawBytes:      total.logRawSize.Load(),
+			FetchLatencySum:    time.Duration(total.fetchLogHistogram.Sum.Load()) * time.Microsecond,
+			ProcessCount:       total.processHistogram.Count.Load(),
+			ProcessFailedCount: total.processFailedCount.Load(),
+			ProcessLatencySum:  time.Duration(total.processHistogram.Sum.Load()) * time.Microsecond,
+		})
+		return true
+	})
+	sort.Slice(stats.Shards, func(i, j int) bool {
+		return stats.Shards[i].Shard < stats.Shards[j].Shard
+	})
+	return stats
+}
+
+// MetricsHandler returns a http.Handler serving Stats in the prometheus text
+// exposition format, metric names are prefixed with "sls_consumer_".
+// Like the producer, it is the only prometheus integration and is scraped directly.
+//
+//	http.Handle("/metrics/consumer", worker.MetricsHandler())
+func (consumerWorker *ConsumerWorker) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		internal.WritePrometheusText(w, consumerWorker.Stats().metricFamilies())
+	})
+}
+
+var shardLabelNames = []string{"project", "logstore", "consumer_group", "consumer", "shard"}
+
+var (
+	fetchLatencyDesc   = &internal.MetricDesc{Name: "sls_consumer_fetch_latency_seconds", Help: "Latency of pulling logs from server.", Type: "summary", LabelNames: shardLabelNames}
+	fetchFailedDesc    = &internal.MetricDesc{Name: "sls_consumer_fetch_failed_total", Help: "Failed pull logs requests.", Type: "counter", LabelNames: shardLabelNames}
+	fetchRawBytesDesc  = &internal.MetricDesc{Name: "sls_consumer_fetch_raw_bytes_total", Help: "Raw size of logs pulled.", Type: "counter", LabelNames: shardLabelNames}
+	processLatencyDesc = &internal.MetricDesc{Name: "sls_consumer_process_latency_seconds", Help: "Latency of processing logs by the processor.", Type: "summary", LabelNames: shardLabelNames}
+	processFailedDesc  = &internal.MetricDesc{Name: "sls_consumer_process_failed_total", Help: "Processor calls returned an error or panicked.", Type: "counter", LabelNames: shardLabelNames}
+
+	consumerMetricDescs = []*internal.MetricDesc{fetchLatencyDesc, fetchFailedDesc, fetchRawBytesDesc, processLatencyDesc, processFailedDesc}
+)
+
+func (stats ConsumerStats) metricFamilies() []internal.MetricFamily {
+	family := func(desc *internal.MetricDesc, value func(s *ShardStats) internal.MetricSample) internal.MetricFamily {
+		family := internal.MetricFamily{Desc: desc}
+		for i := range stats.Shards {
+			s := &stats.Shards[i]
+			sample := value(s)
+			sample.LabelValues = []string{stats.Project, stats.Logstore, stats.ConsumerGroupName, stats.ConsumerName, strconv.Itoa(s.Shard)}
+			family.Samples = append(family.Samples, sample)
+		}
+		return family
+	}
+	summary := func(sum time.Duration, count int64) internal.MetricSample {
+		return internal.MetricSample{Value: sum.Seconds(), Count: uint64(count)}
+	}
+	return []internal.MetricFamily{
+		family(fetchLatencyDesc, func(s *ShardStats) internal.MetricSample { return summary(s.FetchLatencySum, s.FetchCount) }),
+		family(fetchFailedDesc, func(s *ShardStats) internal.MetricSample {
+			return internal.MetricSample{Value: float64(s.FetchFailedCount)}
+		}),
+		family(fetchRawBytesDesc, func(s *ShardStats) internal.MetricSample {
+			return internal.MetricSample{Value: float64(s.FetchRawBytes)}
+		}),
+		family(processLatencyDesc, func(s *ShardStats) internal.MetricSample { return summary(s.ProcessLatencySum, s.ProcessCount) }),
+		family(processFailedDesc, func(s *ShardStats) internal.MetricSample {
+			return internal.MetricSample{Value: float64(s.ProcessFailedCount)}
+		}),
+	}
+}
@@ -0,0 +1,47 @@
+package consumerLibrary
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumerStats(t *testing.T) {
+	worker := &ConsumerWorker{
+		client: &ConsumerClient{option: LogHubConfig{
+			Project:           "my-project",
+			Logstore:          "my-store",
+			ConsumerGroupName: "my-group",
+			ConsumerName:      "my-consumer",
+		}},
+	}
+	monitor := worker.getShardMonitor(1)
+	assert.Equal(t, monitor, worker.getShardMonitor(1))
+	monitor.RecordFetchRequest(&sls.PullLogMeta{RawSize: 100}, nil, time.Now())
+	monitor.RecordFetchRequest(nil, errors.New("fetch failed"), time.Now())
+	monitor.RecordProcess(errors.New("process failed"), time.Now())
+	worker.getShardMonitor(0).RecordProcess(nil, time.Now())
+	monitor.getAndResetMetrics()
+
+	stats := worker.Stats()
+	assert.Equal(t, "my-group", stats.ConsumerGroupName)
+	assert.Len(t, stats.Shards, 2)
+	assert.Equal(t, 0, stats.Shards[0].Shard)
+	assert.Equal(t, int64(1), stats.Shards[0].ProcessCount)
+	assert.Equal(t, 1, stats.Shards[1].Shard)
+	assert.Equal(t, int64(2), stats.Shards[1].FetchCount)
+	assert.Equal(t, int64(1), stats.Shards[1].FetchFailedCount)
+	assert.Equal(t, int64(100), stats.Shards[1].FetchRawBytes)
+	assert.Equal(t, int64(1), stats.Shards[1].ProcessFailedCount)
+
+	recorder := httptest.NewRecorder()
+	worker.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, recorder.Body.String(),
+		`sls_consumer_process_failed_total{project="my-project",logstore="my-store",consumer_group="my-group",consumer="my-consumer",shard="1"} 1`+"\n")
+	assert.Contains(t, recorder.Body.String(),
+		`sls_consumer_fetch_latency_seconds_count{project="my-project",logstore="my-store",consumer_group="my-group",consumer="my-consumer",shard="1"} 2`+"\n")
+}
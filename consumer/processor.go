@@ -17,3 +17,37 @@ func (processor ProcessFunc) Shutdown(checkpointTracker CheckPointTracker) error
 	// Do nothing
 	return nil
 }
+
+// ViewProcessor is a Processor of the fetched log groups as a view, which
+// decodes them lazily as they are read instead of decoding them all before
+// Process is called, see sls.LogGroupListView. The view is only valid during
+// ProcessView.
+type ViewProcessor interface {
+	ProcessView(int, *sls.LogGroupListView, CheckPointTracker) (string, error)
+	Shutdown(CheckPointTracker) error
+}
+
+type ViewProcessFunc func(int, *sls.LogGroupListView, CheckPointTracker) (string, error)
+
+func (processor ViewProcessFunc) ProcessView(shard int, view *sls.LogGroupListView, checkpointTracker CheckPointTracker) (string, error) {
+	return processor(shard, view, checkpointTracker)
+}
+
+func (processor ViewProcessFunc) Shutdown(checkpointTracker CheckPointTracker) error {
+	// Do nothing
+	return nil
+}
+
+// decodingProcessor is the ViewProcessor of a Processor, it decodes the whole view,
+// shard workers pass it the log groups pulled with PullLogsWithQuery instead
+type decodingProcessor struct {
+	Processor
+}
+
+func (processor decodingProcessor) ProcessView(shard int, view *sls.LogGroupListView, checkpointTracker CheckPointTracker) (string, error) {
+	lgList, err := view.Decode()
+	if err != nil {
+		return "", err
+	}
+	return processor.Process(shard, lgList, checkpointTracker)
+}
@@ -0,0 +1,32 @@
+package consumerLibrary
+
+import (
+	"testing"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodingProcessor(t *testing.T) {
+	data, err := proto.Marshal(&sls.LogGroupList{LogGroups: []*sls.LogGroup{
+		{Topic: proto.String("a")},
+		{Topic: proto.String("b")},
+	}})
+	require.NoError(t, err)
+
+	var topics []string
+	processor := decodingProcessor{ProcessFunc(func(shard int, lgList *sls.LogGroupList, tracker CheckPointTracker) (string, error) {
+		for _, group := range lgList.LogGroups {
+			topics = append(topics, group.GetTopic())
+		}
+		return "", nil
+	})}
+	_, err = processor.ProcessView(0, sls.NewLogGroupListView(data, nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, topics)
+
+	_, err = processor.ProcessView(0, sls.NewLogGroupListView(data[:len(data)-1], nil), nil)
+	assert.Error(t, err)
+}
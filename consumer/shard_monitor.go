@@ -24,7 +24,8 @@ type ShardMonitor struct {
 	shard          int
 	reportInterval time.Duration
 	lastReportTime time.Time
-	metrics        atomic.Value // *MonitorMetrics
+	metrics        atomic.Value   // *MonitorMetrics
+	total          MonitorMetrics // cumulative metrics, never reset
 }
 
 func newShardMonitor(shard int, reportInterval time.Duration) *ShardMonitor {
@@ -45,6 +46,13 @@ func (m *ShardMonitor) RecordFetchRequest(plm *sls.PullLogMeta, err error, start
 		metrics.logRawSize.Add(int64(plm.RawSize))
 	}
 	metrics.fetchLogHistogram.AddSample(float64(time.Since(start).Microseconds()))
+
+	if err != nil {
+		m.total.fetchReqFailedCount.Inc()
+	} else {
+		m.total.logRawSize.Add(int64(plm.RawSize))
+	}
+	m.total.fetchLogHistogram.AddSample(float64(time.Since(start).Microseconds()))
 }
 
 func (m *ShardMonitor) RecordProcess(err error, start time.Time) {
@@ -53,6 +61,11 @@ func (m *ShardMonitor) RecordProcess(err error, start time.Time) {
 		metrics.processFailedCount.Inc()
 	}
 	metrics.processHistogram.AddSample(float64(time.Since(start).Microseconds()))
+
+	if err != nil {
+		m.total.processFailedCount.Inc()
+	}
+	m.total.processHistogram.AddSample(float64(time.Since(start).Microseconds()))
 }
 
 func (m *ShardMonitor) getAndResetMetrics() *MonitorMetrics {
@@ -1,6 +1,7 @@
 package consumerLibrary
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"sync"
@@ -25,7 +26,7 @@ const (
 type ShardConsumerWorker struct {
 	client                    *ConsumerClient
 	consumerCheckPointTracker *DefaultCheckPointTracker
-	processor                 Processor
+	processor                 ViewProcessor
 	shardId                   int
 	monitor                   *ShardMonitor
 
@@ -37,7 +38,7 @@ type ShardConsumerWorker struct {
 	ioThrottler            ioThrottler
 }
 
-func newShardConsumerWorker(shardId int, consumerClient *ConsumerClient, consumerHeartBeat *ConsumerHeartBeat, processor Processor, logger log.Logger, ioThrottler ioThrottler) *ShardConsumerWorker {
+func newShardConsumerWorker(shardId int, consumerClient *ConsumerClient, consumerHeartBeat *ConsumerHeartBeat, processor ViewProcessor, logger log.Logger, ioThrottler ioThrottler, monitor *ShardMonitor) *ShardConsumerWorker {
 	shardConsumeWorker := &ShardConsumerWorker{
 		processor:                 processor,
 		consumerCheckPointTracker: initConsumerCheckpointTracker(shardId, consumerClient, consumerHeartBeat, logger),
@@ -47,7 +48,7 @@ func newShardConsumerWorker(shardId int, consumerClient *ConsumerClient, consume
 		shutDownFlag:              atomic.NewBool(false),
 		stopped:                   atomic.NewBool(false),
 		lastCheckpointSaveTime:    time.Now(),
-		monitor:                   newShardMonitor(shardId, time.Minute),
+		monitor:                   monitor,
 		ioThrottler:               ioThrottler,
 	}
 	return shardConsumeWorker
@@ -71,12 +72,12 @@ func (c *ShardConsumerWorker) runLoop() {
 
 	for !c.shutDownFlag.Load() {
 		lastFetchTime := time.Now()
-		shouldCallProcess, logGroupList, plm := c.fetchLogs(cursor)
+		shouldCallProcess, logs, plm := c.fetchLogs(cursor)
 		if !shouldCallProcess {
 			continue
 		}
 
-		cursor = c.callProcess(logGroupList, plm)
+		cursor = c.callProcess(logs, plm)
 		if c.shutDownFlag.Load() {
 			break
 		}
@@ -96,17 +97,33 @@ func (consumer *ShardConsumerWorker) getInitCursor() string {
 	return ""
 }
 
-func (c *ShardConsumerWorker) fetchLogs(cursor string) (shouldCallProcess bool, logGroupList *sls.LogGroupList, plm *sls.PullLogMeta) {
+// pulledLogs holds the decoded log groups for a Processor, or their view for a ViewProcessor
+type pulledLogs struct {
+	logGroupList *sls.LogGroupList
+	view         *sls.LogGroupListView
+}
+
+func (c *ShardConsumerWorker) fetchLogs(cursor string) (shouldCallProcess bool, logs pulledLogs, plm *sls.PullLogMeta) {
 	c.ioThrottler.Acquire()
 	defer c.ioThrottler.Release()
 
+	ctx, span := c.startSpan("sls consumer fetch")
+	defer span.End()
+
 	start := time.Now()
-	logGroupList, plm, err := c.client.pullLogs(c.shardId, cursor)
+	var err error
+	// Processors are fed the decoded log groups as they were before views
+	if _, ok := c.processor.(decodingProcessor); ok {
+		logs.logGroupList, plm, err = c.client.pullLogs(ctx, c.shardId, cursor)
+	} else {
+		logs.view, plm, err = c.client.pullLogsView(ctx, c.shardId, cursor)
+	}
 	c.monitor.RecordFetchRequest(plm, err, start)
 
 	if err != nil {
+		sls.RecordSpanError(span, err)
 		time.Sleep(fetchFailedSleepTime)
-		return false, nil, nil
+		return false, pulledLogs{}, nil
 	}
 
 	c.consumerCheckPointTracker.setCurrentCursor(cursor)
@@ -115,16 +132,22 @@ func (c *ShardConsumerWorker) fetchLogs(cursor string) (shouldCallProcess bool,
 	if cursor == plm.NextCursor { // already reach end of shard
 		c.saveCheckPointIfNeeded()
 		time.Sleep(noProgressSleepTime)
-		return false, nil, nil
+		return false, pulledLogs{}, nil
 	}
-	return true, logGroupList, plm
+	return true, logs, plm
 }
 
-func (c *ShardConsumerWorker) callProcess(logGroupList *sls.LogGroupList, plm *sls.PullLogMeta) (nextCursor string) {
+func (c *ShardConsumerWorker) callProcess(logs pulledLogs, plm *sls.PullLogMeta) (nextCursor string) {
 	for {
+		_, span := c.startSpan("sls consumer process",
+			sls.Attribute{Key: sls.AttrLogCount, Value: plm.Count})
 		start := time.Now()
-		rollBackCheckpoint, err := c.processInternal(logGroupList)
+		rollBackCheckpoint, err := c.processInternal(logs)
 		c.monitor.RecordProcess(err, start)
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
 
 		c.saveCheckPointIfNeeded()
 		if err != nil {
@@ -147,16 +170,38 @@ func (c *ShardConsumerWorker) callProcess(logGroupList *sls.LogGroupList, plm *s
 	}
 }
 
-func (c *ShardConsumerWorker) processInternal(logGroup *sls.LogGroupList) (rollBackCheckpoint string, err error) {
+func (c *ShardConsumerWorker) processInternal(logs pulledLogs) (rollBackCheckpoint string, err error) {
 	defer func() {
 		if r := c.recoverIfPanic("panic in your process function"); r != nil {
 			err = fmt.Errorf("panic when process: %v", r)
 		}
 	}()
 
-	return c.processor.Process(c.shardId, logGroup, c.consumerCheckPointTracker)
+	if processor, ok := c.processor.(decodingProcessor); ok {
+		return processor.Process(c.shardId, logs.logGroupList, c.consumerCheckPointTracker)
+	}
+	return c.processor.ProcessView(c.shardId, logs.view, c.consumerCheckPointTracker)
+}
+
+// startSpan starts a span of this shard, the span is a noop one if tracing is disabled
+func (c *ShardConsumerWorker) startSpan(name string, attrs ...sls.Attribute) (context.Context, sls.Span) {
+	tracer := c.client.option.Tracer
+	if tracer == nil {
+		return context.Background(), noopSpan{}
+	}
+	return tracer.Start(context.Background(), name, append([]sls.Attribute{
+		{Key: sls.AttrProject, Value: c.client.option.Project},
+		{Key: sls.AttrLogstore, Value: c.client.option.Logstore},
+		{Key: sls.AttrShard, Value: c.shardId},
+	}, attrs...)...)
 }
 
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attrs ...sls.Attribute) {}
+func (noopSpan) RecordError(err error)                {}
+func (noopSpan) End()                                 {}
+
 // call user shutdown func and flush checkpoint
 func (c *ShardConsumerWorker) doShutDown() {
 	level.Info(c.logger).Log("msg", "begin to shutdown, invoking processor.shutdown")
@@ -18,7 +18,8 @@ type ConsumerWorker struct {
 	client             *ConsumerClient
 	workerShutDownFlag *atomic.Bool
 	shardConsumer      sync.Map // map[int]*ShardConsumerWorker
-	processor          Processor
+	shardMonitors      sync.Map // map[int]*ShardMonitor, kept after shard released
+	processor          ViewProcessor
 	waitGroup          sync.WaitGroup
 	Logger             log.Logger
 	ioThrottler        ioThrottler
@@ -54,6 +55,12 @@ func InitConsumerWorkerWithCheckpointTracker(option LogHubConfig, do func(int, *
 // InitConsumerWorkerWithProcessor
 // you need save checkpoint by yourself and can do something after consumer shutdown
 func InitConsumerWorkerWithProcessor(option LogHubConfig, processor Processor) *ConsumerWorker {
+	return InitConsumerWorkerWithViewProcessor(option, decodingProcessor{processor})
+}
+
+// InitConsumerWorkerWithViewProcessor is like InitConsumerWorkerWithProcessor,
+// the log groups are passed to the processor as a view decoding them lazily
+func InitConsumerWorkerWithViewProcessor(option LogHubConfig, processor ViewProcessor) *ConsumerWorker {
 	logger := option.Logger
 	if logger == nil {
 		logger = logConfig(option)
@@ -155,12 +162,21 @@ func (consumerWorker *ConsumerWorker) getShardConsumer(shardId int) *ShardConsum
 		consumerWorker.consumerHeatBeat,
 		consumerWorker.processor,
 		consumerWorker.Logger,
-		consumerWorker.ioThrottler)
+		consumerWorker.ioThrottler,
+		consumerWorker.getShardMonitor(shardId))
 	consumerWorker.shardConsumer.Store(shardId, consumerIns)
 	return consumerIns
 
 }
 
+func (consumerWorker *ConsumerWorker) getShardMonitor(shardId int) *ShardMonitor {
+	monitor, ok := consumerWorker.shardMonitors.Load(shardId)
+	if !ok {
+		monitor, _ = consumerWorker.shardMonitors.LoadOrStore(shardId, newShardMonitor(shardId, time.Minute))
+	}
+	return monitor.(*ShardMonitor)
+}
+
 func (consumerWorker *ConsumerWorker) cleanShardConsumer(owned_shards []int) {
 
 	consumerWorker.shardConsumer.Range(
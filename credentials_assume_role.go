@@ -0,0 +1,92 @@
+package sls
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Config of a ram role to assume, see NewAssumeRoleCredentialsProvider.
+type AssumeRoleConfig struct {
+	RoleArn         string       // required
+	RoleSessionName string       // optional, default aliyun-log-go-sdk
+	Policy          string       // optional, a policy further restricting the permissions of the role
+	DurationSeconds int          // optional, the server side default is used if 0
+	ExternalID      string       // optional, required by roles that restrict their trusted accounts with an external id
+	StsEndpoint     string       // optional, default https://sts.aliyuncs.com
+	HTTPClient      *http.Client // optional, a client with a 10s timeout if nil
+}
+
+/**
+ * Create a credentials provider that assumes ram roles by calling sts AssumeRole.
+ *
+ * @param base The provider of the credentials used to assume the first role.
+ * @param roles The roles to assume in order, the credentials of each role are used to assume the next one,
+ * the provider returns the credentials of the last role.
+ *
+ * Credentials are fetched again before they expire, if it fails, the credentials fetched last time are
+ * returned until they expire, after that the error is returned.
+ */
+func NewAssumeRoleCredentialsProvider(base CredentialsProvider, roles ...AssumeRoleConfig) (CredentialsProvider, error) {
+	if base == nil {
+		return nil, fmt.Errorf("base credentials provider must not be nil")
+	}
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("at least one role is required")
+	}
+	provider := base
+	for i, role := range roles {
+		if role.RoleArn == "" {
+			return nil, fmt.Errorf("RoleArn of role %d must not be empty", i)
+		}
+		if role.RoleSessionName == "" {
+			role.RoleSessionName = defaultRoleSessionName
+		}
+		provider = &UpdateFuncProviderAdapter{
+			fetcher:       newAssumeRoleFetcher(provider, role),
+			fetchAhead:    defaultFetchAhead,
+			rejectExpired: true,
+		}
+	}
+	return provider, nil
+}
+
+// newAssumeRoleFetcher gets the base credentials once and retries only the sts call,
+// so that a failing base of chained roles is not retried by each role.
+func newAssumeRoleFetcher(base CredentialsProvider, role AssumeRoleConfig) CredentialsFetcher {
+	return func() (*tempCredentials, error) {
+		baseCred, err := base.GetCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("fail to get base credentials to assume role %s: %w", role.RoleArn, err)
+		}
+		return fetcherWithRetry(func() (*tempCredentials, error) {
+			return assumeRole(role, &baseCred)
+		}, STS_RETRY_TIMES)()
+	}
+}
+
+func assumeRole(role AssumeRoleConfig, baseCred *Credentials) (*tempCredentials, error) {
+	params := url.Values{}
+	params.Set("RoleArn", role.RoleArn)
+	params.Set("RoleSessionName", role.RoleSessionName)
+	if role.Policy != "" {
+		params.Set("Policy", role.Policy)
+	}
+	if role.DurationSeconds > 0 {
+		params.Set("DurationSeconds", strconv.Itoa(role.DurationSeconds))
+	}
+	if role.ExternalID != "" {
+		params.Set("ExternalId", role.ExternalID)
+	}
+	cred, err := doStsRequest(role.HTTPClient, role.StsEndpoint, "AssumeRole", params, baseCred)
+	if err != nil {
+		return nil, err
+	}
+	if cred.Expiration.Before(time.Now()) {
+		return nil, fmt.Errorf("AssumeRole %s returned expired credentials, expiration: %s",
+			role.RoleArn, cred.Expiration.Format(time.RFC3339))
+	}
+	return cred, nil
+}
@@ -0,0 +1,129 @@
+package sls
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssumeRoleCredentialsProvider(t *testing.T) {
+	secrets := map[string]string{"a0": "b0", "STS.a1": "b1"}
+	roles := map[string]string{"acs:ram::1:role/first": "STS.a1", "acs:ram::2:role/second": "STS.a2"}
+	failing := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRole", r.Form.Get("Action"))
+		accessKeyID := r.Form.Get("AccessKeyId")
+		if accessKeyID != "a0" {
+			assert.Equal(t, "token-"+accessKeyID, r.Form.Get("SecurityToken"))
+		}
+		signature := stsSignature(http.MethodPost, r.PostForm, secrets[accessKeyID])
+		if failing || signature != r.Form.Get("Signature") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"Code": "SignatureDoesNotMatch"})
+			return
+		}
+		id := roles[r.Form.Get("RoleArn")]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"Credentials": map[string]interface{}{
+				"AccessKeyId":     id,
+				"AccessKeySecret": secrets[id],
+				"SecurityToken":   "token-" + id,
+				"Expiration":      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			},
+		})
+	}))
+	defer ts.Close()
+	secrets["STS.a2"] = "b2"
+
+	_, err := NewAssumeRoleCredentialsProvider(NewStaticCredentialsProvider("a0", "b0", ""))
+	assert.Error(t, err)
+
+	provider, err := NewAssumeRoleCredentialsProvider(NewStaticCredentialsProvider("a0", "b0", ""),
+		AssumeRoleConfig{RoleArn: "acs:ram::1:role/first", StsEndpoint: ts.URL, Policy: `{"Statement": [{"Action": ["log:*"]}]}`},
+		AssumeRoleConfig{RoleArn: "acs:ram::2:role/second", StsEndpoint: ts.URL, RoleSessionName: "cross account"},
+	)
+	require.NoError(t, err)
+	cred, err := provider.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "STS.a2", AccessKeySecret: "b2", SecurityToken: "token-STS.a2"}, cred)
+
+	// expired credentials are not returned when failed to refresh
+	failing = true
+	adp := provider.(*UpdateFuncProviderAdapter)
+	adp.cred.Store(newTempCredentials("STS.a2", "b2", "token-STS.a2", time.Now().Add(time.Minute), time.Now()))
+	cred, err = provider.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "STS.a2", cred.AccessKeyID)
+	adp.cred.Store(newTempCredentials("STS.a2", "b2", "token-STS.a2", time.Now().Add(-time.Minute), time.Now()))
+	_, err = provider.GetCredentials()
+	assert.Error(t, err)
+}
+
+type countingCredentialsProvider struct {
+	calls int
+}
+
+func (p *countingCredentialsProvider) GetCredentials() (Credentials, error) {
+	p.calls++
+	return Credentials{}, errors.New("no credentials")
+}
+
+func TestAssumeRoleChainRetry(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	// a failing base is not retried by each chained role
+	base := &countingCredentialsProvider{}
+	provider, err := NewAssumeRoleCredentialsProvider(base,
+		AssumeRoleConfig{RoleArn: "acs:ram::1:role/first", StsEndpoint: ts.URL},
+		AssumeRoleConfig{RoleArn: "acs:ram::2:role/second", StsEndpoint: ts.URL},
+	)
+	require.NoError(t, err)
+	_, err = provider.GetCredentials()
+	assert.Error(t, err)
+	assert.Equal(t, 1, base.calls)
+	assert.Equal(t, 0, requests)
+
+	// the sts call is retried
+	provider, err = NewAssumeRoleCredentialsProvider(NewStaticCredentialsProvider("a0", "b0", ""),
+		AssumeRoleConfig{RoleArn: "acs:ram::1:role/first", StsEndpoint: ts.URL},
+		AssumeRoleConfig{RoleArn: "acs:ram::2:role/second", StsEndpoint: ts.URL},
+	)
+	require.NoError(t, err)
+	_, err = provider.GetCredentials()
+	assert.Error(t, err)
+	assert.Equal(t, STS_RETRY_TIMES+1, requests)
+}
+
+func TestPercentEncode(t *testing.T) {
+	assert.Equal(t, "a%20b%2A~%2F%3D", percentEncode("a b*~/="))
+}
+
+func TestStsRequestTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stop
+	}))
+	defer ts.Close()
+	defer close(stop)
+	defaultClient := stsHttpClient
+	stsHttpClient = newDefaultHTTPClient(100 * time.Millisecond)
+	defer func() { stsHttpClient = defaultClient }()
+
+	start := time.Now()
+	_, err := doStsRequest(nil, ts.URL, "AssumeRole", url.Values{}, nil)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 2*time.Second)
+}
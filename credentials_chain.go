@@ -0,0 +1,303 @@
+package sls
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	ENV_ACCESS_KEY_ID       = "ALIBABA_CLOUD_ACCESS_KEY_ID"
+	ENV_ACCESS_KEY_SECRET   = "ALIBABA_CLOUD_ACCESS_KEY_SECRET"
+	ENV_SECURITY_TOKEN      = "ALIBABA_CLOUD_SECURITY_TOKEN"
+	ENV_PROFILE             = "ALIBABA_CLOUD_PROFILE"
+	ENV_CONFIG_FILE         = "ALIBABA_CLOUD_CONFIG_FILE"
+	ENV_CREDENTIALS_PROCESS = "ALIBABA_CLOUD_CREDENTIALS_PROCESS"
+	ENV_ECS_METADATA        = "ALIBABA_CLOUD_ECS_METADATA" // name of the ecs ram role
+)
+
+// Credentials without an expiration returned by a credentials process are
+// fetched again after this interval.
+var processCredentialsTTL = time.Hour
+
+const PROCESS_RETRY_TIMES = 3
+
+// A CredentialsProvider that tries its providers in order and returns the
+// credentials of the first one that works.
+//
+// The provider that works is remembered and used until it fails, the
+// providers are responsible for caching and refreshing their credentials.
+type CredentialsChain struct {
+	providers []CredentialsProvider
+
+	mu      sync.Mutex
+	current CredentialsProvider
+}
+
+func NewCredentialsChain(providers ...CredentialsProvider) *CredentialsChain {
+	return &CredentialsChain{providers: providers}
+}
+
+// DefaultCredentialsChain returns a CredentialsChain that looks for credentials in:
+//  1. the environment variables ALIBABA_CLOUD_ACCESS_KEY_ID, ALIBABA_CLOUD_ACCESS_KEY_SECRET
+//     and ALIBABA_CLOUD_SECURITY_TOKEN
+//  2. the ram role assumed with the OIDC token of RRSA, see NewOIDCCredentialsProviderFromEnv
+//  3. the profile ALIBABA_CLOUD_PROFILE of the aliyun cli config file, ~/.aliyun/config.json
+//     or ALIBABA_CLOUD_CONFIG_FILE
+//  4. the output of the command ALIBABA_CLOUD_CREDENTIALS_PROCESS
+//  5. the ecs ram role ALIBABA_CLOUD_ECS_METADATA
+//
+// The OIDC config, the command and the ecs ram role are read from the
+// environment once, when the chain is created.
+func DefaultCredentialsChain() *CredentialsChain {
+	providers := []CredentialsProvider{NewEnvCredentialsProvider()}
+	if oidc, err := NewOIDCCredentialsProviderFromEnv(); err == nil {
+		providers = append(providers, oidc)
+	}
+	providers = append(providers, NewProfileCredentialsProvider("", ""))
+	if command := os.Getenv(ENV_CREDENTIALS_PROCESS); command != "" {
+		providers = append(providers, NewProcessCredentialsProvider(command))
+	}
+	if roleName := os.Getenv(ENV_ECS_METADATA); roleName != "" {
+		providers = append(providers, NewEcsRamRoleCredentialsProvider(roleName))
+	}
+	return NewCredentialsChain(providers...)
+}
+
+func (c *CredentialsChain) GetCredentials() (Credentials, error) {
+	// providers are called without the lock, so that a slow fetch does not block other callers
+	c.mu.Lock()
+	current := c.current
+	c.mu.Unlock()
+	if current != nil {
+		cred, err := current.GetCredentials()
+		if err == nil {
+			return cred, nil
+		}
+		level.Warn(Logger).Log("reason", "credentials chain provider failed, try others", "err", err)
+		c.mu.Lock()
+		if c.current == current {
+			c.current = nil
+		}
+		c.mu.Unlock()
+	}
+	var errs []error
+	for _, p := range c.providers {
+		cred, err := p.GetCredentials()
+		if err == nil {
+			c.mu.Lock()
+			c.current = p
+			c.mu.Unlock()
+			return cred, nil
+		}
+		errs = append(errs, err)
+	}
+	return Credentials{}, fmt.Errorf("no credentials found in chain: %w", joinErrors(errs...))
+}
+
+// A CredentialsProvider that reads credentials from the environment variables
+// ALIBABA_CLOUD_ACCESS_KEY_ID, ALIBABA_CLOUD_ACCESS_KEY_SECRET and the optional
+// ALIBABA_CLOUD_SECURITY_TOKEN, everytime credentials are needed.
+type EnvCredentialsProvider struct{}
+
+func NewEnvCredentialsProvider() *EnvCredentialsProvider {
+	return &EnvCredentialsProvider{}
+}
+
+func (p *EnvCredentialsProvider) GetCredentials() (Credentials, error) {
+	cred := Credentials{
+		AccessKeyID:     os.Getenv(ENV_ACCESS_KEY_ID),
+		AccessKeySecret: os.Getenv(ENV_ACCESS_KEY_SECRET),
+		SecurityToken:   os.Getenv(ENV_SECURITY_TOKEN),
+	}
+	if cred.AccessKeyID == "" || cred.AccessKeySecret == "" {
+		return Credentials{}, fmt.Errorf("env %s or %s is not set", ENV_ACCESS_KEY_ID, ENV_ACCESS_KEY_SECRET)
+	}
+	return cred, nil
+}
+
+// A CredentialsProvider that reads credentials from a profile of an aliyun cli
+// config file.
+//
+// Profiles of mode AK and StsToken are used as is, EcsRamRole profiles fetch
+// credentials of their ram_role_name and External profiles run their
+// process_command, see NewProcessCredentialsProvider.
+//
+// The config file is read once, the first time credentials are needed.
+type ProfileCredentialsProvider struct {
+	path    string
+	profile string
+
+	mu       sync.Mutex
+	provider CredentialsProvider
+}
+
+// Create a ProfileCredentialsProvider.
+//
+// @param path The config file, if empty, ALIBABA_CLOUD_CONFIG_FILE or ~/.aliyun/config.json.
+// @param profile The profile name, if empty, ALIBABA_CLOUD_PROFILE or the current profile of the config file.
+func NewProfileCredentialsProvider(path, profile string) *ProfileCredentialsProvider {
+	return &ProfileCredentialsProvider{path: path, profile: profile}
+}
+
+func (p *ProfileCredentialsProvider) GetCredentials() (Credentials, error) {
+	p.mu.Lock()
+	if p.provider == nil {
+		provider, err := p.load()
+		if err != nil {
+			p.mu.Unlock()
+			return Credentials{}, err
+		}
+		p.provider = provider
+	}
+	provider := p.provider
+	p.mu.Unlock()
+	return provider.GetCredentials()
+}
+
+// Config file of aliyun cli
+type cliConfig struct {
+	Current  string       `json:"current"`
+	Profiles []cliProfile `json:"profiles"`
+}
+
+type cliProfile struct {
+	Name            string `json:"name"`
+	Mode            string `json:"mode"`
+	AccessKeyID     string `json:"access_key_id"`
+	AccessKeySecret string `json:"access_key_secret"`
+	StsToken        string `json:"sts_token"`
+	RamRoleName     string `json:"ram_role_name"`
+	ProcessCommand  string `json:"process_command"`
+}
+
+func (p *ProfileCredentialsProvider) load() (CredentialsProvider, error) {
+	path := p.path
+	if path == "" {
+		path = os.Getenv(ENV_CONFIG_FILE)
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("fail to get home dir: %w", err)
+		}
+		path = filepath.Join(home, ".aliyun", "config.json")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read config file: %w", err)
+	}
+	var config cliConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal config file %s: %w", path, err)
+	}
+
+	name := p.profile
+	if name == "" {
+		name = os.Getenv(ENV_PROFILE)
+	}
+	if name == "" {
+		name = config.Current
+	}
+	if name == "" {
+		name = "default"
+	}
+	for _, profile := range config.Profiles {
+		if profile.Name == name {
+			return profile.provider()
+		}
+	}
+	return nil, fmt.Errorf("profile %s not found in config file %s", name, path)
+}
+
+func (profile *cliProfile) provider() (CredentialsProvider, error) {
+	switch profile.Mode {
+	case "", "AK", "StsToken":
+		if profile.AccessKeyID == "" || profile.AccessKeySecret == "" {
+			return nil, fmt.Errorf("profile %s has no access_key_id or access_key_secret", profile.Name)
+		}
+		token := profile.StsToken
+		if profile.Mode != "StsToken" {
+			token = ""
+		}
+		return NewStaticCredentialsProvider(profile.AccessKeyID, profile.AccessKeySecret, token), nil
+	case "EcsRamRole":
+		if profile.RamRoleName == "" {
+			return nil, fmt.Errorf("profile %s has no ram_role_name", profile.Name)
+		}
+		return NewEcsRamRoleCredentialsProvider(profile.RamRoleName), nil
+	case "External":
+		if profile.ProcessCommand == "" {
+			return nil, fmt.Errorf("profile %s has no process_command", profile.Name)
+		}
+		return NewProcessCredentialsProvider(profile.ProcessCommand), nil
+	}
+	return nil, fmt.Errorf("profile %s has unsupported mode %s", profile.Name, profile.Mode)
+}
+
+/**
+ * Create a credentials provider that runs an external command to fetch credentials.
+ *
+ * The command is run by the shell and must print credentials as json in the
+ * format of the aliyun cli, expiration is optional, in RFC3339 format:
+ *
+ *	{"mode": "StsToken", "access_key_id": "...", "access_key_secret": "...",
+ *	 "sts_token": "...", "expiration": "2024-01-01T00:00:00Z"}
+ *
+ * The command runs again before the credentials expire, or every hour for
+ * credentials without an expiration. A failed command is retried at most
+ * PROCESS_RETRY_TIMES times.
+ */
+func NewProcessCredentialsProvider(command string) CredentialsProvider {
+	return &UpdateFuncProviderAdapter{
+		fetcher:    fetcherWithRetry(newProcessFetcher(command), PROCESS_RETRY_TIMES),
+		fetchAhead: defaultFetchAhead,
+	}
+}
+
+// Output of a credentials process
+type processCredentials struct {
+	cliProfile
+	Expiration time.Time `json:"expiration"`
+}
+
+func newProcessFetcher(command string) CredentialsFetcher {
+	return func() (*tempCredentials, error) {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", command)
+		} else {
+			cmd = exec.Command("sh", "-c", command)
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("fail to run credentials process: %w, stderr: %s",
+				err, strings.TrimSpace(stderr.String()))
+		}
+		var resp processCredentials
+		if err := json.Unmarshal(out, &resp); err != nil {
+			return nil, fmt.Errorf("fail to unmarshal credentials process output: %w", err)
+		}
+		if resp.AccessKeyID == "" || resp.AccessKeySecret == "" {
+			return nil, fmt.Errorf("credentials process output has no access_key_id or access_key_secret")
+		}
+		now := time.Now()
+		expiration := resp.Expiration
+		if expiration.IsZero() {
+			expiration = now.Add(processCredentialsTTL)
+		}
+		return newTempCredentials(resp.AccessKeyID, resp.AccessKeySecret, resp.StsToken, expiration, now), nil
+	}
+}
@@ -0,0 +1,142 @@
+package sls
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingCredentialsProvider struct {
+	calls int
+}
+
+func (p *failingCredentialsProvider) GetCredentials() (Credentials, error) {
+	p.calls++
+	return Credentials{}, errors.New("no credentials")
+}
+
+func TestCredentialsChain(t *testing.T) {
+	failing := &failingCredentialsProvider{}
+	chain := NewCredentialsChain(failing, NewStaticCredentialsProvider("a1", "b1", ""))
+	cred, err := chain.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "a1", cred.AccessKeyID)
+	_, err = chain.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, 1, failing.calls)
+
+	_, err = NewCredentialsChain(failing).GetCredentials()
+	assert.Error(t, err)
+}
+
+// blockingCredentialsProvider blocks its first call until unblock is closed
+type blockingCredentialsProvider struct {
+	calls   int32
+	unblock chan struct{}
+}
+
+func (p *blockingCredentialsProvider) GetCredentials() (Credentials, error) {
+	if atomic.AddInt32(&p.calls, 1) == 1 {
+		<-p.unblock
+	}
+	return Credentials{AccessKeyID: "a1", AccessKeySecret: "b1"}, nil
+}
+
+func TestCredentialsChainConcurrent(t *testing.T) {
+	blocking := &blockingCredentialsProvider{unblock: make(chan struct{})}
+	chain := NewCredentialsChain(blocking)
+	defer close(blocking.unblock)
+	go chain.GetCredentials()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&blocking.calls) == 1 }, time.Second, time.Millisecond)
+
+	// a slow fetch does not block other callers
+	done := make(chan struct{})
+	go func() {
+		chain.GetCredentials()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("blocked by another caller")
+	}
+}
+
+func TestEnvAndProfileCredentialsProvider(t *testing.T) {
+	t.Setenv(ENV_ACCESS_KEY_ID, "")
+	t.Setenv(ENV_ACCESS_KEY_SECRET, "")
+	_, err := NewEnvCredentialsProvider().GetCredentials()
+	assert.Error(t, err)
+	t.Setenv(ENV_ACCESS_KEY_ID, "a1")
+	t.Setenv(ENV_ACCESS_KEY_SECRET, "b1")
+	t.Setenv(ENV_SECURITY_TOKEN, "c1")
+	cred, err := NewEnvCredentialsProvider().GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "a1", AccessKeySecret: "b1", SecurityToken: "c1"}, cred)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"current": "dev",
+		"profiles": [
+			{"name": "dev", "mode": "AK", "access_key_id": "a2", "access_key_secret": "b2"},
+			{"name": "prod", "mode": "StsToken", "access_key_id": "a3", "access_key_secret": "b3", "sts_token": "c3"},
+			{"name": "sso", "mode": "CloudSSO"}
+		]}`), 0644))
+	cred, err = NewProfileCredentialsProvider(path, "").GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "a2", AccessKeySecret: "b2"}, cred)
+	t.Setenv(ENV_PROFILE, "prod")
+	cred, err = NewProfileCredentialsProvider(path, "").GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "a3", AccessKeySecret: "b3", SecurityToken: "c3"}, cred)
+	_, err = NewProfileCredentialsProvider(path, "sso").GetCredentials()
+	assert.Error(t, err)
+	_, err = NewProfileCredentialsProvider(path, "missing").GetCredentials()
+	assert.Error(t, err)
+}
+
+func TestProcessCredentialsProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	output := filepath.Join(dir, "cred.json")
+	require.NoError(t, os.WriteFile(output, []byte(`{"mode": "StsToken", "access_key_id": "a1",
+		"access_key_secret": "b1", "sts_token": "c1", "expiration": "2000-01-01T00:00:00Z"}`), 0644))
+	provider := NewProcessCredentialsProvider("cat " + output)
+	cred, err := provider.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "a1", AccessKeySecret: "b1", SecurityToken: "c1"}, cred)
+
+	// expired credentials are fetched again
+	require.NoError(t, os.WriteFile(output, []byte(`{"access_key_id": "a2", "access_key_secret": "b2"}`), 0644))
+	cred, err = provider.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "a2", AccessKeySecret: "b2"}, cred)
+	assert.False(t, provider.(*UpdateFuncProviderAdapter).shouldRefresh())
+
+	_, err = NewProcessCredentialsProvider("exit 1").GetCredentials()
+	assert.Error(t, err)
+
+	// a failed command is retried
+	counter := filepath.Join(dir, "counter")
+	cred, err = NewProcessCredentialsProvider(`echo >> ` + counter + `; [ $(wc -l < ` + counter + `) -ge 2 ] && cat ` + output).GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "a2", cred.AccessKeyID)
+
+	// profiles of mode External run their command
+	config := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(config, []byte(`{"profiles": [
+		{"name": "default", "mode": "External", "process_command": "cat `+output+`"}]}`), 0644))
+	t.Setenv(ENV_PROFILE, "")
+	cred, err = NewProfileCredentialsProvider(config, "").GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "a2", cred.AccessKeyID)
+}
@@ -0,0 +1,97 @@
+package sls
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variables injected by RRSA of ACK
+const (
+	ENV_ROLE_ARN          = "ALIBABA_CLOUD_ROLE_ARN"
+	ENV_OIDC_PROVIDER_ARN = "ALIBABA_CLOUD_OIDC_PROVIDER_ARN"
+	ENV_OIDC_TOKEN_FILE   = "ALIBABA_CLOUD_OIDC_TOKEN_FILE"
+	ENV_ROLE_SESSION_NAME = "ALIBABA_CLOUD_ROLE_SESSION_NAME"
+	ENV_STS_ENDPOINT      = "ALIBABA_CLOUD_STS_ENDPOINT"
+)
+
+const defaultRoleSessionName = "aliyun-log-go-sdk"
+
+// Config of a credentials provider assuming a ram role with an OIDC token,
+// see NewOIDCCredentialsProvider.
+type OIDCCredentialsConfig struct {
+	RoleArn         string       // required
+	OIDCProviderArn string       // required
+	OIDCTokenFile   string       // required, read everytime credentials are fetched as the token rotates
+	RoleSessionName string       // optional, default aliyun-log-go-sdk
+	Policy          string       // optional, a policy further restricting the permissions of the role
+	DurationSeconds int          // optional, the server side default is used if 0
+	StsEndpoint     string       // optional, default https://sts.aliyuncs.com
+	HTTPClient      *http.Client // optional, a client with a 10s timeout if nil
+}
+
+/**
+ * Create a credentials provider that assumes a ram role with an OIDC token,
+ * such as the service account token mounted by RRSA of ACK, by calling sts AssumeRoleWithOIDC.
+ *
+ * Credentials are fetched again before they expire.
+ */
+func NewOIDCCredentialsProvider(config OIDCCredentialsConfig) (CredentialsProvider, error) {
+	if config.RoleArn == "" || config.OIDCProviderArn == "" || config.OIDCTokenFile == "" {
+		return nil, fmt.Errorf("RoleArn, OIDCProviderArn and OIDCTokenFile must not be empty")
+	}
+	if config.RoleSessionName == "" {
+		config.RoleSessionName = defaultRoleSessionName
+	}
+	return &UpdateFuncProviderAdapter{
+		fetcher:    fetcherWithRetry(newOIDCFetcher(config), STS_RETRY_TIMES),
+		fetchAhead: defaultFetchAhead,
+	}, nil
+}
+
+// Create an OIDC credentials provider from the environment variables injected by RRSA of ACK:
+// ALIBABA_CLOUD_ROLE_ARN, ALIBABA_CLOUD_OIDC_PROVIDER_ARN and ALIBABA_CLOUD_OIDC_TOKEN_FILE,
+// and the optional ALIBABA_CLOUD_ROLE_SESSION_NAME and ALIBABA_CLOUD_STS_ENDPOINT.
+func NewOIDCCredentialsProviderFromEnv() (CredentialsProvider, error) {
+	return NewOIDCCredentialsProvider(OIDCCredentialsConfig{
+		RoleArn:         os.Getenv(ENV_ROLE_ARN),
+		OIDCProviderArn: os.Getenv(ENV_OIDC_PROVIDER_ARN),
+		OIDCTokenFile:   os.Getenv(ENV_OIDC_TOKEN_FILE),
+		RoleSessionName: os.Getenv(ENV_ROLE_SESSION_NAME),
+		StsEndpoint:     os.Getenv(ENV_STS_ENDPOINT),
+	})
+}
+
+func newOIDCFetcher(config OIDCCredentialsConfig) CredentialsFetcher {
+	return func() (*tempCredentials, error) {
+		token, err := ioutil.ReadFile(config.OIDCTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read oidc token file: %w", err)
+		}
+		params := url.Values{}
+		params.Set("RoleArn", config.RoleArn)
+		params.Set("OIDCProviderArn", config.OIDCProviderArn)
+		params.Set("OIDCToken", strings.TrimSpace(string(token)))
+		params.Set("RoleSessionName", config.RoleSessionName)
+		if config.Policy != "" {
+			params.Set("Policy", config.Policy)
+		}
+		if config.DurationSeconds > 0 {
+			params.Set("DurationSeconds", strconv.Itoa(config.DurationSeconds))
+		}
+		cred, err := doStsRequest(config.HTTPClient, config.StsEndpoint, "AssumeRoleWithOIDC", params, nil)
+		if err != nil {
+			return nil, err
+		}
+		if cred.Expiration.Before(time.Now()) {
+			return nil, fmt.Errorf("AssumeRoleWithOIDC returned expired credentials, expiration: %s",
+				cred.Expiration.Format(time.RFC3339))
+		}
+		return cred, nil
+	}
+}
@@ -0,0 +1,78 @@
+package sls
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCCredentialsProvider(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token-1\n"), 0644))
+	requests := 0
+	expiration := time.Now().Add(time.Hour)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRoleWithOIDC", r.Form.Get("Action"))
+		assert.Equal(t, "acs:ram::123:role/app", r.Form.Get("RoleArn"))
+		assert.Equal(t, "acs:ram::123:oidc-provider/ack", r.Form.Get("OIDCProviderArn"))
+		assert.Equal(t, "aliyun-log-go-sdk", r.Form.Get("RoleSessionName"))
+		if r.Form.Get("OIDCToken") != "token-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"RequestId": "r1", "Code": "InvalidParameter.OIDCToken", "Message": "invalid token",
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"RequestId": "r2",
+			"Credentials": map[string]interface{}{
+				"AccessKeyId":     "STS.a1",
+				"AccessKeySecret": "b1",
+				"SecurityToken":   "c1",
+				"Expiration":      expiration.UTC().Format(time.RFC3339),
+			},
+		})
+	}))
+	defer ts.Close()
+
+	_, err := NewOIDCCredentialsProvider(OIDCCredentialsConfig{RoleArn: "acs:ram::123:role/app"})
+	assert.Error(t, err)
+
+	t.Setenv(ENV_ROLE_ARN, "acs:ram::123:role/app")
+	t.Setenv(ENV_OIDC_PROVIDER_ARN, "acs:ram::123:oidc-provider/ack")
+	t.Setenv(ENV_OIDC_TOKEN_FILE, tokenFile)
+	t.Setenv(ENV_ROLE_SESSION_NAME, "")
+	t.Setenv(ENV_STS_ENDPOINT, ts.URL)
+	provider, err := NewOIDCCredentialsProviderFromEnv()
+	require.NoError(t, err)
+	cred, err := provider.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, Credentials{AccessKeyID: "STS.a1", AccessKeySecret: "b1", SecurityToken: "c1"}, cred)
+	_, err = provider.GetCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	// credentials are fetched again before they expire
+	expiration = time.Now().Add(time.Minute)
+	provider, err = NewOIDCCredentialsProviderFromEnv()
+	require.NoError(t, err)
+	_, err = provider.GetCredentials()
+	require.NoError(t, err)
+	assert.True(t, provider.(*UpdateFuncProviderAdapter).shouldRefresh())
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token-2"), 0644))
+	provider, err = NewOIDCCredentialsProviderFromEnv()
+	require.NoError(t, err)
+	_, err = provider.GetCredentials()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "InvalidParameter.OIDCToken")
+}
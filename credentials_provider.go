@@ -87,6 +87,8 @@ type UpdateFuncProviderAdapter struct {
 
 	fetcher    CredentialsFetcher
 	fetchAhead time.Duration
+	// return an error instead of the last saved credentials if they are expired and fail to fetch new ones
+	rejectExpired bool
 }
 
 func updateFuncFetcher(updateFunc UpdateTokenFunction) CredentialsFetcher {
@@ -138,7 +140,7 @@ func (adp *UpdateFuncProviderAdapter) GetCredentials() (Credentials, error) {
 
 	lastCred := adp.cred.Load()
 	// use last saved credentials when failed to fetch new credentials
-	if lastCred != nil {
+	if lastCred != nil && !(adp.rejectExpired && lastCred.(*tempCredentials).isExpired()) {
 		return lastCred.(*tempCredentials).Credentials, nil
 	}
 	return Credentials{}, fmt.Errorf("updateTokenFunc fail to fetch credentials, err:%w", err)
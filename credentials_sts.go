@@ -0,0 +1,137 @@
+package sls
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	STS_DEFAULT_ENDPOINT = "https://sts.aliyuncs.com"
+	STS_API_VERSION      = "2015-04-01"
+	STS_RETRY_TIMES      = 3
+)
+
+// stsHttpClient calls sts if no http client is given, so that an unresponsive
+// sts endpoint does not hang the requests waiting for credentials.
+var stsHttpClient = newDefaultHTTPClient(defaultStsRequestTimeout)
+
+const defaultStsRequestTimeout = 10 * time.Second
+
+// Response of sts AssumeRole* actions
+type stsCredentialsResp struct {
+	RequestID   string `json:"RequestId"`
+	Code        string `json:"Code"`
+	Message     string `json:"Message"`
+	Credentials struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		AccessKeySecret string    `json:"AccessKeySecret"`
+		SecurityToken   string    `json:"SecurityToken"`
+		Expiration      time.Time `json:"Expiration"`
+	} `json:"Credentials"`
+}
+
+// doStsRequest calls the sts action with params and returns the credentials in its response.
+//
+// @param endpoint The sts endpoint, with or without scheme, https is used if no scheme.
+// @param client If nil, stsHttpClient is used.
+// @param cred The credentials to sign the request with, the request is anonymous if nil.
+func doStsRequest(client *http.Client, endpoint, action string, params url.Values, cred *Credentials) (*tempCredentials, error) {
+	if client == nil {
+		client = stsHttpClient
+	}
+	if endpoint == "" {
+		endpoint = STS_DEFAULT_ENDPOINT
+	} else if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "https://" + endpoint
+	}
+	params.Set("Action", action)
+	params.Set("Format", "JSON")
+	params.Set("Version", STS_API_VERSION)
+	params.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	if cred != nil {
+		signStsRequest(http.MethodPost, params, cred)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint+"/", strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("fail to build http request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fail to do http request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read http resp body: %w", err)
+	}
+	fetchResp := stsCredentialsResp{}
+	if err := json.Unmarshal(data, &fetchResp); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal json: %w, body: %s", err, string(data))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sts %s failed, status: %d, code: %s, message: %s, requestId: %s",
+			action, resp.StatusCode, fetchResp.Code, fetchResp.Message, fetchResp.RequestID)
+	}
+	res := newTempCredentials(
+		fetchResp.Credentials.AccessKeyID,
+		fetchResp.Credentials.AccessKeySecret,
+		fetchResp.Credentials.SecurityToken,
+		fetchResp.Credentials.Expiration,
+		time.Now())
+	if !res.isValid() {
+		return nil, fmt.Errorf("invalid sts %s result, body: %s", action, string(data))
+	}
+	return res, nil
+}
+
+// signStsRequest adds the credentials and the signature of cred to the params of a rpc style request
+func signStsRequest(method string, params url.Values, cred *Credentials) {
+	params.Set("AccessKeyId", cred.AccessKeyID)
+	if cred.SecurityToken != "" {
+		params.Set("SecurityToken", cred.SecurityToken)
+	}
+	params.Set("SignatureMethod", "HMAC-SHA1")
+	params.Set("SignatureVersion", "1.0")
+	params.Set("SignatureNonce", strconv.FormatInt(time.Now().UnixNano(), 36))
+	params.Set("Signature", stsSignature(method, params, cred.AccessKeySecret))
+}
+
+// Signature = base64(hmac-sha1(AccessKeySecret + "&", Method + "&%2F&" + percentEncode(CanonicalizedQuery)))
+func stsSignature(method string, params url.Values, accessKeySecret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != "Signature" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, percentEncode(k)+"="+percentEncode(params.Get(k)))
+	}
+	stringToSign := method + "&" + percentEncode("/") + "&" + percentEncode(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(accessKeySecret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// percentEncode encodes s as required by rpc style signatures
+func percentEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	s = strings.ReplaceAll(s, "%7E", "~")
+	return s
+}
@@ -1,7 +1,10 @@
 package sls
 
 import (
+	"context"
 	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -72,3 +75,33 @@ func TestProjectHttpClient(t *testing.T) {
 		assert.Equal(t, p.httpClient.Timeout, time.Second*19)
 	}
 }
+
+func TestClientWithContext(t *testing.T) {
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(3 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	client := CreateNormalInterfaceWithContext(ts.URL, NewStaticCredentialsProvider("id", "key", ""))
+	assert.Equal(t, context.Background(), client.Context())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	bound := client.WithContext(ctx)
+	assert.Equal(t, ctx, bound.Context())
+	// the copies share the http client created on first use
+	assert.NotNil(t, bound.(*Client).HTTPClient)
+	assert.Same(t, bound.(*Client).HTTPClient, client.WithContext(ctx).(*Client).HTTPClient)
+
+	start := time.Now()
+	_, err := bound.ListShards("", "my-store")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "context deadline exceeded")
+	assert.True(t, time.Since(start) < 2*time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+}
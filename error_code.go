@@ -13,6 +13,8 @@ const MISSING_API_VERSION = "MissingAPIVersion"
 const INVALID_API_VERSION = "InvalidAPIVersion"
 const MISS_ACCESS_KEY_ID = "MissAccessKeyId"
 const UN_AUTHORIZED = "Unauthorized"
+const INVALID_ACCESS_KEY_ID = "InvalidAccessKeyId"
+const SECURITY_TOKEN_EXPIRED = "SecurityTokenExpired"
 const MISSING_SIGNATURE_METHOD = "MissingSignatureMethod"
 const INVALID_SIGNATURE_METHOD = "InvalidSignatureMethod"
 const REQUEST_TIME_TOO_SKEWED = "RequestTimeTooSkewed"
@@ -33,6 +35,7 @@ const PARAMETER_INVALID = "ParameterInvalid"
 const INVALID_LOGSTORE_QUERY = "InvalidLogStoreQuery"
 const LOGSTORE_WITHOUT_SHARD = "LogStoreWithoutShard"
 const SHARD_NOT_EXIST = "ShardNotExist"
+const SHARD_READ_ONLY = "ShardReadOnly"
 const INVALID_CURSOR = "InvalidCursor"
 const POST_BODY_INVALID = "PostBodyInvalid"
 const INVALID_TIMESTAMP = "InvalidTimestamp"
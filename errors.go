@@ -2,8 +2,68 @@ package sls
 
 import (
 	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
 )
 
+// Error categories, errors returned by the sdk match them with errors.Is
+// according to their error code and http status code, eg.
+//
+//	if errors.Is(err, sls.ErrNotFound) {
+//		// the project, logstore, consumer group ... does not exist
+//	}
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrShardReadOnly = errors.New("shard read only")
+	ErrThrottled     = errors.New("throttled")
+	ErrServerBusy    = errors.New("server busy")
+)
+
+// matchCategory reports whether an error with httpCode and code belongs to the category target
+func matchCategory(target error, httpCode int, code string) bool {
+	switch target {
+	case ErrNotFound:
+		return httpCode == http.StatusNotFound || hasAnySuffix(code, "NotExist", "NotExists", "NotExsit", "NotFound")
+	case ErrAlreadyExists:
+		return hasAnySuffix(code, "AlreadyExist", "AlreadyExists")
+	case ErrQuotaExceeded:
+		return hasAnySuffix(code, "QuotaExceed", "QuotaExceeded")
+	case ErrUnauthorized:
+		switch code {
+		case UN_AUTHORIZED, SIGNATURE_NOT_MATCH, MISS_ACCESS_KEY_ID, INVALID_ACCESS_KEY_ID, SECURITY_TOKEN_EXPIRED:
+			return true
+		}
+		return httpCode == http.StatusUnauthorized
+	case ErrInvalidCursor:
+		return code == INVALID_CURSOR
+	case ErrShardReadOnly:
+		return code == SHARD_READ_ONLY
+	case ErrThrottled:
+		switch code {
+		case WRITE_QUOTA_EXCEED, SHARD_WRITE_QUOTA_EXCEED, READ_QUOTA_EXCEED, SHARD_READ_QUOTA_EXCEED:
+			return true
+		}
+		return httpCode == http.StatusTooManyRequests
+	case ErrServerBusy:
+		return code == SERVER_BUSY || httpCode == http.StatusServiceUnavailable
+	}
+	return false
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 // BadResponseError : special sls error, not valid json format
 type BadResponseError struct {
 	RespBody   string
@@ -23,6 +83,11 @@ func (e BadResponseError) Error() string {
 	return e.String()
 }
 
+// Is reports whether the http status code of e belongs to the category target, eg. ErrNotFound.
+func (e BadResponseError) Is(target error) bool {
+	return matchCategory(target, e.HTTPCode, "")
+}
+
 // NewBadResponseError ...
 func NewBadResponseError(body string, header map[string][]string, httpCode int) *BadResponseError {
 	return &BadResponseError{
@@ -0,0 +1,44 @@
+package sls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCategories(t *testing.T) {
+	cases := []struct {
+		err    error
+		target error
+	}{
+		{&Error{HTTPCode: 404, Code: LOGSTORE_NOT_EXIST}, ErrNotFound},
+		{&Error{HTTPCode: 400, Code: "ConsumerGroupNotExist"}, ErrNotFound},
+		{&BadResponseError{HTTPCode: 404}, ErrNotFound},
+		{&Error{HTTPCode: 400, Code: LOGSTORE_ALREADY_EXIST}, ErrAlreadyExists},
+		{&Error{HTTPCode: 403, Code: PROJECT_QUOTA_EXCEED}, ErrQuotaExceeded},
+		{&Error{HTTPCode: 403, Code: SHARD_WRITE_QUOTA_EXCEED}, ErrThrottled},
+		{&Error{HTTPCode: 401, Code: SIGNATURE_NOT_MATCH}, ErrUnauthorized},
+		{&Error{HTTPCode: 400, Code: INVALID_CURSOR}, ErrInvalidCursor},
+		{&Error{HTTPCode: 403, Code: SHARD_READ_ONLY}, ErrShardReadOnly},
+		{&Error{HTTPCode: 503, Code: SERVER_BUSY}, ErrServerBusy},
+		{fmt.Errorf("get logstore: %w", &Error{HTTPCode: 404, Code: LOGSTORE_NOT_EXIST}), ErrNotFound},
+	}
+	for _, c := range cases {
+		assert.True(t, errors.Is(c.err, c.target), "%v should be %v", c.err, c.target)
+	}
+
+	assert.False(t, errors.Is(&Error{HTTPCode: 403, Code: PROJECT_QUOTA_EXCEED}, ErrThrottled))
+	assert.False(t, errors.Is(&Error{HTTPCode: 500, Code: INTERNAL_SERVER_ERROR}, ErrServerBusy))
+
+	clientErr := NewClientError(fmt.Errorf("send request: %w", context.Canceled))
+	assert.True(t, errors.Is(clientErr, context.Canceled))
+
+	wrapped := WrapError(fmt.Errorf("send request: %w", context.Canceled))
+	assert.True(t, errors.Is(wrapped, context.Canceled))
+	assert.Equal(t, int32(0), wrapped.HTTPCode)
+	assert.Equal(t, "", wrapped.Code)
+	assert.Equal(t, "send request: context canceled", wrapped.Message)
+}
@@ -0,0 +1,36 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	record  []string
+}
+
+// NewCSVWriter returns a RowWriter writing a header of the columns and a
+// record per row, missing values are written as empty fields.
+func NewCSVWriter(w io.Writer) RowWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Begin(columns []string, sample []map[string]string) error {
+	c.columns = columns
+	c.record = make([]string, len(columns))
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) WriteRow(row map[string]string) error {
+	for i, column := range c.columns {
+		c.record[i] = row[column]
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,126 @@
+// Package export writes the results of searches and analytic SQLs to CSV,
+// NDJSON or Parquet files.
+//
+// Results are walked page by page with sls.LogIterator, so only a page of logs
+// is held in memory, a Parquet row group apart. The columns of a result and
+// their order are the keys of the meta of its first page, followed by the
+// other keys of the rows of the first page in sorted order, unless set by
+// Options.Columns.
+//
+//	f, err := os.Create("errors.csv")
+//	n, err := export.WriteCSV(ctx, client, project, logstore, &sls.GetLogRequest{
+//		From: from, To: to, Query: "status >= 500 | select status, count(*) as c group by status",
+//	}, f, export.Options{})
+package export
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+)
+
+// RowWriter writes the rows of a result in a format.
+type RowWriter interface {
+	// Begin is called once before the rows, with the columns of the result and
+	// the rows of its first page, which are written with WriteRow afterwards.
+	Begin(columns []string, sample []map[string]string) error
+	WriteRow(row map[string]string) error
+	// Close flushes the rows written, it does not close the underlying writer.
+	Close() error
+}
+
+// Options configures an export.
+type Options struct {
+	// Columns sets the columns and their order, keys of the rows out of them are not written to CSV and Parquet
+	Columns []string
+	// Iterator configures the paging of the result
+	Iterator sls.LogIteratorOptions
+}
+
+// Export writes the result of req to w and returns the number of rows
+// written. w is closed after the last row, and left open on error.
+func Export(ctx context.Context, client sls.ClientInterface, project, logstore string, req *sls.GetLogRequest, w RowWriter, opts Options) (int64, error) {
+	it := sls.NewLogIterator(client, project, logstore, req, opts.Iterator)
+	rows, err := it.Next(ctx)
+	if err != nil && err != sls.ErrNoMorePages {
+		return 0, err
+	}
+	columns := opts.Columns
+	if columns == nil {
+		columns = resultColumns(it.Meta(), rows)
+	}
+	if err := w.Begin(columns, rows); err != nil {
+		return 0, err
+	}
+	var n int64
+	for len(rows) > 0 {
+		for _, row := range rows {
+			if err := w.WriteRow(row); err != nil {
+				return n, err
+			}
+			n++
+		}
+		rows, err = it.Next(ctx)
+		if err == sls.ErrNoMorePages {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, w.Close()
+}
+
+// WriteCSV writes the result of req to w as CSV, see NewCSVWriter.
+func WriteCSV(ctx context.Context, client sls.ClientInterface, project, logstore string, req *sls.GetLogRequest, w io.Writer, opts Options) (int64, error) {
+	return Export(ctx, client, project, logstore, req, NewCSVWriter(w), opts)
+}
+
+// WriteNDJSON writes the result of req to w as NDJSON, see NewNDJSONWriter.
+func WriteNDJSON(ctx context.Context, client sls.ClientInterface, project, logstore string, req *sls.GetLogRequest, w io.Writer, opts Options) (int64, error) {
+	return Export(ctx, client, project, logstore, req, NewNDJSONWriter(w), opts)
+}
+
+// WriteParquet writes the result of req to w as a Parquet file, see NewParquetWriter.
+func WriteParquet(ctx context.Context, client sls.ClientInterface, project, logstore string, req *sls.GetLogRequest, w io.Writer, opts Options, parquetOpts ParquetOptions) (int64, error) {
+	return Export(ctx, client, project, logstore, req, NewParquetWriter(w, parquetOpts), opts)
+}
+
+// resultColumns returns the keys of meta followed by the other keys of rows in sorted order
+func resultColumns(meta *sls.GetLogsV3ResponseMeta, rows []map[string]string) []string {
+	columns := []string{}
+	seen := map[string]bool{}
+	if meta != nil {
+		for _, key := range meta.Keys {
+			if !seen[key] {
+				columns = append(columns, key)
+				seen[key] = true
+			}
+		}
+	}
+	var extra []string
+	for _, row := range rows {
+		for key := range row {
+			if !seen[key] {
+				extra = append(extra, key)
+				seen[key] = true
+			}
+		}
+	}
+	sort.Strings(extra)
+	return append(columns, extra...)
+}
+
+// sortedExtraKeys returns the keys of row out of columns in sorted order
+func sortedExtraKeys(row map[string]string, columns map[string]bool) []string {
+	var keys []string
+	for key := range row {
+		if !columns[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
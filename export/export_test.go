@@ -0,0 +1,142 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer serves GetLogsV3 over rows, with keys in the meta of every page
+func fakeServer(rows []map[string]string, keys []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req sls.GetLogRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		page := []map[string]string{}
+		for i := req.Offset; i < int64(len(rows)) && i < req.Offset+req.Lines; i++ {
+			page = append(page, rows[i])
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"meta": map[string]interface{}{"progress": "Complete", "keys": keys},
+			"data": page,
+		})
+	}))
+}
+
+func testRows(n int) []map[string]string {
+	var rows []map[string]string
+	for i := 0; i < n; i++ {
+		row := map[string]string{
+			"__time__": strconv.Itoa(1000 + i),
+			"status":   "200",
+			"latency":  strconv.Itoa(i%3) + ".5",
+			"path":     "/" + strconv.Itoa(i),
+			"cached":   strconv.FormatBool(i%2 == 0),
+		}
+		if i == 150 {
+			row["host"] = "h"
+			delete(row, "latency")
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestExport(t *testing.T) {
+	ts := fakeServer(testRows(250), []string{"status", "__time__"})
+	defer ts.Close()
+	client := sls.CreateNormalInterfaceV2(ts.URL, sls.NewStaticCredentialsProvider("id", "key", ""))
+	req := &sls.GetLogRequest{From: 1000, To: 2000, Query: "*"}
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	n, err := WriteCSV(ctx, client, "", "logstore", req, &buf, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), n)
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 251)
+	assert.Equal(t, []string{"status", "__time__", "cached", "latency", "path"}, records[0])
+	assert.Equal(t, []string{"200", "1000", "true", "0.5", "/0"}, records[1])
+	assert.Equal(t, []string{"200", "1150", "true", "", "/150"}, records[151])
+
+	buf.Reset()
+	n, err = WriteNDJSON(ctx, client, "", "logstore", req, &buf, Options{Columns: []string{"path", "status"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), n)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 250)
+	assert.Equal(t, `{"path":"/0","status":"200","__time__":"1000","cached":"true","latency":"0.5"}`, lines[0])
+	assert.Equal(t, `{"path":"/150","status":"200","__time__":"1150","cached":"true","host":"h"}`, lines[150])
+
+	buf.Reset()
+	n, err = WriteParquet(ctx, client, "", "logstore", req, &buf, Options{}, ParquetOptions{RowGroupSize: 100})
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), n)
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("PAR1")))
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("PAR1")))
+
+	// a single page, with a column missing from all rows
+	buf.Reset()
+	n, err = WriteParquet(ctx, client, "", "logstore", &sls.GetLogRequest{From: 1000, To: 2000, Query: "*"},
+		&buf, Options{Columns: []string{"a"}, Iterator: sls.LogIteratorOptions{PageSize: 1000}}, ParquetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), n)
+}
+
+func TestExportEmpty(t *testing.T) {
+	ts := fakeServer(nil, nil)
+	defer ts.Close()
+	client := sls.CreateNormalInterfaceV2(ts.URL, sls.NewStaticCredentialsProvider("id", "key", ""))
+	req := &sls.GetLogRequest{From: 1000, To: 2000, Query: "*"}
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	_, err := WriteParquet(ctx, client, "", "logstore", req, &buf, Options{}, ParquetOptions{})
+	assert.Equal(t, ErrNoColumns, err)
+
+	// with the columns set, an empty file is written
+	n, err := WriteParquet(ctx, client, "", "logstore", req, &buf, Options{Columns: []string{"a"}}, ParquetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("PAR1")))
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("PAR1")))
+
+	buf.Reset()
+	n, err = WriteCSV(ctx, client, "", "logstore", req, &buf, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestParquetTypes(t *testing.T) {
+	sample := []map[string]string{
+		{"n": "1", "f": "1", "b": "true", "s": "x", "null": "null"},
+		{"n": "", "f": "1.5", "b": "false", "s": "1"},
+	}
+	assert.Equal(t, TypeInt64, inferType("n", sample))
+	assert.Equal(t, TypeDouble, inferType("f", sample))
+	assert.Equal(t, TypeBoolean, inferType("b", sample))
+	assert.Equal(t, TypeString, inferType("s", sample))
+	assert.Equal(t, TypeString, inferType("null", sample))
+	assert.Equal(t, TypeString, inferType("missing", sample))
+
+	var buf bytes.Buffer
+	w := NewParquetWriter(&buf, ParquetOptions{ColumnTypes: map[string]ColumnType{"s": TypeString, "f": TypeDouble}})
+	require.NoError(t, w.Begin([]string{"n", "f", "s"}, sample[:1]))
+	for _, row := range sample {
+		require.NoError(t, w.WriteRow(row))
+	}
+	err := w.WriteRow(map[string]string{"n": "1.5"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "column n")
+	require.NoError(t, w.Close())
+}
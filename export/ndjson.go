@@ -0,0 +1,59 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+	known   map[string]bool
+}
+
+// NewNDJSONWriter returns a RowWriter writing a json object of string values
+// per line. The keys of an object are in the order of the columns, followed by
+// the other keys of its row in sorted order, missing values are omitted.
+func NewNDJSONWriter(w io.Writer) RowWriter {
+	return &ndjsonWriter{w: bufio.NewWriter(w)}
+}
+
+func (n *ndjsonWriter) Begin(columns []string, sample []map[string]string) error {
+	n.columns = columns
+	n.known = make(map[string]bool, len(columns))
+	for _, column := range columns {
+		n.known[column] = true
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) WriteRow(row map[string]string) error {
+	n.w.WriteByte('{')
+	first := true
+	write := func(key, value string) {
+		if !first {
+			n.w.WriteByte(',')
+		}
+		first = false
+		k, _ := json.Marshal(key)
+		v, _ := json.Marshal(value)
+		n.w.Write(k)
+		n.w.WriteByte(':')
+		n.w.Write(v)
+	}
+	for _, column := range n.columns {
+		if value, ok := row[column]; ok {
+			write(column, value)
+		}
+	}
+	for _, key := range sortedExtraKeys(row, n.known) {
+		write(key, row[key])
+	}
+	n.w.WriteByte('}')
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
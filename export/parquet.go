@@ -0,0 +1,158 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/aliyun/aliyun-log-go-sdk/internal/parquet"
+)
+
+// ColumnType is the type of a Parquet column.
+type ColumnType int
+
+const (
+	// TypeAuto infers the type of a column from the values of the first page
+	TypeAuto ColumnType = iota
+	TypeString
+	TypeInt64
+	TypeDouble
+	TypeBoolean
+)
+
+// ParquetOptions configures a Parquet export.
+type ParquetOptions struct {
+	// ColumnTypes sets the types of columns, the others are inferred
+	ColumnTypes map[string]ColumnType
+	// RowGroupSize is the number of rows of a row group, default 10000
+	RowGroupSize int
+}
+
+type parquetWriter struct {
+	w       io.Writer
+	opts    ParquetOptions
+	pw      *parquet.Writer
+	columns []string
+	types   []ColumnType
+	values  []interface{}
+}
+
+// ErrNoColumns is returned by the Parquet RowWriter for a result without columns, such as
+// an empty search result, as a Parquet file needs at least one. Options.Columns sets them.
+var ErrNoColumns = errors.New("result has no columns to write a Parquet file with")
+
+// NewParquetWriter returns a RowWriter writing a Parquet file of optional
+// columns. The type of a column is the first of int64, double, boolean and
+// string all its values of the first page parse as, missing and "null" values
+// being nulls, and empty values too in non string columns. Values of later
+// pages which do not parse as the type of their column fail the export,
+// ParquetOptions.ColumnTypes then sets the type.
+//
+// An empty result is written as a file without rows, it fails with ErrNoColumns
+// if the result has no columns either.
+func NewParquetWriter(w io.Writer, opts ParquetOptions) RowWriter {
+	return &parquetWriter{w: w, opts: opts}
+}
+
+func (p *parquetWriter) Begin(columns []string, sample []map[string]string) error {
+	if len(columns) == 0 {
+		return ErrNoColumns
+	}
+	p.columns = columns
+	p.types = make([]ColumnType, len(columns))
+	p.values = make([]interface{}, len(columns))
+	schema := make([]parquet.Column, len(columns))
+	for i, column := range columns {
+		typ := p.opts.ColumnTypes[column]
+		if typ == TypeAuto {
+			typ = inferType(column, sample)
+		}
+		p.types[i] = typ
+		schema[i] = parquet.Column{Name: column}
+		switch typ {
+		case TypeString:
+			schema[i].Type = parquet.ByteArray
+		case TypeInt64:
+			schema[i].Type = parquet.Int64
+		case TypeDouble:
+			schema[i].Type = parquet.Double
+		case TypeBoolean:
+			schema[i].Type = parquet.Boolean
+		default:
+			return fmt.Errorf("invalid type %d of column %s", typ, column)
+		}
+	}
+	pw, err := parquet.NewWriter(p.w, schema)
+	if err != nil {
+		return err
+	}
+	pw.RowGroupSize = p.opts.RowGroupSize
+	p.pw = pw
+	return nil
+}
+
+func (p *parquetWriter) WriteRow(row map[string]string) error {
+	for i, column := range p.columns {
+		value, ok := row[column]
+		v, err := parseValue(p.types[i], value, ok)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column, err)
+		}
+		p.values[i] = v
+	}
+	return p.pw.Write(p.values)
+}
+
+func (p *parquetWriter) Close() error {
+	return p.pw.Close()
+}
+
+// inferType returns the narrowest type all values of column in sample parse as, TypeString if they are all null
+func inferType(column string, sample []map[string]string) ColumnType {
+	defined := false
+	for _, row := range sample {
+		if value := row[column]; value != "" && value != "null" {
+			defined = true
+			break
+		}
+	}
+	if !defined {
+		return TypeString
+	}
+	for _, typ := range []ColumnType{TypeInt64, TypeDouble, TypeBoolean} {
+		ok := true
+		for _, row := range sample {
+			value, present := row[column]
+			if _, err := parseValue(typ, value, present); err != nil {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return typ
+		}
+	}
+	return TypeString
+}
+
+// parseValue returns value as typ, nil for missing and "null" values, and empty values of non string types
+func parseValue(typ ColumnType, value string, present bool) (interface{}, error) {
+	if !present || value == "null" {
+		return nil, nil
+	}
+	if typ == TypeString {
+		return value, nil
+	}
+	if value == "" {
+		return nil, nil
+	}
+	switch typ {
+	case TypeInt64:
+		return strconv.ParseInt(value, 10, 64)
+	case TypeDouble:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return strconv.ParseBool(value)
+	}
+}
@@ -0,0 +1,28 @@
+"""Checks golden.parquet with pyarrow, the reference implementation.
+
+    pip install pyarrow
+    python3 verify_golden.py
+"""
+import os
+
+import pyarrow as pa
+import pyarrow.parquet as pq
+
+path = os.path.join(os.path.dirname(os.path.abspath(__file__)), "golden.parquet")
+f = pq.ParquetFile(path)
+assert f.metadata.num_rows == 3
+assert f.metadata.num_row_groups == 2
+assert f.metadata.created_by == "aliyun-log-go-sdk"
+assert f.schema_arrow == pa.schema([
+    ("n", pa.int64()),
+    ("f", pa.float64()),
+    ("b", pa.bool_()),
+    ("s", pa.string()),
+]), f.schema_arrow
+assert f.read().to_pydict() == {
+    "n": [1, None, -3],
+    "f": [0.5, None, 2.0],
+    "b": [True, False, None],
+    "s": ["a", None, "日志"],
+}, f.read().to_pydict()
+print("ok")
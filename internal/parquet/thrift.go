@@ -0,0 +1,92 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// types of the thrift compact protocol
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// compactWriter writes thrift structs with the compact protocol, the metadata
+// of parquet files is serialized with it
+type compactWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func (w *compactWriter) fieldHeader(id int16, typ byte) {
+	delta := id - w.lastID
+	if delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(uint64(zigzag(int64(id))))
+	}
+	w.lastID = id
+}
+
+func (w *compactWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (w *compactWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.varint(zigzag(int64(v)))
+}
+
+func (w *compactWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(zigzag(v))
+}
+
+func (w *compactWriter) string(id int16, v string) {
+	w.fieldHeader(id, thriftBinary)
+	w.rawString(v)
+}
+
+func (w *compactWriter) rawString(v string) {
+	w.varint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+// listBegin writes the header of a list field, the elements are written after it
+func (w *compactWriter) listBegin(id int16, elemType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		w.buf.WriteByte(0xf0 | elemType)
+		w.varint(uint64(size))
+	}
+}
+
+// structBegin starts a struct field, or a struct element of a list if id is 0
+func (w *compactWriter) structBegin(id int16) {
+	if id != 0 {
+		w.fieldHeader(id, thriftStruct)
+	}
+	w.stack = append(w.stack, w.lastID)
+	w.lastID = 0
+}
+
+func (w *compactWriter) structEnd() {
+	w.buf.WriteByte(0)
+	w.lastID = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+}
@@ -0,0 +1,334 @@
+// Package parquet writes flat parquet files of optional columns, with a
+// single uncompressed PLAIN encoded data page per column chunk.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Type is the physical type of a column.
+type Type int32
+
+const (
+	Boolean   Type = 0
+	Int64     Type = 2
+	Double    Type = 5
+	ByteArray Type = 6 // written as UTF8 strings
+)
+
+func (t Type) String() string {
+	switch t {
+	case Boolean:
+		return "boolean"
+	case Int64:
+		return "int64"
+	case Double:
+		return "double"
+	case ByteArray:
+		return "string"
+	}
+	return fmt.Sprintf("Type(%d)", int32(t))
+}
+
+// Column of a file, all columns are optional.
+type Column struct {
+	Name string
+	Type Type
+}
+
+const (
+	magic = "PAR1"
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	convertedUTF8  = 0
+	repetitionOpt  = 1
+	pageTypeData   = 0
+	codecNone      = 0
+	createdBy      = "aliyun-log-go-sdk"
+	defaultRowSize = 10000
+)
+
+// Writer writes rows to a parquet file, buffering them in memory until a row
+// group is flushed.
+type Writer struct {
+	// RowGroupSize is the number of rows of a row group, default 10000
+	RowGroupSize int
+
+	w         io.Writer
+	offset    int64
+	columns   []Column
+	chunks    []*columnBuffer
+	rows      int
+	numRows   int64
+	rowGroups []rowGroup
+	started   bool
+	closed    bool
+}
+
+type columnBuffer struct {
+	defs   []bool // whether the value of each row is defined
+	values bytes.Buffer
+	bools  []bool
+}
+
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+type rowGroup struct {
+	chunks []columnChunk
+	rows   int64
+	size   int64
+}
+
+// NewWriter returns a Writer of a file of columns to w, Close must be called to write the footer.
+func NewWriter(w io.Writer, columns []Column) (*Writer, error) {
+	// readers reject a schema without leaf columns
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("parquet: no columns")
+	}
+	seen := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		if c.Name == "" || seen[c.Name] {
+			return nil, fmt.Errorf("parquet: empty or duplicated column name %q", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	pw := &Writer{w: w, columns: columns}
+	pw.chunks = make([]*columnBuffer, len(columns))
+	for i := range pw.chunks {
+		pw.chunks[i] = &columnBuffer{}
+	}
+	return pw, nil
+}
+
+// Write appends a row, with a value per column: nil for null, or a bool,
+// int64, float64 or string according to the type of the column.
+func (pw *Writer) Write(row []interface{}) error {
+	if pw.closed {
+		return fmt.Errorf("parquet: writer is closed")
+	}
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("parquet: row has %d values, expected %d", len(row), len(pw.columns))
+	}
+	for i, v := range row {
+		if v == nil {
+			continue
+		}
+		if err := checkType(pw.columns[i], v); err != nil {
+			return err
+		}
+	}
+	for i, v := range row {
+		chunk := pw.chunks[i]
+		chunk.defs = append(chunk.defs, v != nil)
+		switch v := v.(type) {
+		case bool:
+			chunk.bools = append(chunk.bools, v)
+		case int64:
+			binary.Write(&chunk.values, binary.LittleEndian, v)
+		case float64:
+			binary.Write(&chunk.values, binary.LittleEndian, math.Float64bits(v))
+		case string:
+			binary.Write(&chunk.values, binary.LittleEndian, uint32(len(v)))
+			chunk.values.WriteString(v)
+		}
+	}
+	pw.rows++
+	rowGroupSize := pw.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = defaultRowSize
+	}
+	if pw.rows >= rowGroupSize {
+		return pw.Flush()
+	}
+	return nil
+}
+
+func checkType(c Column, v interface{}) error {
+	ok := false
+	switch v.(type) {
+	case bool:
+		ok = c.Type == Boolean
+	case int64:
+		ok = c.Type == Int64
+	case float64:
+		ok = c.Type == Double
+	case string:
+		ok = c.Type == ByteArray
+	}
+	if !ok {
+		return fmt.Errorf("parquet: value %v of type %T does not match column %s of type %s", v, v, c.Name, c.Type)
+	}
+	return nil
+}
+
+func (pw *Writer) write(data []byte) error {
+	n, err := pw.w.Write(data)
+	pw.offset += int64(n)
+	return err
+}
+
+// Flush writes the buffered rows as a row group.
+func (pw *Writer) Flush() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	if !pw.started {
+		if err := pw.write([]byte(magic)); err != nil {
+			return err
+		}
+		pw.started = true
+	}
+	group := rowGroup{rows: int64(pw.rows)}
+	for i, chunk := range pw.chunks {
+		var page bytes.Buffer
+		levels := encodeLevels(chunk.defs)
+		binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+		page.Write(levels)
+		if pw.columns[i].Type == Boolean {
+			page.Write(packBools(chunk.bools))
+		} else {
+			page.Write(chunk.values.Bytes())
+		}
+
+		var header compactWriter
+		header.structBegin(0)
+		header.i32(1, pageTypeData)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(page.Len()))
+		header.structBegin(5)
+		header.i32(1, int32(pw.rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.structEnd()
+		header.structEnd()
+
+		c := columnChunk{offset: pw.offset, size: int64(header.buf.Len() + page.Len())}
+		if err := pw.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := pw.write(page.Bytes()); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, c)
+		group.size += c.size
+		pw.chunks[i] = &columnBuffer{}
+	}
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.numRows += group.rows
+	pw.rows = 0
+	return nil
+}
+
+// Close flushes the buffered rows and writes the footer, it does not close the underlying writer.
+func (pw *Writer) Close() error {
+	if pw.closed {
+		return nil
+	}
+	if err := pw.Flush(); err != nil {
+		return err
+	}
+	pw.closed = true
+	if !pw.started {
+		if err := pw.write([]byte(magic)); err != nil {
+			return err
+		}
+	}
+
+	var meta compactWriter
+	meta.structBegin(0)
+	meta.i32(1, 1)
+	meta.listBegin(2, thriftStruct, len(pw.columns)+1)
+	meta.structBegin(0)
+	meta.string(4, "schema")
+	meta.i32(5, int32(len(pw.columns)))
+	meta.structEnd()
+	for _, c := range pw.columns {
+		meta.structBegin(0)
+		meta.i32(1, int32(c.Type))
+		meta.i32(3, repetitionOpt)
+		meta.string(4, c.Name)
+		if c.Type == ByteArray {
+			meta.i32(6, convertedUTF8)
+		}
+		meta.structEnd()
+	}
+	meta.i64(3, pw.numRows)
+	meta.listBegin(4, thriftStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		meta.structBegin(0)
+		meta.listBegin(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			meta.structBegin(0)
+			meta.i64(2, chunk.offset)
+			meta.structBegin(3)
+			meta.i32(1, int32(pw.columns[i].Type))
+			meta.listBegin(2, thriftI32, 2)
+			meta.varint(zigzag(encodingPlain))
+			meta.varint(zigzag(encodingRLE))
+			meta.listBegin(3, thriftBinary, 1)
+			meta.rawString(pw.columns[i].Name)
+			meta.i32(4, codecNone)
+			meta.i64(5, group.rows)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.structEnd()
+			meta.structEnd()
+		}
+		meta.i64(2, group.size)
+		meta.i64(3, group.rows)
+		meta.structEnd()
+	}
+	meta.string(6, createdBy)
+	meta.structEnd()
+
+	if err := pw.write(meta.buf.Bytes()); err != nil {
+		return err
+	}
+	var footer [8]byte
+	binary.LittleEndian.PutUint32(footer[:4], uint32(meta.buf.Len()))
+	copy(footer[4:], magic)
+	return pw.write(footer[:])
+}
+
+// encodeLevels encodes definition levels of max level 1 with the RLE hybrid encoding, as RLE runs only
+func encodeLevels(defs []bool) []byte {
+	var buf bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	for i := 0; i < len(defs); {
+		j := i
+		for j < len(defs) && defs[j] == defs[i] {
+			j++
+		}
+		n := binary.PutUvarint(tmp[:], uint64(j-i)<<1)
+		buf.Write(tmp[:n])
+		if defs[i] {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		i = j
+	}
+	return buf.Bytes()
+}
+
+// packBools encodes booleans with the PLAIN encoding, one bit per value, least significant bit first
+func packBools(values []bool) []byte {
+	packed := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return packed
+}
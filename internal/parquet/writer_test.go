@@ -0,0 +1,170 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compactReader decodes thrift compact structs into maps of field ids
+type compactReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *compactReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		r.pos += n
+		return string(r.data[r.pos-n : r.pos])
+	case thriftList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.structValue()
+	}
+	panic("unexpected type")
+}
+
+func (r *compactReader) structValue() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var id int16
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		if delta := header >> 4; delta != 0 {
+			id += int16(delta)
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	columns := []Column{{"n", Int64}, {"f", Double}, {"b", Boolean}, {"s", ByteArray}}
+	w, err := NewWriter(&buf, columns)
+	require.NoError(t, err)
+	w.RowGroupSize = 2
+	require.NoError(t, w.Write([]interface{}{int64(1), 0.5, true, "a"}))
+	require.NoError(t, w.Write([]interface{}{nil, nil, false, nil}))
+	require.NoError(t, w.Write([]interface{}{int64(-3), 2.0, nil, "ccc"}))
+	assert.Error(t, w.Write([]interface{}{"1", nil, nil, nil}))
+	assert.Error(t, w.Write([]interface{}{nil}))
+	require.NoError(t, w.Close())
+
+	data := buf.Bytes()
+	assert.Equal(t, magic, string(data[:4]))
+	assert.Equal(t, magic, string(data[len(data)-4:]))
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &compactReader{data: data[len(data)-8-size : len(data)-8]}
+	meta := footer.structValue()
+	assert.Equal(t, size, footer.pos)
+	assert.Equal(t, int64(3), meta[3])
+	assert.Equal(t, createdBy, meta[6])
+
+	schema := meta[2].([]interface{})
+	require.Len(t, schema, 5)
+	assert.Equal(t, int64(4), schema[0].(map[int16]interface{})[5])
+	assert.Equal(t, map[int16]interface{}{1: int64(ByteArray), 3: int64(repetitionOpt), 4: "s", 6: int64(convertedUTF8)}, schema[4])
+
+	groups := meta[4].([]interface{})
+	require.Len(t, groups, 2)
+	assert.Equal(t, int64(2), groups[0].(map[int16]interface{})[3])
+	assert.Equal(t, int64(1), groups[1].(map[int16]interface{})[3])
+
+	// the pages of the first row group
+	chunks := groups[0].(map[int16]interface{})[1].([]interface{})
+	require.Len(t, chunks, 4)
+	var pages [][]byte
+	for i, c := range chunks {
+		chunkMeta := c.(map[int16]interface{})[3].(map[int16]interface{})
+		assert.Equal(t, []interface{}{columns[i].Name}, chunkMeta[3])
+		assert.Equal(t, int64(2), chunkMeta[5])
+		page := &compactReader{data: data[chunkMeta[9].(int64):]}
+		header := page.structValue()
+		assert.Equal(t, int64(2), header[5].(map[int16]interface{})[1])
+		pageSize := int(header[3].(int64))
+		assert.Equal(t, chunkMeta[7], int64(page.pos+pageSize))
+		pages = append(pages, page.data[page.pos:page.pos+pageSize])
+	}
+	// levels of n: a run of 1 defined value and a run of 1 null
+	assert.Equal(t, []byte{4, 0, 0, 0, 2, 1, 2, 0}, pages[0][:8])
+	assert.Equal(t, int64(1), int64(binary.LittleEndian.Uint64(pages[0][8:])))
+	assert.Equal(t, 0.5, math.Float64frombits(binary.LittleEndian.Uint64(pages[1][8:])))
+	assert.Equal(t, []byte{2, 0, 0, 0, 4, 1, 1}, pages[2])
+	assert.Equal(t, []byte{1, 0, 0, 0, 'a'}, pages[3][8:])
+}
+
+func TestWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Column{{"a", Int64}})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, magic, string(buf.Bytes()[:4]))
+	_, err = NewWriter(&buf, nil)
+	assert.Error(t, err)
+	_, err = NewWriter(&buf, []Column{{"a", Int64}, {"a", Double}})
+	assert.Error(t, err)
+}
+
+var updateGolden = flag.Bool("update", false, "update testdata/golden.parquet")
+
+// TestWriterGolden compares the output with testdata/golden.parquet, whose content is
+// checked by testdata/verify_golden.py with pyarrow, run it after updating the file.
+func TestWriterGolden(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Column{{"n", Int64}, {"f", Double}, {"b", Boolean}, {"s", ByteArray}})
+	require.NoError(t, err)
+	w.RowGroupSize = 2
+	require.NoError(t, w.Write([]interface{}{int64(1), 0.5, true, "a"}))
+	require.NoError(t, w.Write([]interface{}{nil, nil, false, nil}))
+	require.NoError(t, w.Write([]interface{}{int64(-3), 2.0, nil, "日志"}))
+	require.NoError(t, w.Close())
+
+	golden := filepath.Join("testdata", "golden.parquet")
+	if *updateGolden {
+		require.NoError(t, ioutil.WriteFile(golden, buf.Bytes(), 0644))
+	}
+	expected, err := ioutil.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.Bytes())
+}
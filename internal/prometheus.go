@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MetricDesc describes a metric family.
+type MetricDesc struct {
+	Name       string
+	Help       string
+	Type       string // counter, gauge or summary
+	LabelNames []string
+}
+
+// MetricFamily is a group of samples sharing the same metric name,
+// it is written in the prometheus text exposition format.
+type MetricFamily struct {
+	Desc    *MetricDesc
+	Samples []MetricSample
+}
+
+// MetricSample is a single sample of a MetricFamily.
+type MetricSample struct {
+	LabelValues []string // in the order of Desc.LabelNames
+	Value       float64  // the sum of a summary
+	Count       uint64   // the count of a summary, unused by counters and gauges
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// WritePrometheusText writes families to w in the prometheus text exposition format.
+func WritePrometheusText(w io.Writer, families []MetricFamily) error {
+	bw := bufio.NewWriter(w)
+	writeSample := func(desc *MetricDesc, suffix string, labelValues []string, value float64) {
+		bw.WriteString(desc.Name + suffix)
+		if len(labelValues) > 0 {
+			bw.WriteByte('{')
+			for i, value := range labelValues {
+				if i > 0 {
+					bw.WriteByte(',')
+				}
+				bw.WriteString(desc.LabelNames[i] + `="` + labelValueEscaper.Replace(value) + `"`)
+			}
+			bw.WriteByte('}')
+		}
+		bw.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+	}
+	for _, family := range families {
+		desc := family.Desc
+		bw.WriteString("# HELP " + desc.Name + " " + desc.Help + "\n")
+		bw.WriteString("# TYPE " + desc.Name + " " + desc.Type + "\n")
+		for _, sample := range family.Samples {
+			if desc.Type == "summary" {
+				writeSample(desc, "_sum", sample.LabelValues, sample.Value)
+				writeSample(desc, "_count", sample.LabelValues, float64(sample.Count))
+			} else {
+				writeSample(desc, "", sample.LabelValues, sample.Value)
+			}
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,352 @@
+package sls
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogsResult is the result of a query, see DecodeLogs.
+type LogsResult interface {
+	// Rows returns the logs or the rows of the analytic SQL, as column to value maps
+	Rows() []map[string]string
+}
+
+func (resp *GetLogsResponse) Rows() []map[string]string   { return resp.Logs }
+func (resp *GetLogsV3Response) Rows() []map[string]string { return resp.Logs }
+
+// MissingFieldPolicy decides what LogDecoder does with struct fields whose
+// column is missing in a row.
+type MissingFieldPolicy int
+
+const (
+	// MissingFieldZero leaves the field with its zero value, nil for pointers
+	MissingFieldZero MissingFieldPolicy = iota
+	// MissingFieldError fails the decoding
+	MissingFieldError
+)
+
+// LogDecoder decodes the rows of query results into structs.
+//
+// Struct fields are mapped to columns with the sls tag, in the form
+// `sls:"column,option,..."`, untagged fields are mapped to the column of the
+// same name, case insensitively, and `sls:"-"` fields are ignored. Fields of
+// embedded structs are decoded as if they were fields of the outer struct.
+//
+// Values are converted to the type of the field: strings, bools, ints, uints,
+// floats, time.Time and pointers to them. Times are parsed from unix seconds,
+// such as __time__, RFC3339 or "2006-01-02 15:04:05.999". The value null
+// decodes to nil pointers and zero values, except for strings.
+//
+// Options of the tag:
+//   - required, optional: override MissingField for the field
+//   - json: the value is decoded as json into the field, whatever its type
+//   - ms: times are parsed from unix milliseconds instead of seconds
+//   - int, float, bool, string, time: the expected type, checked against the field type
+type LogDecoder struct {
+	MissingField MissingFieldPolicy
+	// Location of times without time zone, default UTC
+	Location *time.Location
+}
+
+// DecodeError is the error of a value that can not be decoded.
+type DecodeError struct {
+	Row    int
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode logs: row %d, column %q, value %q: %v", e.Row, e.Column, e.Value, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeLogs decodes the rows of result into out, a pointer to a slice of
+// structs or of pointers to structs, with the default LogDecoder.
+//
+//	var rows []struct {
+//		Time   time.Time `sls:"__time__"`
+//		Status int       `sls:"status,required"`
+//		Count  int64     `sls:"cnt"`
+//	}
+//	err := sls.DecodeLogs(resp, &rows)
+func DecodeLogs(result LogsResult, out interface{}) error {
+	return (&LogDecoder{}).Decode(result, out)
+}
+
+// Decode decodes the rows of result into out, a pointer to a slice of
+// structs or of pointers to structs.
+func (d *LogDecoder) Decode(result LogsResult, out interface{}) error {
+	return d.DecodeRows(result.Rows(), out)
+}
+
+// DecodeRows is like Decode for rows that are not returned as a LogsResult.
+func (d *LogDecoder) DecodeRows(rows []map[string]string, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("decode logs: out must be a non nil pointer to a slice, got %T", out)
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+	fields, err := structFieldsOf(structType)
+	if err != nil {
+		return err
+	}
+	result := reflect.MakeSlice(slice.Type(), len(rows), len(rows))
+	for i, row := range rows {
+		elem := result.Index(i)
+		if elemType.Kind() == reflect.Ptr {
+			elem.Set(reflect.New(structType))
+			elem = elem.Elem()
+		}
+		if err := d.decodeRow(i, row, fields, elem); err != nil {
+			return err
+		}
+	}
+	slice.Set(result)
+	return nil
+}
+
+// DecodeRow decodes a single row into out, a pointer to a struct.
+func (d *LogDecoder) DecodeRow(row map[string]string, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("decode logs: out must be a non nil pointer to a struct, got %T", out)
+	}
+	fields, err := structFieldsOf(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+	return d.decodeRow(0, row, fields, v.Elem())
+}
+
+// a struct field mapped to a column
+type columnField struct {
+	index    []int
+	column   string
+	byName   bool // untagged, the column is matched case insensitively
+	typeHint string
+	required *bool
+	json     bool
+	millis   bool
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func structFieldsOf(t reflect.Type) ([]*columnField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("decode logs: cannot decode into %s, it is not a struct", t)
+	}
+	var fields []*columnField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("sls")
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			embedded, err := structFieldsOf(sf.Type)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range embedded {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if sf.PkgPath != "" { // unexported
+			continue
+		}
+		f := &columnField{index: []int{i}}
+		parts := strings.Split(tag, ",")
+		f.column = parts[0]
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "required", "optional":
+				required := opt == "required"
+				f.required = &required
+			case "json":
+				f.json = true
+			case "ms":
+				f.millis = true
+			case "int", "float", "bool", "string", "time":
+				f.typeHint = opt
+			case "":
+			default:
+				return nil, fmt.Errorf("decode logs: unknown option %q of field %s.%s", opt, t, sf.Name)
+			}
+		}
+		if f.typeHint != "" && !f.json && !hintMatches(f.typeHint, sf.Type) {
+			return nil, fmt.Errorf("decode logs: field %s.%s of type %s is not %s", t, sf.Name, sf.Type, f.typeHint)
+		}
+		if f.column == "" {
+			f.column, f.byName = sf.Name, true
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func hintMatches(hint string, t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch hint {
+	case "int":
+		return t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64
+	case "float":
+		return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+	case "bool":
+		return t.Kind() == reflect.Bool
+	case "string":
+		return t.Kind() == reflect.String
+	case "time":
+		return t == timeType
+	}
+	return false
+}
+
+func (d *LogDecoder) decodeRow(rowIndex int, row map[string]string, fields []*columnField, out reflect.Value) error {
+	for _, f := range fields {
+		column := f.column
+		value, ok := row[column]
+		if !ok && f.byName {
+			for k, v := range row {
+				if strings.EqualFold(k, column) {
+					column, value, ok = k, v, true
+					break
+				}
+			}
+		}
+		if !ok {
+			required := d.MissingField == MissingFieldError
+			if f.required != nil {
+				required = *f.required
+			}
+			if required {
+				return &DecodeError{Row: rowIndex, Column: column, Err: fmt.Errorf("missing column")}
+			}
+			continue
+		}
+		if err := d.setValue(out.FieldByIndex(f.index), value, f); err != nil {
+			return &DecodeError{Row: rowIndex, Column: column, Value: value, Err: err}
+		}
+	}
+	return nil
+}
+
+func (d *LogDecoder) setValue(v reflect.Value, value string, f *columnField) error {
+	if f.json {
+		ptr := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(value), ptr.Interface()); err != nil {
+			return err
+		}
+		v.Set(ptr.Elem())
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if value == "null" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		ptr := reflect.New(v.Type().Elem())
+		if err := d.setValue(ptr.Elem(), value, f); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if v.Kind() != reflect.String && (value == "null" || value == "") {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Type() == timeType {
+		t, err := d.parseTime(value, f.millis)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			// analytic results may render integers as floats, such as 3.0
+			fv, ferr := strconv.ParseFloat(value, 64)
+			if ferr != nil || fv != math.Trunc(fv) || fv < math.MinInt64 || fv > math.MaxInt64 {
+				return err
+			}
+			n = int64(fv)
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("overflows %s", v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			fv, ferr := strconv.ParseFloat(value, 64)
+			if ferr != nil || fv != math.Trunc(fv) || fv < 0 || fv > math.MaxUint64 {
+				return err
+			}
+			n = uint64(fv)
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("overflows %s", v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s, use the json option", v.Type())
+	}
+	return nil
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+func (d *LogDecoder) parseTime(value string, millis bool) (time.Time, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if millis {
+			return time.UnixMilli(n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+	loc := d.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time")
+}
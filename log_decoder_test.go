@@ -0,0 +1,89 @@
+package sls
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decodeBase struct {
+	Time time.Time `sls:"__time__"`
+}
+
+type decodeRow struct {
+	decodeBase
+	Status  int               `sls:"status,int,required"`
+	Latency float64           `sls:"latency"`
+	Bytes   *uint32           `sls:"bytes"`
+	Cached  bool              `sls:"cached"`
+	Path    string            // matched by name
+	Day     time.Time         `sls:"day"`
+	Labels  map[string]string `sls:"labels,json"`
+	Ignored string            `sls:"-"`
+}
+
+func TestDecodeLogs(t *testing.T) {
+	resp := &GetLogsV3Response{Logs: []map[string]string{
+		{"__time__": "1700000000", "status": "200", "latency": "0.25", "bytes": "1024", "cached": "true",
+			"path": "/index", "day": "2023-11-14 22:13:20.000", "labels": `{"env":"prod"}`, "Ignored": "x"},
+		{"__time__": "1700000060", "status": "504.0", "latency": "null", "bytes": "null"},
+	}}
+	var rows []decodeRow
+	require.NoError(t, DecodeLogs(resp, &rows))
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(1700000000), rows[0].Time.Unix())
+	assert.Equal(t, 200, rows[0].Status)
+	assert.Equal(t, 0.25, rows[0].Latency)
+	require.NotNil(t, rows[0].Bytes)
+	assert.Equal(t, uint32(1024), *rows[0].Bytes)
+	assert.True(t, rows[0].Cached)
+	assert.Equal(t, "/index", rows[0].Path)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), rows[0].Day)
+	assert.Equal(t, map[string]string{"env": "prod"}, rows[0].Labels)
+	assert.Empty(t, rows[0].Ignored)
+	assert.Equal(t, 504, rows[1].Status)
+	assert.Equal(t, 0.0, rows[1].Latency)
+	assert.Nil(t, rows[1].Bytes)
+
+	var ptrs []*decodeRow
+	require.NoError(t, DecodeLogs(&GetLogsResponse{Logs: resp.Logs}, &ptrs))
+	assert.Equal(t, rows[1], *ptrs[1])
+}
+
+func TestDecodeLogsErrors(t *testing.T) {
+	var rows []decodeRow
+	err := DecodeLogs(&GetLogsV3Response{Logs: []map[string]string{{"status": "200"}, {"latency": "1"}}}, &rows)
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr), err)
+	assert.Equal(t, 1, decodeErr.Row)
+	assert.Equal(t, "status", decodeErr.Column)
+
+	err = DecodeLogs(&GetLogsV3Response{Logs: []map[string]string{{"status": "200"}, {"status": "1.5"}}}, &rows)
+	require.True(t, errors.As(err, &decodeErr), err)
+	assert.Equal(t, 1, decodeErr.Row)
+	assert.Equal(t, "1.5", decodeErr.Value)
+	assert.True(t, errors.Is(err, strconv.ErrSyntax))
+
+	var small []struct {
+		N int8 `sls:"n"`
+	}
+	assert.Error(t, DecodeLogs(&GetLogsV3Response{Logs: []map[string]string{{"n": "300"}}}, &small))
+
+	var mistyped []struct {
+		N string `sls:"n,int"`
+	}
+	assert.Error(t, DecodeLogs(&GetLogsV3Response{}, &mistyped))
+	assert.Error(t, DecodeLogs(&GetLogsV3Response{}, rows))
+
+	strict := &LogDecoder{MissingField: MissingFieldError}
+	var optional struct {
+		A string `sls:"a"`
+		B string `sls:"b,optional"`
+	}
+	require.NoError(t, strict.DecodeRow(map[string]string{"a": "1"}, &optional))
+	assert.Error(t, strict.DecodeRow(map[string]string{"b": "1"}, &optional))
+}
@@ -0,0 +1,370 @@
+package sls
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// LogTimeKey is the key of the struct field holding the time of a log, see EncodeLog.
+const LogTimeKey = "__time__"
+
+// logPlans caches the *logPlan of struct types
+var logPlans sync.Map
+
+// logPlan maps the fields of a struct type to the keys of a log
+type logPlan struct {
+	fields []*logField
+	byKey  map[string]*logField
+	time   *logField
+}
+
+type logField struct {
+	index     []int
+	key       string
+	omitempty bool
+	json      bool
+	millis    bool
+}
+
+// EncodeLog encodes v, a struct or a pointer to a struct, into a log.
+//
+// Struct fields are mapped to keys with the sls tag, in the form
+// `sls:"key,option,..."`, untagged fields are mapped to the key of the field
+// name, and `sls:"-"` fields are ignored, as for LogDecoder. Fields of embedded
+// structs are encoded as if they were fields of the outer struct.
+//
+// The field of key __time__, a time.Time or an integer of unix seconds, sets
+// the Time and TimeNs of the log instead of a content, logs without it or with
+// a zero time get the current time.
+//
+// Values are formatted from strings, bools, ints, uints, floats, time.Time and
+// pointers to them, nil pointers are omitted. Times are formatted as RFC3339
+// with nanoseconds. Other types, such as nested structs, maps and slices, are
+// encoded as json.
+//
+//	type Access struct {
+//		Time    time.Time         `sls:"__time__"`
+//		Status  int               `sls:"status"`
+//		Latency float64           `sls:"latency,omitempty"`
+//		Client  Peer              `sls:"client,flatten"` // client.ip, client.port
+//		Labels  map[string]string `sls:"labels"`         // json
+//	}
+//	log, err := sls.EncodeLog(&Access{Time: time.Now(), Status: 200})
+//
+// Options of the tag:
+//   - omitempty: zero values are omitted
+//   - json: the value is encoded as json, whatever its type
+//   - ms: times are formatted as unix milliseconds
+//   - flatten: the fields of a nested struct are keys of the log, prefixed with the key of the field and a dot
+func EncodeLog(v interface{}) (*Log, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("encode log: nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	plan, err := logPlanOf(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	return plan.encode(rv)
+}
+
+// EncodeLogGroup encodes values, a slice of structs or of pointers to structs,
+// into a log group of topic and source, see EncodeLog.
+func EncodeLogGroup(topic, source string, values interface{}) (*LogGroup, error) {
+	rv := reflect.ValueOf(values)
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("encode log: values must be a slice, got %T", values)
+	}
+	group := &LogGroup{Topic: proto.String(topic), Source: proto.String(source)}
+	for i := 0; i < rv.Len(); i++ {
+		log, err := EncodeLog(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		group.Logs = append(group.Logs, log)
+	}
+	return group, nil
+}
+
+// DecodeLog decodes log into out, a pointer to a struct, the reverse of
+// EncodeLog. Keys without field are ignored, fields without key are left
+// unchanged.
+func DecodeLog(log *Log, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("decode log: out must be a non nil pointer to a struct, got %T", out)
+	}
+	plan, err := logPlanOf(rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+	return plan.decode(log, rv.Elem())
+}
+
+// DecodeLogGroup decodes the logs of group into out, a pointer to a slice of
+// structs or of pointers to structs, see DecodeLog.
+func DecodeLogGroup(group *LogGroup, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("decode log: out must be a non nil pointer to a slice, got %T", out)
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+	plan, err := logPlanOf(structType)
+	if err != nil {
+		return err
+	}
+	result := reflect.MakeSlice(slice.Type(), len(group.Logs), len(group.Logs))
+	for i, log := range group.Logs {
+		elem := result.Index(i)
+		if elemType.Kind() == reflect.Ptr {
+			elem.Set(reflect.New(structType))
+			elem = elem.Elem()
+		}
+		if err := plan.decode(log, elem); err != nil {
+			return fmt.Errorf("log %d: %w", i, err)
+		}
+	}
+	slice.Set(result)
+	return nil
+}
+
+func logPlanOf(t reflect.Type) (*logPlan, error) {
+	if plan, ok := logPlans.Load(t); ok {
+		return plan.(*logPlan), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("encode log: %s is not a struct", t)
+	}
+	fields, err := logFieldsOf(t, "")
+	if err != nil {
+		return nil, err
+	}
+	plan := &logPlan{byKey: make(map[string]*logField, len(fields))}
+	for _, f := range fields {
+		if _, ok := plan.byKey[f.key]; ok {
+			return nil, fmt.Errorf("encode log: duplicated key %q in %s", f.key, t)
+		}
+		plan.byKey[f.key] = f
+		if f.key == LogTimeKey {
+			ft := t.FieldByIndex(f.index).Type
+			if ft != timeType && !(ft.Kind() >= reflect.Int && ft.Kind() <= reflect.Uint64) {
+				return nil, fmt.Errorf("encode log: field %s of %s must be a time.Time or an integer", LogTimeKey, t)
+			}
+			plan.time = f
+			continue
+		}
+		plan.fields = append(plan.fields, f)
+	}
+	actual, _ := logPlans.LoadOrStore(t, plan)
+	return actual.(*logPlan), nil
+}
+
+// logFieldsOf returns the fields of t, with keys prefixed with prefix
+func logFieldsOf(t reflect.Type, prefix string) ([]*logField, error) {
+	var fields []*logField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("sls")
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			embedded, err := logFieldsOf(sf.Type, prefix)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, withIndex(i, embedded)...)
+			continue
+		}
+		if sf.PkgPath != "" { // unexported
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		f := &logField{index: []int{i}, key: parts[0]}
+		if !hasTag {
+			f.key = sf.Name
+		}
+		flatten := false
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "omitempty":
+				f.omitempty = true
+			case "json":
+				f.json = true
+			case "ms":
+				f.millis = true
+			case "flatten":
+				flatten = true
+			case "":
+			default:
+				return nil, fmt.Errorf("encode log: unknown option %q of field %s.%s", opt, t, sf.Name)
+			}
+		}
+		if flatten {
+			if sf.Type.Kind() != reflect.Struct || sf.Type == timeType {
+				return nil, fmt.Errorf("encode log: field %s.%s of type %s can not be flattened", t, sf.Name, sf.Type)
+			}
+			nestedPrefix := prefix
+			if f.key != "" {
+				nestedPrefix += f.key + "."
+			}
+			nested, err := logFieldsOf(sf.Type, nestedPrefix)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, withIndex(i, nested)...)
+			continue
+		}
+		if f.key == "" {
+			f.key = sf.Name
+		}
+		f.key = prefix + f.key
+		if !f.json && !isScalar(sf.Type) {
+			f.json = true
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func withIndex(i int, fields []*logField) []*logField {
+	for _, f := range fields {
+		f.index = append([]int{i}, f.index...)
+	}
+	return fields
+}
+
+// isScalar returns whether values of t are formatted without json
+func isScalar(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func (p *logPlan) encode(v reflect.Value) (*Log, error) {
+	log := &Log{Contents: make([]*LogContent, 0, len(p.fields))}
+	if err := p.encodeTime(log, v); err != nil {
+		return nil, err
+	}
+	for _, f := range p.fields {
+		fv := v.FieldByIndex(f.index)
+		if f.omitempty && fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Ptr && !f.json {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		value, err := formatValue(fv, f)
+		if err != nil {
+			return nil, fmt.Errorf("encode log: key %q: %w", f.key, err)
+		}
+		log.Contents = append(log.Contents, &LogContent{Key: proto.String(f.key), Value: proto.String(value)})
+	}
+	return log, nil
+}
+
+func (p *logPlan) encodeTime(log *Log, v reflect.Value) error {
+	t := time.Now()
+	if p.time != nil {
+		fv := v.FieldByIndex(p.time.index)
+		switch {
+		case fv.Type() == timeType:
+			if ft := fv.Interface().(time.Time); !ft.IsZero() {
+				t = ft
+			}
+		case fv.CanInt() && fv.Int() != 0:
+			t = time.Unix(fv.Int(), 0)
+		case fv.CanUint() && fv.Uint() != 0:
+			t = time.Unix(int64(fv.Uint()), 0)
+		}
+	}
+	if t.Unix() < 0 || t.Unix() > 1<<32-1 {
+		return fmt.Errorf("encode log: time %s out of range", t)
+	}
+	log.Time = proto.Uint32(uint32(t.Unix()))
+	if ns := t.Nanosecond(); ns != 0 {
+		log.TimeNs = proto.Uint32(uint32(ns))
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value, f *logField) (string, error) {
+	if f.json {
+		b, err := json.Marshal(v.Interface())
+		return string(b), err
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if f.millis {
+			return strconv.FormatInt(t.UnixMilli(), 10), nil
+		}
+		return t.Format(time.RFC3339Nano), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func (p *logPlan) decode(log *Log, v reflect.Value) error {
+	if p.time != nil {
+		fv := v.FieldByIndex(p.time.index)
+		switch {
+		case fv.Type() == timeType:
+			fv.Set(reflect.ValueOf(time.Unix(int64(log.GetTime()), int64(log.GetTimeNs()))))
+		case fv.CanInt():
+			fv.SetInt(int64(log.GetTime()))
+		default:
+			fv.SetUint(uint64(log.GetTime()))
+		}
+	}
+	decoder := &LogDecoder{}
+	for _, content := range log.Contents {
+		f, ok := p.byKey[content.GetKey()]
+		if !ok || f == p.time {
+			continue
+		}
+		if err := decoder.setValue(v.FieldByIndex(f.index), content.GetValue(), &columnField{json: f.json, millis: f.millis}); err != nil {
+			return fmt.Errorf("decode log: key %q, value %q: %w", f.key, content.GetValue(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package sls
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type encodePeer struct {
+	IP   string `sls:"ip"`
+	Port uint16 `sls:"port,omitempty"`
+}
+
+type encodeBase struct {
+	Time time.Time `sls:"__time__"`
+}
+
+type encodeRow struct {
+	encodeBase
+	Status  int               `sls:"status"`
+	Latency float64           `sls:"latency"`
+	Cached  bool              `sls:"cached"`
+	Bytes   *int64            `sls:"bytes"`
+	Path    string            // key of the field name
+	Error   string            `sls:"error,omitempty"`
+	Day     time.Time         `sls:"day,ms"`
+	Client  encodePeer        `sls:"client,flatten"`
+	Server  encodePeer        `sls:"server"`
+	Labels  map[string]string `sls:"labels"`
+	Ignored string            `sls:"-"`
+}
+
+func logContents(log *Log) map[string]string {
+	contents := map[string]string{}
+	for _, c := range log.Contents {
+		contents[c.GetKey()] = c.GetValue()
+	}
+	return contents
+}
+
+func TestEncodeLog(t *testing.T) {
+	ts := time.Unix(1700000000, 123)
+	row := &encodeRow{
+		encodeBase: encodeBase{Time: ts},
+		Status:     200,
+		Latency:    0.25,
+		Cached:     true,
+		Path:       "/index",
+		Day:        time.UnixMilli(1700000000500),
+		Client:     encodePeer{IP: "10.0.0.1", Port: 80},
+		Server:     encodePeer{IP: "10.0.0.2"},
+		Labels:     map[string]string{"env": "prod"},
+		Ignored:    "x",
+	}
+	log, err := EncodeLog(row)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1700000000), log.GetTime())
+	assert.Equal(t, uint32(123), log.GetTimeNs())
+	assert.Equal(t, map[string]string{
+		"status":    "200",
+		"latency":   "0.25",
+		"cached":    "true",
+		"Path":      "/index",
+		"day":       "1700000000500",
+		"client.ip": "10.0.0.1", "client.port": "80",
+		"server": `{"IP":"10.0.0.2","Port":0}`,
+		"labels": `{"env":"prod"}`,
+	}, logContents(log))
+
+	var decoded encodeRow
+	require.NoError(t, DecodeLog(log, &decoded))
+	assert.True(t, decoded.Time.Equal(ts))
+	assert.True(t, decoded.Day.Equal(row.Day))
+	decoded.Time, decoded.Day = row.Time, row.Day
+	row.Ignored = ""
+	assert.Equal(t, *row, decoded)
+
+	bytes := int64(10)
+	group, err := EncodeLogGroup("topic", "source", []encodeRow{{Bytes: &bytes, Error: "e"}, {}})
+	require.NoError(t, err)
+	assert.Equal(t, "topic", group.GetTopic())
+	require.Len(t, group.Logs, 2)
+	assert.Equal(t, "10", logContents(group.Logs[0])["bytes"])
+	assert.Equal(t, "e", logContents(group.Logs[0])["error"])
+	assert.NotContains(t, logContents(group.Logs[1]), "bytes")
+	assert.NotZero(t, group.Logs[1].GetTime())
+
+	var rows []*encodeRow
+	require.NoError(t, DecodeLogGroup(group, &rows))
+	require.Len(t, rows, 2)
+	assert.Equal(t, int64(10), *rows[0].Bytes)
+	assert.Nil(t, rows[1].Bytes)
+}
+
+func TestEncodeLogErrors(t *testing.T) {
+	_, err := EncodeLog(1)
+	assert.Error(t, err)
+	_, err = EncodeLog((*encodeRow)(nil))
+	assert.Error(t, err)
+	_, err = EncodeLog(struct {
+		A string `sls:"a"`
+		B string `sls:"a"`
+	}{})
+	assert.Error(t, err)
+	_, err = EncodeLog(struct {
+		Time string `sls:"__time__"`
+	}{})
+	assert.Error(t, err)
+	_, err = EncodeLog(struct {
+		A string `sls:"a,flatten"`
+	}{})
+	assert.Error(t, err)
+
+	var seconds struct {
+		Time uint32 `sls:"__time__"`
+		N    int8   `sls:"n"`
+	}
+	log := &Log{Time: proto.Uint32(1700000000), Contents: []*LogContent{
+		{Key: proto.String("n"), Value: proto.String("300")},
+	}}
+	assert.Error(t, DecodeLog(log, &seconds))
+	assert.Equal(t, uint32(1700000000), seconds.Time)
+}
@@ -0,0 +1,108 @@
+package sls
+
+import (
+	"sync"
+)
+
+// logGroupBuffers pools the buffers of released LogGroupEncoders
+var logGroupBuffers sync.Pool
+
+// LogGroupEncoder encodes a LogGroup log by log into its protobuf wire format,
+// so that the logs need not be held until the whole group is marshaled. The
+// bytes are the ones proto.Marshal returns for the LogGroup of the logs.
+//
+//	enc := sls.NewLogGroupEncoder("topic", "source", nil)
+//	defer enc.Release()
+//	for _, log := range logs {
+//		if _, err := enc.AppendLog(log); err != nil {
+//			return err
+//		}
+//	}
+//	err := client.PostLogStoreLogsV2(project, logstore, &sls.PostLogStoreLogsRequest{RawLogGroup: enc.Bytes()})
+type LogGroupEncoder struct {
+	buf    []byte
+	header []byte // topic, source and tags, appended after the logs
+	sealed bool
+	logs   int
+}
+
+// NewLogGroupEncoder returns an encoder of a LogGroup of topic, source and tags, with a buffer from a pool.
+func NewLogGroupEncoder(topic, source string, tags []*LogTag) *LogGroupEncoder {
+	header := &LogGroup{Topic: &topic, Source: &source, LogTags: tags}
+	e := &LogGroupEncoder{}
+	e.header, _ = header.Marshal() // without logs, no required field can be missing
+	if buf, ok := logGroupBuffers.Get().(*[]byte); ok {
+		e.buf = (*buf)[:0]
+	}
+	return e
+}
+
+// AppendLog encodes log and returns the number of bytes it takes in the group.
+func (e *LogGroupEncoder) AppendLog(log *Log) (int, error) {
+	size := log.Size()
+	n := 1 + sovLog(uint64(size)) + size
+	start := len(e.buf)
+	if cap(e.buf)-start < n {
+		grown := make([]byte, start, 2*cap(e.buf)+n)
+		copy(grown, e.buf)
+		e.buf = grown
+	}
+	e.buf = e.buf[:start+n]
+	e.buf[start] = 0x0a // field 1 of LogGroup, length delimited
+	encodeVarintLog(e.buf[:start+n-size], start+n-size, uint64(size))
+	if _, err := log.MarshalToSizedBuffer(e.buf[start+n-size:]); err != nil {
+		e.buf = e.buf[:start]
+		return 0, err
+	}
+	e.logs++
+	return n, nil
+}
+
+// AppendLogs encodes logs and returns the number of bytes they take in the
+// group, no log is encoded if one of them fails.
+func (e *LogGroupEncoder) AppendLogs(logs []*Log) (int, error) {
+	start, count := len(e.buf), e.logs
+	total := 0
+	for _, log := range logs {
+		n, err := e.AppendLog(log)
+		if err != nil {
+			e.buf, e.logs = e.buf[:start], count
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Len returns the number of logs encoded.
+func (e *LogGroupEncoder) Len() int {
+	return e.logs
+}
+
+// Size returns the size of the encoded LogGroup.
+func (e *LogGroupEncoder) Size() int {
+	if e.sealed {
+		return len(e.buf)
+	}
+	return len(e.buf) + len(e.header)
+}
+
+// Bytes returns the encoded LogGroup, which is valid until Release is called.
+func (e *LogGroupEncoder) Bytes() []byte {
+	if !e.sealed {
+		// logs appended afterwards follow the header, protobuf does not mind
+		e.buf = append(e.buf, e.header...)
+		e.sealed = true
+	}
+	return e.buf
+}
+
+// Release returns the buffer of the encoder to the pool, the encoder must not be used afterwards.
+func (e *LogGroupEncoder) Release() {
+	if e.buf == nil {
+		return
+	}
+	buf := e.buf
+	e.buf, e.header = nil, nil
+	logGroupBuffers.Put(&buf)
+}
@@ -0,0 +1,70 @@
+package sls
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogGroupEncoder(t *testing.T) {
+	gl := testLogGroupList()
+	for _, group := range gl.LogGroups {
+		enc := NewLogGroupEncoder(group.GetTopic(), group.GetSource(), group.LogTags)
+		total := 0
+		for _, log := range group.Logs {
+			n, err := enc.AppendLog(log)
+			require.NoError(t, err)
+			total += n
+		}
+		expected, err := proto.Marshal(group)
+		require.NoError(t, err)
+		assert.Equal(t, len(group.Logs), enc.Len())
+		assert.Equal(t, len(expected), enc.Size())
+		assert.Equal(t, expected, enc.Bytes())
+		assert.Equal(t, len(expected)-len(enc.header), total)
+
+		// logs appended after Bytes are still decoded in order
+		_, err = enc.AppendLog(group.Logs[0])
+		require.NoError(t, err)
+		decoded := &LogGroup{}
+		require.NoError(t, proto.Unmarshal(enc.Bytes(), decoded))
+		assert.Len(t, decoded.Logs, 3)
+		assert.Equal(t, group.Logs[0].String(), decoded.Logs[2].String())
+		enc.Release()
+	}
+
+	enc := NewLogGroupEncoder("", "", nil)
+	defer enc.Release()
+	_, err := enc.AppendLogs([]*Log{gl.LogGroups[0].Logs[0], {Contents: gl.LogGroups[0].Logs[0].Contents}})
+	assert.Error(t, err)
+	assert.Equal(t, 0, enc.Len())
+	n, err := enc.AppendLogs(gl.LogGroups[0].Logs)
+	require.NoError(t, err)
+	assert.Equal(t, 2, enc.Len())
+	assert.Equal(t, n+len(enc.header), enc.Size())
+}
+
+func TestPostRawLogGroup(t *testing.T) {
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+	client := CreateNormalInterfaceV2(ts.URL, NewStaticCredentialsProvider("id", "key", ""))
+
+	group := testLogGroupList().LogGroups[0]
+	enc := NewLogGroupEncoder(group.GetTopic(), group.GetSource(), group.LogTags)
+	defer enc.Release()
+	_, err := enc.AppendLogs(group.Logs)
+	require.NoError(t, err)
+	require.NoError(t, client.PostLogStoreLogsV2("", "logstore", &PostLogStoreLogsRequest{
+		RawLogGroup:  enc.Bytes(),
+		CompressType: Compress_None,
+	}))
+	assert.Equal(t, enc.Bytes(), body)
+}
@@ -0,0 +1,431 @@
+package sls
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var errInvalidProto = errors.New("invalid protobuf data")
+
+// LogGroupListView is a read only view of a serialized LogGroupList, such as
+// the data returned by GetLogsBytesWithQuery, which decodes the log groups,
+// logs and contents lazily as they are iterated, unlike LogsBytesDecode.
+//
+// Byte slices returned by views alias the data of the view, they must not be
+// modified, and must be copied to be kept after the data is reused. Iterators
+// stop at malformed data, Err returns the error then.
+//
+//	view := sls.NewLogGroupListView(data, plm)
+//	groups := view.Groups()
+//	for groups.Next() {
+//		group := groups.Group()
+//		if string(group.Topic()) != "access" {
+//			continue // the logs of the group are not decoded
+//		}
+//		logs := group.Logs()
+//		for logs.Next() {
+//			if status, ok := logs.Log().Get("status"); ok && string(status) == "500" {
+//				...
+//			}
+//		}
+//		if err := logs.Err(); err != nil {
+//			return err
+//		}
+//	}
+//	if err := groups.Err(); err != nil {
+//		return err
+//	}
+type LogGroupListView struct {
+	data        []byte
+	firstCursor int64
+	hasCursor   bool
+	n           int // number of log groups, -1 until counted
+}
+
+// NewLogGroupListView returns a view of data, a serialized LogGroupList.
+//
+// plm, the meta returned with data by GetLogsBytesWithQuery, sets the cursors
+// of the log groups like PullLogsWithQuery does, it may be nil.
+func NewLogGroupListView(data []byte, plm *PullLogMeta) *LogGroupListView {
+	v := &LogGroupListView{data: data, n: -1}
+	if plm != nil && plm.Count > 0 && plm.readLastCursor != "" && !plm.hasQuery {
+		if last, err := strconv.ParseInt(plm.readLastCursor, 10, 64); err == nil {
+			if n, err := v.Len(); err == nil {
+				v.firstCursor, v.hasCursor, v.n = last-int64(n)+1, true, n
+			}
+		}
+	}
+	return v
+}
+
+// ParseLogGroupListView is like NewLogGroupListView, it also checks that the
+// log groups of data are well framed, without decoding them.
+func ParseLogGroupListView(data []byte, plm *PullLogMeta) (*LogGroupListView, error) {
+	v := NewLogGroupListView(data, plm)
+	n, err := v.Len()
+	if err != nil {
+		return nil, err
+	}
+	v.n = n
+	return v, nil
+}
+
+// Bytes returns the data of the view.
+func (v *LogGroupListView) Bytes() []byte {
+	return v.data
+}
+
+// Len returns the number of log groups, without decoding them.
+func (v *LogGroupListView) Len() (int, error) {
+	if v.n >= 0 {
+		return v.n, nil
+	}
+	n := 0
+	groups := v.Groups()
+	for groups.Next() {
+		n++
+	}
+	return n, groups.Err()
+}
+
+// Groups returns an iterator over the log groups.
+func (v *LogGroupListView) Groups() LogGroupViewIterator {
+	return LogGroupViewIterator{fields: protoFields{data: v.data}, list: v}
+}
+
+// Decode decodes the whole view into a LogGroupList, as PullLogsWithQuery would return it.
+func (v *LogGroupListView) Decode() (*LogGroupList, error) {
+	gl, err := LogsBytesDecode(v.data)
+	if err != nil {
+		return nil, err
+	}
+	if v.hasCursor {
+		for i, group := range gl.LogGroups {
+			group.cursor = encodeCursor(v.firstCursor + int64(i))
+		}
+	}
+	return gl, nil
+}
+
+// LogGroupViewIterator iterates over the log groups of a LogGroupListView.
+type LogGroupViewIterator struct {
+	fields protoFields
+	list   *LogGroupListView
+	group  LogGroupView
+	index  int
+}
+
+// Next moves to the next log group, it returns false after the last one or on error.
+func (it *LogGroupViewIterator) Next() bool {
+	for it.fields.next() {
+		if it.fields.field.num != 1 {
+			continue
+		}
+		if !it.fields.expect(protoBytes) {
+			return false
+		}
+		it.group = LogGroupView{data: it.fields.field.bytes}
+		if it.list.hasCursor {
+			it.group.cursor = encodeCursor(it.list.firstCursor + int64(it.index))
+		}
+		it.index++
+		return true
+	}
+	return false
+}
+
+// Group returns the current log group.
+func (it *LogGroupViewIterator) Group() LogGroupView {
+	return it.group
+}
+
+// Err returns the error that stopped the iterator, nil if it walked all log groups.
+func (it *LogGroupViewIterator) Err() error {
+	return it.fields.err
+}
+
+// LogGroupView is a view of a serialized LogGroup.
+type LogGroupView struct {
+	data   []byte
+	cursor string
+}
+
+// Bytes returns the serialized log group.
+func (g LogGroupView) Bytes() []byte {
+	return g.data
+}
+
+// Cursor returns the cursor of the log group, empty if it is unknown, see LogGroup.GetCursor.
+func (g LogGroupView) Cursor() string {
+	return g.cursor
+}
+
+// Category, Topic, Source and MachineUUID return the fields of the log group, nil if they are not set.
+func (g LogGroupView) Category() []byte    { return findProtoBytes(g.data, 2) }
+func (g LogGroupView) Topic() []byte       { return findProtoBytes(g.data, 3) }
+func (g LogGroupView) Source() []byte      { return findProtoBytes(g.data, 4) }
+func (g LogGroupView) MachineUUID() []byte { return findProtoBytes(g.data, 5) }
+
+// Logs returns an iterator over the logs of the group.
+func (g LogGroupView) Logs() LogViewIterator {
+	return LogViewIterator{fields: protoFields{data: g.data}}
+}
+
+// Tags returns an iterator over the tags of the group.
+func (g LogGroupView) Tags() KeyValueIterator {
+	return KeyValueIterator{fields: protoFields{data: g.data}, num: 6}
+}
+
+// Tag returns the value of the tag of key, false if the group has no such tag.
+func (g LogGroupView) Tag(key string) ([]byte, bool) {
+	return findKeyValue(g.Tags(), key)
+}
+
+// Decode decodes the log group.
+func (g LogGroupView) Decode() (*LogGroup, error) {
+	group := &LogGroup{}
+	if err := group.Unmarshal(g.data); err != nil {
+		return nil, err
+	}
+	group.cursor = g.cursor
+	return group, nil
+}
+
+// LogViewIterator iterates over the logs of a LogGroupView.
+type LogViewIterator struct {
+	fields protoFields
+	log    LogView
+}
+
+// Next moves to the next log, it returns false after the last one or on error.
+func (it *LogViewIterator) Next() bool {
+	for it.fields.next() {
+		if it.fields.field.num != 1 {
+			continue
+		}
+		if !it.fields.expect(protoBytes) {
+			return false
+		}
+		it.log = LogView{data: it.fields.field.bytes}
+		return true
+	}
+	return false
+}
+
+// Log returns the current log.
+func (it *LogViewIterator) Log() LogView {
+	return it.log
+}
+
+// Err returns the error that stopped the iterator, nil if it walked all logs.
+func (it *LogViewIterator) Err() error {
+	return it.fields.err
+}
+
+// LogView is a view of a serialized Log.
+type LogView struct {
+	data []byte
+}
+
+// Bytes returns the serialized log.
+func (l LogView) Bytes() []byte {
+	return l.data
+}
+
+// Time returns the time of the log in unix seconds, 0 if it is malformed.
+func (l LogView) Time() uint32 {
+	return uint32(findProtoNumber(l.data, 1, protoVarint))
+}
+
+// TimeNs returns the nanoseconds part of the time of the log.
+func (l LogView) TimeNs() uint32 {
+	return uint32(findProtoNumber(l.data, 4, protoFixed32))
+}
+
+// Contents returns an iterator over the contents of the log.
+func (l LogView) Contents() KeyValueIterator {
+	return KeyValueIterator{fields: protoFields{data: l.data}, num: 2}
+}
+
+// Get returns the value of the content of key, false if the log has no such content.
+func (l LogView) Get(key string) ([]byte, bool) {
+	return findKeyValue(l.Contents(), key)
+}
+
+// Decode decodes the log.
+func (l LogView) Decode() (*Log, error) {
+	log := &Log{}
+	if err := log.Unmarshal(l.data); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// KeyValueIterator iterates over the contents of a LogView or the tags of a LogGroupView.
+type KeyValueIterator struct {
+	fields     protoFields
+	num        int
+	key, value []byte
+}
+
+// Next moves to the next key and value, it returns false after the last one or on error.
+func (it *KeyValueIterator) Next() bool {
+	for it.fields.next() {
+		if it.fields.field.num != it.num {
+			continue
+		}
+		if !it.fields.expect(protoBytes) {
+			return false
+		}
+		it.key, it.value = nil, nil
+		kv := protoFields{data: it.fields.field.bytes}
+		for kv.next() {
+			switch kv.field.num {
+			case 1:
+				if kv.expect(protoBytes) {
+					it.key = kv.field.bytes
+				}
+			case 2:
+				if kv.expect(protoBytes) {
+					it.value = kv.field.bytes
+				}
+			}
+		}
+		if kv.err != nil {
+			it.fields.err = kv.err
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (it *KeyValueIterator) Key() []byte {
+	return it.key
+}
+
+func (it *KeyValueIterator) Value() []byte {
+	return it.value
+}
+
+// Err returns the error that stopped the iterator, nil if it walked all keys and values.
+func (it *KeyValueIterator) Err() error {
+	return it.fields.err
+}
+
+func findKeyValue(it KeyValueIterator, key string) ([]byte, bool) {
+	for it.Next() {
+		if string(it.key) == key {
+			return it.value, true
+		}
+	}
+	return nil, false
+}
+
+// wire types of protobuf
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// protoField is a field of a serialized protobuf message
+type protoField struct {
+	num      int
+	wireType int
+	number   uint64 // value of varint and fixed fields
+	bytes    []byte // value of length delimited fields
+}
+
+// protoFields iterates over the fields of a serialized protobuf message
+type protoFields struct {
+	data  []byte
+	pos   int
+	field protoField
+	err   error
+}
+
+func (it *protoFields) next() bool {
+	if it.err != nil || it.pos >= len(it.data) {
+		return false
+	}
+	key, n := binary.Uvarint(it.data[it.pos:])
+	if n <= 0 {
+		it.err = errInvalidProto
+		return false
+	}
+	pos := it.pos + n
+	f := protoField{num: int(key >> 3), wireType: int(key & 7)}
+	switch f.wireType {
+	case protoVarint:
+		f.number, n = binary.Uvarint(it.data[pos:])
+		if n <= 0 {
+			it.err = errInvalidProto
+			return false
+		}
+		pos += n
+	case protoFixed64:
+		if len(it.data)-pos < 8 {
+			it.err = errInvalidProto
+			return false
+		}
+		f.number = binary.LittleEndian.Uint64(it.data[pos:])
+		pos += 8
+	case protoBytes:
+		size, n := binary.Uvarint(it.data[pos:])
+		if n <= 0 || size > uint64(len(it.data)-pos-n) {
+			it.err = errInvalidProto
+			return false
+		}
+		pos += n
+		f.bytes = it.data[pos : pos+int(size) : pos+int(size)]
+		pos += int(size)
+	case protoFixed32:
+		if len(it.data)-pos < 4 {
+			it.err = errInvalidProto
+			return false
+		}
+		f.number = uint64(binary.LittleEndian.Uint32(it.data[pos:]))
+		pos += 4
+	default:
+		it.err = fmt.Errorf("%w: unsupported wire type %d", errInvalidProto, f.wireType)
+		return false
+	}
+	it.field, it.pos = f, pos
+	return true
+}
+
+// expect checks the wire type of the current field
+func (it *protoFields) expect(wireType int) bool {
+	if it.field.wireType != wireType {
+		it.err = fmt.Errorf("%w: wire type %d of field %d", errInvalidProto, it.field.wireType, it.field.num)
+		return false
+	}
+	return true
+}
+
+// findProtoBytes returns the length delimited field num of data, the last one wins as when unmarshaling
+func findProtoBytes(data []byte, num int) []byte {
+	var value []byte
+	fields := protoFields{data: data}
+	for fields.next() {
+		if fields.field.num == num && fields.field.wireType == protoBytes {
+			value = fields.field.bytes
+		}
+	}
+	return value
+}
+
+func findProtoNumber(data []byte, num, wireType int) uint64 {
+	var value uint64
+	fields := protoFields{data: data}
+	for fields.next() {
+		if fields.field.num == num && fields.field.wireType == wireType {
+			value = fields.field.number
+		}
+	}
+	return value
+}
@@ -0,0 +1,159 @@
+package sls
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLogGroupList() *LogGroupList {
+	gl := &LogGroupList{}
+	for g := 0; g < 3; g++ {
+		group := &LogGroup{
+			Topic:   proto.String("topic" + strconv.Itoa(g)),
+			Source:  proto.String("127.0.0.1"),
+			LogTags: []*LogTag{{Key: proto.String("__pack_id__"), Value: proto.String("p" + strconv.Itoa(g))}},
+		}
+		for i := 0; i < 2; i++ {
+			group.Logs = append(group.Logs, &Log{
+				Time:   proto.Uint32(uint32(1700000000 + i)),
+				TimeNs: proto.Uint32(uint32(i)),
+				Contents: []*LogContent{
+					{Key: proto.String("group"), Value: proto.String(strconv.Itoa(g))},
+					{Key: proto.String("index"), Value: proto.String(strconv.Itoa(i))},
+				},
+			})
+		}
+		gl.LogGroups = append(gl.LogGroups, group)
+	}
+	return gl
+}
+
+func TestLogGroupListView(t *testing.T) {
+	gl := testLogGroupList()
+	data, err := proto.Marshal(gl)
+	require.NoError(t, err)
+
+	view := NewLogGroupListView(data, nil)
+	n, err := view.Len()
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	var topics, values []string
+	groups := view.Groups()
+	for groups.Next() {
+		group := groups.Group()
+		topics = append(topics, string(group.Topic()))
+		assert.Equal(t, "127.0.0.1", string(group.Source()))
+		assert.Nil(t, group.Category())
+		assert.Empty(t, group.Cursor())
+		packID, ok := group.Tag("__pack_id__")
+		assert.True(t, ok)
+		assert.Equal(t, "p"+strconv.Itoa(len(topics)-1), string(packID))
+		if string(group.Topic()) == "topic1" {
+			continue
+		}
+		logs := group.Logs()
+		for logs.Next() {
+			log := logs.Log()
+			index, ok := log.Get("index")
+			require.True(t, ok)
+			assert.Equal(t, uint32(1700000000)+log.TimeNs(), log.Time())
+			contents := log.Contents()
+			for contents.Next() {
+				values = append(values, string(contents.Key())+"="+string(contents.Value()))
+			}
+			require.NoError(t, contents.Err())
+			_, ok = log.Get("missing")
+			assert.False(t, ok)
+			decoded, err := log.Decode()
+			require.NoError(t, err)
+			assert.Equal(t, string(index), decoded.Contents[1].GetValue())
+		}
+		require.NoError(t, logs.Err())
+	}
+	require.NoError(t, groups.Err())
+	assert.Equal(t, []string{"topic0", "topic1", "topic2"}, topics)
+	assert.Equal(t, []string{"group=0", "index=0", "group=0", "index=1", "group=2", "index=0", "group=2", "index=1"}, values)
+
+	decoded, err := view.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, gl.String(), decoded.String())
+}
+
+func TestLogGroupListViewCursors(t *testing.T) {
+	data, err := proto.Marshal(testLogGroupList())
+	require.NoError(t, err)
+	plm := &PullLogMeta{Count: 3, readLastCursor: "102"}
+
+	view := NewLogGroupListView(data, plm)
+	decoded, err := view.Decode()
+	require.NoError(t, err)
+	var cursors []string
+	groups := view.Groups()
+	for groups.Next() {
+		cursors = append(cursors, groups.Group().Cursor())
+	}
+	assert.Equal(t, []string{encodeCursor(100), encodeCursor(101), encodeCursor(102)}, cursors)
+	assert.Equal(t, encodeCursor(101), decoded.LogGroups[1].GetCursor())
+
+	plm.hasQuery = true
+	groups = NewLogGroupListView(data, plm).Groups()
+	require.True(t, groups.Next())
+	assert.Empty(t, groups.Group().Cursor())
+}
+
+func TestLogGroupListViewMalformed(t *testing.T) {
+	data, err := proto.Marshal(testLogGroupList())
+	require.NoError(t, err)
+
+	_, err = NewLogGroupListView(data[:len(data)-3], nil).Len()
+	assert.True(t, errors.Is(err, errInvalidProto))
+	_, err = ParseLogGroupListView(data[:len(data)-3], nil)
+	assert.True(t, errors.Is(err, errInvalidProto))
+	view, err := ParseLogGroupListView(data, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, view.n)
+
+	// a log group field with a varint wire type
+	groups := NewLogGroupListView([]byte{0x08, 0x01}, nil).Groups()
+	assert.False(t, groups.Next())
+	assert.True(t, errors.Is(groups.Err(), errInvalidProto))
+
+	empty := NewLogGroupListView(nil, nil).Groups()
+	assert.False(t, empty.Next())
+	assert.NoError(t, empty.Err())
+}
+
+func BenchmarkLogGroupListView(b *testing.B) {
+	data, _ := proto.Marshal(testLogGroupList())
+	b.Run("view", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			groups := NewLogGroupListView(data, nil).Groups()
+			for groups.Next() {
+				logs := groups.Group().Logs()
+				for logs.Next() {
+					logs.Log().Get("index")
+				}
+			}
+		}
+	})
+	b.Run("decode", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gl, _ := LogsBytesDecode(data)
+			for _, group := range gl.LogGroups {
+				for _, log := range group.Logs {
+					for _, content := range log.Contents {
+						if content.GetKey() == "index" {
+							break
+						}
+					}
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,363 @@
+package sls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+const (
+	// DefaultLogPageSize is the page size of LogIterator, the max lines of a search page
+	DefaultLogPageSize = 100
+	// DefaultMaxLogOffset is the offset above which LogIterator splits the time range
+	DefaultMaxLogOffset = 100000
+	// number of histogram buckets requested to split a time range
+	splitBuckets = 60
+)
+
+var (
+	sqlLimitRe   = regexp.MustCompile(`(?i)\blimit\s+\d+`)
+	sqlOrderByRe = regexp.MustCompile(`(?i)\border\s+by\b`)
+)
+
+// ErrUnorderedSQL is returned by LogIterator for an analytic SQL without ORDER BY
+// whose rows do not fit in a single page, as its pages would not be stable.
+var ErrUnorderedSQL = errors.New("analytic SQL paged by LogIterator must have an ORDER BY clause")
+
+// LogIteratorOptions configures a LogIterator.
+type LogIteratorOptions struct {
+	// PageSize is the number of logs or rows fetched per request, default DefaultLogPageSize
+	PageSize int64
+	// SplitTimeRange splits the time range of searches into windows holding at
+	// most MaxOffset logs each, according to the counts of GetHistogramsV2, so
+	// that the offset of a page never exceeds the limit of the server.
+	SplitTimeRange bool
+	// MaxOffset is the max offset of a page when SplitTimeRange is set, default DefaultMaxLogOffset
+	MaxOffset int64
+}
+
+// LogIterator walks the logs of a search, or the rows of an analytic SQL,
+// page by page, holding a single page in memory.
+//
+// Searches are paged with GetLogRequest.Offset and Lines, analytic SQLs by
+// appending "limit offset, size" to the SQL, unless it has a top level limit, in
+// which case the result is returned as a single page. The rows of a SQL are
+// only in a stable order with a top level ORDER BY, so a SQL without one whose rows
+// do not fit in a single page fails the iterator with ErrUnorderedSQL. Pages are
+// fetched with GetLogsToCompletedV3, a page still incomplete after retries fails
+// the iterator.
+//
+//	it := sls.NewLogIterator(client, project, logstore, &sls.GetLogRequest{
+//		From: from, To: to, Query: "status >= 500",
+//	}, sls.LogIteratorOptions{SplitTimeRange: true})
+//	err := it.ForEach(ctx, func(row map[string]string) error {
+//		return writer.Write(row)
+//	})
+type LogIterator struct {
+	client   ClientInterface
+	project  string
+	logstore string
+	req      GetLogRequest
+	opts     LogIteratorOptions
+	sqlIndex int // index of the "|" before the analytic SQL, -1 for searches
+
+	windows []timeWindow // windows to walk, nil until the time range is split
+	offset  int64        // offset in windows[0]
+	meta    *GetLogsV3ResponseMeta
+	done    bool
+	err     error
+}
+
+type timeWindow struct {
+	from, to int64
+}
+
+// NewLogIterator returns an iterator over the logs returned by req, the Offset and Lines of req are ignored.
+func NewLogIterator(client ClientInterface, project, logstore string, req *GetLogRequest, opts LogIteratorOptions) *LogIterator {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultLogPageSize
+	}
+	if opts.MaxOffset <= 0 {
+		opts.MaxOffset = DefaultMaxLogOffset
+	}
+	it := &LogIterator{
+		client:   client,
+		project:  project,
+		logstore: logstore,
+		req:      *req,
+		opts:     opts,
+		sqlIndex: sqlPipeIndex(req.Query),
+	}
+	return it
+}
+
+// sqlPipeIndex returns the index of the first "|" of query out of quotes, -1 if there is none
+func sqlPipeIndex(query string) int {
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '|':
+			return i
+		}
+	}
+	return -1
+}
+
+// sqlTopLevel returns sql with its quoted literals and parenthesized parts blanked out, so
+// that the clauses of subqueries and window functions are not taken for those of sql
+func sqlTopLevel(sql string) string {
+	buf := []byte(sql)
+	var quote byte
+	depth := 0
+	for i := 0; i < len(buf); i++ {
+		c := buf[i]
+		switch {
+		case quote != 0 && c == '\\':
+			buf[i] = ' '
+			if i+1 < len(buf) {
+				i++
+				buf[i] = ' '
+			}
+			continue
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		default:
+			if depth == 0 {
+				continue
+			}
+		}
+		buf[i] = ' '
+	}
+	return string(buf)
+}
+
+// Next returns the next page of logs, ErrNoMorePages after the last one.
+func (it *LogIterator) Next(ctx context.Context) ([]map[string]string, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+	client := BindContext(it.client, ctx)
+	for !it.done {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if it.windows == nil {
+			if err := it.split(client); err != nil {
+				it.err = err
+				return nil, err
+			}
+		}
+		if len(it.windows) == 0 {
+			it.done = true
+			break
+		}
+		rows, last, err := it.fetch(client, it.windows[0])
+		if err != nil {
+			it.err = err
+			return nil, err
+		}
+		it.offset += int64(len(rows))
+		if last {
+			it.windows = it.windows[1:]
+			it.offset = 0
+		}
+		if len(rows) > 0 {
+			return rows, nil
+		}
+	}
+	return nil, ErrNoMorePages
+}
+
+// ForEach calls fn with every log, it stops at the first error of fn.
+func (it *LogIterator) ForEach(ctx context.Context, fn func(row map[string]string) error) error {
+	for {
+		rows, err := it.Next(ctx)
+		if err == ErrNoMorePages {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Stream sends every log to the returned channel, which is closed after the
+// last log, on error or when ctx is done, Err returns the error then.
+//
+// At most a page and buffer logs are held in memory.
+func (it *LogIterator) Stream(ctx context.Context, buffer int) <-chan map[string]string {
+	ch := make(chan map[string]string, buffer)
+	go func() {
+		defer close(ch)
+		err := it.ForEach(ctx, func(row map[string]string) error {
+			select {
+			case ch <- row:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			it.err = err
+		}
+	}()
+	return ch
+}
+
+// Meta returns the meta of the last page fetched, nil before the first one.
+func (it *LogIterator) Meta() *GetLogsV3ResponseMeta {
+	return it.meta
+}
+
+// Err returns the error that stopped the iterator, nil if it is not stopped or walked all logs.
+func (it *LogIterator) Err() error {
+	return it.err
+}
+
+// fetch returns a page of window at it.offset and whether it is the last page of window
+func (it *LogIterator) fetch(client ClientInterface, window timeWindow) ([]map[string]string, bool, error) {
+	req := it.req
+	req.From, req.To = window.from, window.to
+	ordered := true
+	if it.sqlIndex >= 0 {
+		sql := sqlTopLevel(req.Query[it.sqlIndex:])
+		if sqlLimitRe.MatchString(sql) {
+			resp, err := it.getLogs(client, &req)
+			if err != nil {
+				return nil, false, err
+			}
+			return resp.Logs, true, nil
+		}
+		ordered = sqlOrderByRe.MatchString(sql)
+		req.Query = fmt.Sprintf("%s limit %d, %d", req.Query, it.offset, it.opts.PageSize)
+	} else {
+		req.Offset, req.Lines = it.offset, it.opts.PageSize
+	}
+	resp, err := it.getLogs(client, &req)
+	if err != nil {
+		return nil, false, err
+	}
+	last := int64(len(resp.Logs)) < it.opts.PageSize
+	if !ordered && !last {
+		return nil, false, ErrUnorderedSQL
+	}
+	return resp.Logs, last, nil
+}
+
+func (it *LogIterator) getLogs(client ClientInterface, req *GetLogRequest) (*GetLogsV3Response, error) {
+	resp, err := client.GetLogsToCompletedV3(it.project, it.logstore, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsComplete() {
+		return nil, fmt.Errorf("logs of [%d, %d) at offset %d are incomplete after retries", req.From, req.To, req.Offset)
+	}
+	it.meta = &resp.Meta
+	return resp, nil
+}
+
+// split sets it.windows, the time range is split only for searches with SplitTimeRange
+func (it *LogIterator) split(client ClientInterface) error {
+	whole := timeWindow{from: it.req.From, to: it.req.To}
+	if !it.opts.SplitTimeRange || it.sqlIndex >= 0 {
+		it.windows = []timeWindow{whole}
+		return nil
+	}
+	windows, err := it.splitWindow(client, whole)
+	if err != nil {
+		return err
+	}
+	if it.req.Reverse {
+		for i, j := 0, len(windows)-1; i < j; i, j = i+1, j-1 {
+			windows[i], windows[j] = windows[j], windows[i]
+		}
+	}
+	it.windows = windows
+	return nil
+}
+
+// splitWindow splits w into consecutive windows holding at most MaxOffset logs,
+// except for one second windows, which can not be split further
+func (it *LogIterator) splitWindow(client ClientInterface, w timeWindow) ([]timeWindow, error) {
+	interval := (w.to - w.from + splitBuckets - 1) / splitBuckets
+	if interval < 1 {
+		interval = 1
+	}
+	resp, err := client.GetHistogramsToCompletedV2(it.project, it.logstore, &GetHistogramRequest{
+		Topic:    it.req.Topic,
+		From:     w.from,
+		To:       w.to,
+		Query:    it.req.Query,
+		Interval: int32(interval),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Count <= it.opts.MaxOffset {
+		return []timeWindow{w}, nil
+	}
+
+	// windows are contiguous, so that logs out of the buckets are not skipped
+	var windows []timeWindow
+	end := w.from
+	merging := false // whether the last window can be extended with the next bucket
+	var count int64  // logs in the last window
+	for _, h := range resp.Histograms {
+		bucket := timeWindow{from: end, to: minInt64(h.To, w.to)}
+		if bucket.from >= bucket.to {
+			continue
+		}
+		end = bucket.to
+		if h.Count > it.opts.MaxOffset && bucket.to-bucket.from > 1 && bucket != w {
+			sub, err := it.splitWindow(client, bucket)
+			if err != nil {
+				return nil, err
+			}
+			windows = append(windows, sub...)
+			merging = false
+			continue
+		}
+		if merging && count+h.Count <= it.opts.MaxOffset {
+			windows[len(windows)-1].to = bucket.to
+			count += h.Count
+			continue
+		}
+		windows = append(windows, bucket)
+		merging, count = true, h.Count
+	}
+	if end < w.to {
+		windows = append(windows, timeWindow{from: end, to: w.to})
+	}
+	return windows, nil
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
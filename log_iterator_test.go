@@ -0,0 +1,181 @@
+package sls
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQueryServer serves GetLogsV3 and GetHistogramsV2 over one log per second in [1000, 1250)
+type fakeQueryServer struct {
+	mu        sync.Mutex
+	maxOffset int64
+	queries   []string
+}
+
+var fakeSQLLimitRe = regexp.MustCompile(`limit (\d+), (\d+)$`)
+
+func (s *fakeQueryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Query().Get("type") == "histogram" {
+		from, _ := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		to, _ := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+		interval, _ := strconv.ParseInt(r.URL.Query().Get("interval"), 10, 64)
+		var histograms []SingleHistogram
+		var total int64
+		for t := from; t < to; t += interval {
+			h := SingleHistogram{From: t, To: minInt64(t+interval, to), Progress: "Complete"}
+			h.Count = minInt64(h.To, 1250) - maxOf(h.From, 1000)
+			if h.Count < 0 {
+				h.Count = 0
+			}
+			total += h.Count
+			histograms = append(histograms, h)
+		}
+		w.Header().Set(GetLogsCountHeader, strconv.FormatInt(total, 10))
+		w.Header().Set(ProgressHeader, "Complete")
+		json.NewEncoder(w).Encode(histograms)
+		return
+	}
+
+	var req GetLogRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	s.queries = append(s.queries, req.Query)
+	offset, lines := req.Offset, req.Lines
+	if m := fakeSQLLimitRe.FindStringSubmatch(req.Query); m != nil {
+		offset, _ = strconv.ParseInt(m[1], 10, 64)
+		lines, _ = strconv.ParseInt(m[2], 10, 64)
+	}
+	if offset > s.maxOffset {
+		s.maxOffset = offset
+	}
+	var logs []map[string]string
+	for t := maxOf(req.From, 1000) + offset; t < minInt64(req.To, 1250) && int64(len(logs)) < lines; t++ {
+		logs = append(logs, map[string]string{"__time__": strconv.FormatInt(t, 10)})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"meta": map[string]interface{}{"progress": "Complete"},
+		"data": logs,
+	})
+}
+
+func maxOf(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func collectTimes(t *testing.T, it *LogIterator) []string {
+	var times []string
+	require.NoError(t, it.ForEach(context.Background(), func(row map[string]string) error {
+		times = append(times, row["__time__"])
+		return nil
+	}))
+	return times
+}
+
+func expectedTimes(from, to int) []string {
+	var times []string
+	for i := from; i < to; i++ {
+		times = append(times, strconv.Itoa(i))
+	}
+	return times
+}
+
+func TestLogIterator(t *testing.T) {
+	server := &fakeQueryServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client := CreateNormalInterfaceV2(ts.URL, NewStaticCredentialsProvider("id", "key", ""))
+	req := &GetLogRequest{From: 900, To: 1300, Query: "*"}
+
+	it := NewLogIterator(client, "", "logstore", req, LogIteratorOptions{})
+	assert.Equal(t, expectedTimes(1000, 1250), collectTimes(t, it))
+	assert.Equal(t, int64(200), server.maxOffset)
+	_, err := it.Next(context.Background())
+	assert.Equal(t, ErrNoMorePages, err)
+
+	server.maxOffset = 0
+	it = NewLogIterator(client, "", "logstore", req, LogIteratorOptions{PageSize: 20, SplitTimeRange: true, MaxOffset: 50})
+	assert.Equal(t, expectedTimes(1000, 1250), collectTimes(t, it))
+	assert.LessOrEqual(t, server.maxOffset, int64(50))
+
+	server.queries = nil
+	it = NewLogIterator(client, "", "logstore", &GetLogRequest{From: 900, To: 1300, Query: "* | select __time__ order by __time__"},
+		LogIteratorOptions{PageSize: 100})
+	var times []string
+	for row := range it.Stream(context.Background(), 10) {
+		times = append(times, row["__time__"])
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, expectedTimes(1000, 1250), times)
+	assert.Equal(t, "* | select __time__ order by __time__ limit 200, 100", server.queries[2])
+
+	// sql without order by is paged only if it fits in a page
+	it = NewLogIterator(client, "", "logstore", &GetLogRequest{From: 900, To: 1300, Query: "* | select __time__"},
+		LogIteratorOptions{PageSize: 100})
+	_, err = it.Next(context.Background())
+	assert.Equal(t, ErrUnorderedSQL, err)
+	it = NewLogIterator(client, "", "logstore", &GetLogRequest{From: 1200, To: 1300, Query: "* | select __time__"},
+		LogIteratorOptions{PageSize: 100})
+	assert.Equal(t, expectedTimes(1200, 1250), collectTimes(t, it))
+
+	// a quoted pipe is part of a search
+	server.queries = nil
+	it = NewLogIterator(client, "", "logstore", &GetLogRequest{From: 1200, To: 1300, Query: `content: "a|b"`},
+		LogIteratorOptions{})
+	assert.Equal(t, expectedTimes(1200, 1250), collectTimes(t, it))
+	assert.Equal(t, []string{`content: "a|b"`}, server.queries)
+
+	// sql with a limit is returned as a single page
+	server.queries = nil
+	it = NewLogIterator(client, "", "logstore", &GetLogRequest{From: 900, To: 1300, Query: "* | select __time__ limit 0, 10"},
+		LogIteratorOptions{})
+	assert.Equal(t, expectedTimes(1000, 1010), collectTimes(t, it))
+	assert.Len(t, server.queries, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NewLogIterator(client, "", "logstore", req, LogIteratorOptions{}).Next(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestSQLPipeIndex(t *testing.T) {
+	assert.Equal(t, -1, sqlPipeIndex("status: 500"))
+	assert.Equal(t, 2, sqlPipeIndex("* | select count(*)"))
+	assert.Equal(t, -1, sqlPipeIndex(`content: "a|b"`))
+	assert.Equal(t, 21, sqlPipeIndex(`content: "a\"|b" and | select 1`))
+	assert.Equal(t, 15, sqlPipeIndex("content: 'a|b' | select '|'"))
+}
+
+func TestSQLTopLevel(t *testing.T) {
+	topLevel := func(sql string) (bool, bool) {
+		sql = sqlTopLevel(sql)
+		return sqlLimitRe.MatchString(sql), sqlOrderByRe.MatchString(sql)
+	}
+	limited, ordered := topLevel("select status, count(*) as c group by status order by c limit 10")
+	assert.True(t, limited)
+	assert.True(t, ordered)
+	limited, ordered = topLevel("select * from (select host from log order by host limit 10)")
+	assert.False(t, limited)
+	assert.False(t, ordered)
+	limited, ordered = topLevel("select host, rank() over (order by latency) as r from log")
+	assert.False(t, limited)
+	assert.False(t, ordered)
+	limited, ordered = topLevel(`select 'order by x limit 1' as s, "limit 5" from log where a = 'it''s (' order by s`)
+	assert.False(t, limited)
+	assert.True(t, ordered)
+	limited, ordered = topLevel(`select 'a\' limit 1' from log`)
+	assert.False(t, limited)
+	assert.False(t, ordered)
+}
@@ -65,6 +65,11 @@ type LogProject struct {
 	// be ignored
 	commonHeaders map[string]string
 	innerHeaders  map[string]string
+
+	// ctx is the parent context of every request, nil means context.Background()
+	ctx          context.Context
+	interceptors []Interceptor
+	retryPolicy  RetryPolicy
 }
 
 // NewLogProject creates a new SLS project.
@@ -125,11 +130,24 @@ func (p *LogProject) WithRetryTimeout(timeout time.Duration) *LogProject {
 	return p
 }
 
+// WithContext binds ctx to the project, requests sent by the project
+// and its logstores are aborted once ctx is done.
+func (p *LogProject) WithContext(ctx context.Context) *LogProject {
+	p.ctx = ctx
+	return p
+}
+
+// WithRetryPolicy sets the retry policy of requests sent by the project and its logstores,
+// nil means DefaultRetryPolicy. A policy set by ContextWithRetryPolicy on the bound context takes precedence.
+func (p *LogProject) WithRetryPolicy(policy RetryPolicy) *LogProject {
+	p.retryPolicy = policy
+	return p
+}
+
 // RawRequest send raw http request to LogService and return the raw http response
 // @note you should call http.Response.Body.Close() to close body stream
 func (p *LogProject) RawRequest(method, uri string, headers map[string]string, body []byte) (*http.Response, error) {
-	ctx := context.Background()
-	return realRequest(ctx, p, method, uri, headers, body)
+	return realRequest(p.context(), p, method, uri, headers, body)
 }
 
 // ListLogStore returns all logstore names of project p.
@@ -1256,6 +1274,24 @@ func (p *LogProject) init() {
 	}
 }
 
+func (p *LogProject) context() context.Context {
+	if p.ctx != nil {
+		return p.ctx
+	}
+	return context.Background()
+}
+
+// getRetryPolicy returns the policy of ctx if any, then the one of the project, then the default one
+func (p *LogProject) getRetryPolicy(ctx context.Context) RetryPolicy {
+	if policy := RetryPolicyFromContext(ctx); policy != nil {
+		return policy
+	}
+	if p.retryPolicy != nil {
+		return p.retryPolicy
+	}
+	return defaultRetryPolicy
+}
+
 func (p *LogProject) getBaseURL() string {
 	p.parseEndpointIfNeeded()
 	return p.baseURL
@@ -356,18 +356,25 @@ func (s *LogStore) PostLogStoreLogs(req *PostLogStoreLogsRequest) (err error) {
 		return err
 	}
 
-	if req.LogGroup == nil || len(req.LogGroup.Logs) == 0 {
-		// empty log group or empty hashkey
-		return nil
-	}
-
-	if s.useMetricStoreURL {
-		return s.PutLogs(req.LogGroup)
-	}
-
-	body, err := proto.Marshal(req.LogGroup)
-	if err != nil {
-		return NewClientError(err)
+	body := req.RawLogGroup
+	if body == nil {
+		if req.LogGroup == nil || len(req.LogGroup.Logs) == 0 {
+			// empty log group or empty hashkey
+			return nil
+		}
+		if s.useMetricStoreURL {
+			return s.PutLogs(req.LogGroup)
+		}
+		body, err = proto.Marshal(req.LogGroup)
+		if err != nil {
+			return NewClientError(err)
+		}
+	} else if s.useMetricStoreURL {
+		lg := &LogGroup{}
+		if err := proto.Unmarshal(body, lg); err != nil {
+			return NewClientError(err)
+		}
+		return s.PutLogs(lg)
 	}
 
 	var out []byte
@@ -471,7 +478,9 @@ func (s *LogStore) GetCursor(shardID int, from string) (cursor string, err error
 			}
 			return
 		}
-		err = fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
+		errMsg.HTTPCode = int32(r.StatusCode)
+		errMsg.RequestID = r.Header.Get(RequestIDHeader)
+		err = errMsg
 		return
 	}
 
@@ -546,7 +555,9 @@ func (s *LogStore) GetLogsBytesWithQuery(plr *PullLogRequest) ([]byte, *PullLogM
 			}
 			return nil, nil, fmt.Errorf("failed parse errorCode json: %w", err)
 		}
-		return nil, nil, fmt.Errorf("%v:%v", errMsg.Code, errMsg.Message)
+		errMsg.HTTPCode = int32(r.StatusCode)
+		errMsg.RequestID = r.Header.Get(RequestIDHeader)
+		return nil, nil, errMsg
 	}
 	netflow := len(buf)
 
@@ -569,6 +580,7 @@ func (s *LogStore) GetLogsBytesWithQuery(plr *PullLogRequest) ([]byte, *PullLogM
 		Netflow:        netflow,
 		Count:          count,
 		readLastCursor: readLastCursor,
+		hasQuery:       plr.Query != "",
 	}
 	// If query is not nil, extract more headers
 	if plr.Query != "" {
@@ -812,7 +824,11 @@ func (s *LogStore) getToCompleted(f func() (bool, error)) {
 		if err != nil || isCompleted {
 			return
 		}
-		time.Sleep(interval)
+		select {
+		case <-s.project.context().Done():
+			return
+		case <-time.After(interval):
+		}
 		retryCount--
 		if interval < 10*time.Second {
 			interval = interval * 2
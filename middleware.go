@@ -0,0 +1,46 @@
+package sls
+
+import (
+	"context"
+	"net/http"
+)
+
+// RoundTripRequest is a single http request to SLS seen by interceptors.
+//
+// Interceptors run before the sdk fills in the public headers and signs the
+// request, so headers added here are signed as well. Body must not be changed
+// without updating the 'x-log-bodyrawsize' and 'Content-MD5' headers.
+type RoundTripRequest struct {
+	Context context.Context
+	Project string // empty for region level APIs, eg. ListProject
+	Method  string
+	URI     string
+	Headers map[string]string
+	Body    []byte
+}
+
+// RoundTrip sends one request to SLS, it is called once per retry attempt.
+// A response whose status code is not 200 is returned as *Error or *BadResponseError.
+// @note if error is nil, the caller must close http.Response.Body
+type RoundTrip func(req *RoundTripRequest) (*http.Response, error)
+
+// Interceptor wraps a RoundTrip, it can inspect or modify the request,
+// short-circuit it, or observe the response and error returned by next.
+//
+//	client.Use(func(next sls.RoundTrip) sls.RoundTrip {
+//		return func(req *sls.RoundTripRequest) (*http.Response, error) {
+//			req.Headers["traceparent"] = traceParent(req.Context)
+//			return next(req)
+//		}
+//	})
+type Interceptor func(next RoundTrip) RoundTrip
+
+// chainInterceptors returns a RoundTrip calling interceptors in order, the first
+// interceptor is the outermost one, and last is called at the end of the chain.
+func chainInterceptors(interceptors []Interceptor, last RoundTrip) RoundTrip {
+	rt := last
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		rt = interceptors[i](rt)
+	}
+	return rt
+}
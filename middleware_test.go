@@ -0,0 +1,64 @@
+package sls
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientInterceptors(t *testing.T) {
+	var traceHeader string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceHeader = r.Header.Get("x-test-trace")
+		w.Header().Set(RequestIDHeader, "request-id")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errorCode":"LogStoreNotExist","errorMessage":"logstore not exist"}`))
+	}))
+	defer ts.Close()
+
+	client := CreateNormalInterfaceV2(ts.URL, NewStaticCredentialsProvider("id", "key", ""))
+	var calls []string
+	var lastErr error
+	assert.True(t, UseInterceptors(client, func(next RoundTrip) RoundTrip {
+		return func(req *RoundTripRequest) (*http.Response, error) {
+			calls = append(calls, "outer "+req.Method+" "+req.URI)
+			req.Headers["x-test-trace"] = "trace-id"
+			resp, err := next(req)
+			lastErr = err
+			return resp, err
+		}
+	}, func(next RoundTrip) RoundTrip {
+		return func(req *RoundTripRequest) (*http.Response, error) {
+			calls = append(calls, "inner "+req.Headers["x-test-trace"])
+			return next(req)
+		}
+	}))
+
+	_, err := client.GetLogStore("", "my-store")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"outer GET /logstores/my-store", "inner trace-id"}, calls)
+	assert.Equal(t, "trace-id", traceHeader)
+	slsErr, ok := lastErr.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, "LogStoreNotExist", slsErr.Code)
+	assert.Equal(t, "request-id", slsErr.RequestID)
+}
+
+func TestClientInterceptorShortCircuit(t *testing.T) {
+	client := CreateNormalInterfaceV2("127.0.0.1:1", NewStaticCredentialsProvider("id", "key", ""))
+	attempts := 0
+	client.(ClientInterfaceWithInterceptors).Use(func(next RoundTrip) RoundTrip {
+		return func(req *RoundTripRequest) (*http.Response, error) {
+			attempts++
+			if attempts < 3 {
+				return nil, &Error{HTTPCode: 503, Code: "ServerBusy", Message: "injected"}
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}
+	})
+
+	assert.NoError(t, client.DeleteLogStore("my-project", "my-store"))
+	assert.Equal(t, 3, attempts)
+}
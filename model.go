@@ -101,6 +101,7 @@ type PullLogMeta struct {
 	RawSize        int
 	Count          int
 	readLastCursor string // int64 string, eg: "1732154287213232020"
+	hasQuery       bool
 	// these fields are only present when query is set
 	RawSizeBeforeQuery   int // processed raw size before query
 	Lines                int // result lines after query
@@ -346,7 +347,9 @@ const (
 )
 
 type PostLogStoreLogsRequest struct {
-	LogGroup     *LogGroup
+	LogGroup *LogGroup
+	// RawLogGroup is the serialized LogGroup, such as encoded by LogGroupEncoder, sent instead of LogGroup if set
+	RawLogGroup  []byte
 	HashKey      *string
 	CompressType int
 	Processor    string
@@ -0,0 +1,296 @@
+package sls
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultPageSize is the page size used by pagers when size <= 0.
+const DefaultPageSize = 100
+
+// ErrNoMorePages is returned by Pager.Next after the last page is returned.
+var ErrNoMorePages = errors.New("no more pages")
+
+// pageFetcher fetches one page, offset based list APIs use offset and size,
+// token based ones use token. total is -1 if the API does not return it.
+type pageFetcher[T any] func(client ClientInterface, offset, size int, token string) (items []T, total int, nextToken string, err error)
+
+// Pager iterates over the items of a List* API page by page, paging by offset
+// and size, or by next token, transparently.
+//
+//	pager := sls.NewLogStorePager(client, project, "", 500)
+//	for pager.HasNext() {
+//		logstores, err := pager.Next(ctx)
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// A Pager is not safe for concurrent use.
+type Pager[T any] struct {
+	client     ClientInterface
+	fetch      pageFetcher[T]
+	pageSize   int
+	tokenBased bool
+
+	offset int
+	token  string
+	total  int
+	done   bool
+}
+
+func newOffsetPager[T any](client ClientInterface, size int, fetch pageFetcher[T]) *Pager[T] {
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	return &Pager[T]{client: client, fetch: fetch, pageSize: size, total: -1}
+}
+
+func newTokenPager[T any](client ClientInterface, fetch pageFetcher[T]) *Pager[T] {
+	return &Pager[T]{client: client, fetch: fetch, tokenBased: true, total: -1}
+}
+
+// HasNext reports whether there may be more pages, the last page may be empty.
+func (p *Pager[T]) HasNext() bool {
+	return !p.done
+}
+
+// Next fetches the next page, the request is bound to ctx if the client
+// implements ClientInterfaceWithContext. A failed page can be fetched again
+// by calling Next, ErrNoMorePages is returned once all pages are fetched.
+func (p *Pager[T]) Next(ctx context.Context) ([]T, error) {
+	if p.done {
+		return nil, ErrNoMorePages
+	}
+	items, total, nextToken, err := p.fetch(BindContext(p.client, ctx), p.offset, p.pageSize, p.token)
+	if err != nil {
+		return nil, err
+	}
+	p.total = total
+	if p.tokenBased {
+		p.token = nextToken
+		p.done = nextToken == ""
+		return items, nil
+	}
+	p.offset += len(items)
+	if total >= 0 {
+		p.done = len(items) == 0 || p.offset >= total
+	} else {
+		p.done = len(items) < p.pageSize
+	}
+	return items, nil
+}
+
+// All fetches the remaining pages and returns their items.
+func (p *Pager[T]) All(ctx context.Context) ([]T, error) {
+	var all []T
+	for p.HasNext() {
+		items, err := p.Next(ctx)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, items...)
+	}
+	return all, nil
+}
+
+// Total returns the total number of items reported by the last fetched page,
+// -1 if no page is fetched yet or the API does not report it.
+func (p *Pager[T]) Total() int {
+	return p.total
+}
+
+// NewProjectPager returns a Pager over ListProjectV2.
+func NewProjectPager(client ClientInterface, size int) *Pager[LogProject] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]LogProject, int, string, error) {
+		projects, _, total, err := c.ListProjectV2(offset, size)
+		return projects, total, "", err
+	})
+}
+
+// NewLogStorePager returns a Pager over ListLogStoreV2, Total is not reported.
+func NewLogStorePager(client ClientInterface, project, telemetryType string, size int) *Pager[string] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]string, int, string, error) {
+		logstores, err := c.ListLogStoreV2(project, offset, size, telemetryType)
+		return logstores, -1, "", err
+	})
+}
+
+// NewEventStorePager returns a Pager over ListEventStore, Total is not reported.
+func NewEventStorePager(client ClientInterface, project string, size int) *Pager[string] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]string, int, string, error) {
+		eventStores, err := c.ListEventStore(project, offset, size)
+		return eventStores, -1, "", err
+	})
+}
+
+// NewStoreViewPager returns a Pager over ListStoreViews.
+func NewStoreViewPager(client ClientInterface, project string, size int) *Pager[string] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]string, int, string, error) {
+		resp, err := c.ListStoreViews(project, &ListStoreViewsRequest{Offset: offset, Size: size})
+		if err != nil {
+			return nil, 0, "", err
+		}
+		return resp.StoreViews, resp.Total, "", nil
+	})
+}
+
+// NewMachineGroupPager returns a Pager over ListMachineGroup.
+func NewMachineGroupPager(client ClientInterface, project string, size int) *Pager[string] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]string, int, string, error) {
+		return pageOf2(c.ListMachineGroup(project, offset, size))
+	})
+}
+
+// NewMachinePager returns a Pager over ListMachinesV2.
+func NewMachinePager(client ClientInterface, project, machineGroupName string, size int) *Pager[*Machine] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*Machine, int, string, error) {
+		return pageOf2(c.ListMachinesV2(project, machineGroupName, offset, size))
+	})
+}
+
+// NewConfigPager returns a Pager over ListConfig.
+func NewConfigPager(client ClientInterface, project string, size int) *Pager[string] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]string, int, string, error) {
+		return pageOf2(c.ListConfig(project, offset, size))
+	})
+}
+
+// NewETLPager returns a Pager over ListETL.
+func NewETLPager(client ClientInterface, project string, size int) *Pager[*ETL] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*ETL, int, string, error) {
+		resp, err := c.ListETL(project, offset, size)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		return resp.Results, resp.Total, "", nil
+	})
+}
+
+// NewEtlMetaPager returns a Pager over ListEtlMeta, which matches all tags.
+func NewEtlMetaPager(client ClientInterface, project, etlMetaName string, size int) *Pager[*EtlMeta] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*EtlMeta, int, string, error) {
+		total, _, etlMetaList, err := c.ListEtlMeta(project, etlMetaName, offset, size)
+		return etlMetaList, total, "", err
+	})
+}
+
+// NewEtlMetaWithTagPager returns a Pager over ListEtlMetaWithTag.
+func NewEtlMetaWithTagPager(client ClientInterface, project, etlMetaName, etlMetaTag string, size int) *Pager[*EtlMeta] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*EtlMeta, int, string, error) {
+		total, _, etlMetaList, err := c.ListEtlMetaWithTag(project, etlMetaName, etlMetaTag, offset, size)
+		return etlMetaList, total, "", err
+	})
+}
+
+// NewEtlMetaNamePager returns a Pager over ListEtlMetaName.
+func NewEtlMetaNamePager(client ClientInterface, project string, size int) *Pager[string] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]string, int, string, error) {
+		total, _, etlMetaNameList, err := c.ListEtlMetaName(project, offset, size)
+		return etlMetaNameList, total, "", err
+	})
+}
+
+// NewDashboardPager returns a Pager over ListDashboard.
+func NewDashboardPager(client ClientInterface, project, dashboardName string, size int) *Pager[string] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]string, int, string, error) {
+		dashboards, _, total, err := c.ListDashboard(project, dashboardName, offset, size)
+		return dashboards, total, "", err
+	})
+}
+
+// NewDashboardV2Pager returns a Pager over ListDashboardV2, with the display names of dashboards.
+func NewDashboardV2Pager(client ClientInterface, project, dashboardName string, size int) *Pager[ResponseDashboardItem] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]ResponseDashboardItem, int, string, error) {
+		_, items, _, total, err := c.ListDashboardV2(project, dashboardName, offset, size)
+		return items, total, "", err
+	})
+}
+
+// NewSavedSearchPager returns a Pager over ListSavedSearch.
+func NewSavedSearchPager(client ClientInterface, project, savedSearchName string, size int) *Pager[string] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]string, int, string, error) {
+		return pageOf3(c.ListSavedSearch(project, savedSearchName, offset, size))
+	})
+}
+
+// NewSavedSearchV2Pager returns a Pager over ListSavedSearchV2, with the display names of saved searches.
+func NewSavedSearchV2Pager(client ClientInterface, project, savedSearchName string, size int) *Pager[ResponseSavedSearchItem] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]ResponseSavedSearchItem, int, string, error) {
+		_, items, total, _, err := c.ListSavedSearchV2(project, savedSearchName, offset, size)
+		return items, total, "", err
+	})
+}
+
+// NewAlertPager returns a Pager over ListAlert.
+func NewAlertPager(client ClientInterface, project, alertName, dashboard string, size int) *Pager[*Alert] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*Alert, int, string, error) {
+		return pageOf3(c.ListAlert(project, alertName, dashboard, offset, size))
+	})
+}
+
+// NewScheduledSQLPager returns a Pager over ListScheduledSQL.
+func NewScheduledSQLPager(client ClientInterface, project, name, displayName string, size int) *Pager[*ScheduledSQL] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*ScheduledSQL, int, string, error) {
+		return pageOf3(c.ListScheduledSQL(project, name, displayName, offset, size))
+	})
+}
+
+// NewIngestionPager returns a Pager over ListIngestion.
+func NewIngestionPager(client ClientInterface, project, logstore, name, displayName string, size int) *Pager[*Ingestion] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*Ingestion, int, string, error) {
+		return pageOf3(c.ListIngestion(project, logstore, name, displayName, offset, size))
+	})
+}
+
+// NewExportPager returns a Pager over ListExport.
+func NewExportPager(client ClientInterface, project, logstore, name, displayName string, size int) *Pager[*Export] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*Export, int, string, error) {
+		return pageOf3(c.ListExport(project, logstore, name, displayName, offset, size))
+	})
+}
+
+// NewResourcePager returns a Pager over ListResource.
+func NewResourcePager(client ClientInterface, resourceType, resourceName string, size int) *Pager[*Resource] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*Resource, int, string, error) {
+		resources, _, total, err := c.ListResource(resourceType, resourceName, offset, size)
+		return resources, total, "", err
+	})
+}
+
+// NewResourceRecordPager returns a Pager over ListResourceRecord.
+func NewResourceRecordPager(client ClientInterface, resourceName string, size int) *Pager[*ResourceRecord] {
+	return newOffsetPager(client, size, func(c ClientInterface, offset, size int, _ string) ([]*ResourceRecord, int, string, error) {
+		records, _, total, err := c.ListResourceRecord(resourceName, offset, size)
+		return records, total, "", err
+	})
+}
+
+// NewTagResourcePager returns a Pager over ListTagResources, Total is not reported.
+func NewTagResourcePager(client ClientInterface, project, resourceType string, resourceIDs []string,
+	tags []ResourceFilterTag) *Pager[*ResourceTagResponse] {
+	return newTokenPager(client, func(c ClientInterface, _, _ int, token string) ([]*ResourceTagResponse, int, string, error) {
+		respTags, nextToken, err := c.ListTagResources(project, resourceType, resourceIDs, tags, token)
+		return respTags, -1, nextToken, err
+	})
+}
+
+// NewSystemTagResourcePager returns a Pager over ListSystemTagResources, Total is not reported.
+func NewSystemTagResourcePager(client ClientInterface, project, resourceType string, resourceIDs []string,
+	tags []ResourceFilterTag, tagOwnerUid, category, scope string) *Pager[*ResourceTagResponse] {
+	return newTokenPager(client, func(c ClientInterface, _, _ int, token string) ([]*ResourceTagResponse, int, string, error) {
+		respTags, nextToken, err := c.ListSystemTagResources(project, resourceType, resourceIDs, tags, tagOwnerUid, category, scope, token)
+		return respTags, -1, nextToken, err
+	})
+}
+
+// pageOf2 adapts list APIs returning (items, total, err)
+func pageOf2[T any](items []T, total int, err error) ([]T, int, string, error) {
+	return items, total, "", err
+}
+
+// pageOf3 adapts list APIs returning (items, total, count, err)
+func pageOf3[T any](items []T, total, _ int, err error) ([]T, int, string, error) {
+	return items, total, "", err
+}
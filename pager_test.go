@@ -0,0 +1,137 @@
+package sls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectPager(t *testing.T) {
+	var names []string
+	for i := 0; i < 5; i++ {
+		names = append(names, fmt.Sprintf("project-%d", i))
+	}
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		var projects []map[string]string
+		for i := offset; i < len(names) && i < offset+size; i++ {
+			projects = append(projects, map[string]string{"projectName": names[i]})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"projects": projects,
+			"count":    len(projects),
+			"total":    len(names),
+		})
+	}))
+	defer ts.Close()
+
+	client := CreateNormalInterfaceV2(ts.URL, NewStaticCredentialsProvider("id", "key", ""))
+	pager := NewProjectPager(client, 2)
+	assert.Equal(t, -1, pager.Total())
+	projects, err := pager.All(context.Background())
+	require.NoError(t, err)
+	require.Len(t, projects, 5)
+	for i, project := range projects {
+		assert.Equal(t, names[i], project.Name)
+	}
+	assert.Equal(t, 5, pager.Total())
+	assert.Equal(t, 3, requests)
+	assert.False(t, pager.HasNext())
+	_, err = pager.Next(context.Background())
+	assert.Equal(t, ErrNoMorePages, err)
+}
+
+func TestTokenPager(t *testing.T) {
+	pages := map[string][]string{"": {"a", "b"}, "t1": {"c"}, "t2": {}}
+	next := map[string]string{"": "t1", "t1": "t2", "t2": ""}
+	pager := newTokenPager(nil, func(_ ClientInterface, _, _ int, token string) ([]string, int, string, error) {
+		return pages[token], -1, next[token], nil
+	})
+	items, err := pager.All(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, items)
+}
+
+type etlMetaPagerClient struct {
+	ClientInterface
+	tags []string
+}
+
+func (c *etlMetaPagerClient) ListEtlMeta(project, etlMetaName string, offset, size int) (int, int, []*EtlMeta, error) {
+	return c.ListEtlMetaWithTag(project, etlMetaName, EtlMetaAllTagMatch, offset, size)
+}
+
+func (c *etlMetaPagerClient) ListEtlMetaWithTag(project, etlMetaName, etlMetaTag string, offset, size int) (int, int, []*EtlMeta, error) {
+	c.tags = append(c.tags, etlMetaTag)
+	var list []*EtlMeta
+	for i := offset; i < 3 && i < offset+size; i++ {
+		list = append(list, &EtlMeta{MetaName: etlMetaName, MetaKey: strconv.Itoa(i), MetaTag: etlMetaTag})
+	}
+	return 3, len(list), list, nil
+}
+
+func TestEtlMetaPager(t *testing.T) {
+	client := &etlMetaPagerClient{}
+	metas, err := NewEtlMetaPager(client, "", "meta", 2).All(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metas, 3)
+	assert.Equal(t, []string{EtlMetaAllTagMatch, EtlMetaAllTagMatch}, client.tags)
+
+	client = &etlMetaPagerClient{}
+	metas, err = NewEtlMetaWithTagPager(client, "", "meta", "tag", 2).All(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metas, 3)
+	assert.Equal(t, []string{"tag", "tag"}, client.tags)
+}
+
+func TestV2Pagers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.Header.Get("offset"))
+		size, _ := strconv.Atoi(r.Header.Get("size"))
+		var names []string
+		var items []map[string]string
+		for i := offset; i < 3 && i < offset+size; i++ {
+			name := fmt.Sprintf("name-%d", i)
+			names = append(names, name)
+			items = append(items, map[string]string{
+				"dashboardName":   name,
+				"savedsearchName": name,
+				"displayName":     "display " + name,
+			})
+		}
+		switch r.URL.Path {
+		case "/dashboards":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"dashboards": names, "dashboardItems": items, "count": len(names), "total": 3,
+			})
+		case "/savedsearches":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"savedsearches": names, "savedsearchItems": items, "count": len(names), "total": 3,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	client := CreateNormalInterfaceV2(ts.URL, NewStaticCredentialsProvider("id", "key", ""))
+	dashboards, err := NewDashboardV2Pager(client, "", "", 2).All(context.Background())
+	require.NoError(t, err)
+	require.Len(t, dashboards, 3)
+	assert.Equal(t, ResponseDashboardItem{DashboardName: "name-2", DisplayName: "display name-2"}, dashboards[2])
+
+	savedSearches, err := NewSavedSearchV2Pager(client, "", "", 2).All(context.Background())
+	require.NoError(t, err)
+	require.Len(t, savedSearches, 3)
+	assert.Equal(t, ResponseSavedSearchItem{SavedSearchName: "name-2", DisplayName: "display name-2"}, savedSearches[2])
+}
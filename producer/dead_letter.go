@@ -0,0 +1,133 @@
+package producer
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/gogo/protobuf/proto"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// DeadLetter is a batch failed for good, because its error is in NoRetryStatusCodeList
+// or the retries are exhausted.
+type DeadLetter struct {
+	Project      string
+	Logstore     string
+	ShardHash    string // empty if the batch is not sent with a hash key
+	LogGroup     *sls.LogGroup
+	Err          *sls.Error // the error of the final attempt
+	AttemptCount int        // the number of attempts, including the final one
+	Attempts     []*Attempt // the first MaxReservedAttempts attempts, which may not include the final one
+}
+
+// DeadLetterSink receives the batches failed for good, it must be thread safe.
+// The batch is reported to the callbacks before it is written to the sink.
+// If ProducerConfig.SpillDir is set, batches whose retries are exhausted are spilled
+// instead, only those failed with an error in NoRetryStatusCodeList are written to the sink.
+type DeadLetterSink interface {
+	WriteDeadLetter(letter *DeadLetter) error
+}
+
+// deadLetterOf returns the dead letter of a failed producerBatch.
+func deadLetterOf(producerBatch *ProducerBatch) (*DeadLetter, error) {
+	logGroup := &sls.LogGroup{}
+	if err := proto.Unmarshal(producerBatch.logGroupBytes(), logGroup); err != nil {
+		return nil, err
+	}
+	letter := &DeadLetter{
+		Project:      producerBatch.getProject(),
+		Logstore:     producerBatch.getLogstore(),
+		LogGroup:     logGroup,
+		Err:          producerBatch.result.err,
+		AttemptCount: producerBatch.attemptCount,
+		Attempts:     producerBatch.result.GetReservedAttempts(),
+	}
+	if shardHash := producerBatch.getShardHash(); shardHash != nil {
+		letter.ShardHash = *shardHash
+	}
+	return letter, nil
+}
+
+// FileDeadLetterSink writes dead letters to a local file as json lines, rotated
+// by size. A line can be decoded into a DeadLetter with encoding/json.
+type FileDeadLetterSink struct {
+	lock   sync.Mutex
+	writer *lumberjack.Logger
+}
+
+// NewFileDeadLetterSink returns a sink writing to fileName, which is rotated once it
+// reaches maxSizeMB megabytes, and at most maxBackups rotated files are retained.
+func NewFileDeadLetterSink(fileName string, maxSizeMB, maxBackups int) *FileDeadLetterSink {
+	return &FileDeadLetterSink{
+		writer: &lumberjack.Logger{
+			Filename:   fileName,
+			MaxSize:    maxSizeMB,
+			MaxBackups: maxBackups,
+		},
+	}
+}
+
+func (sink *FileDeadLetterSink) WriteDeadLetter(letter *DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	_, err = sink.writer.Write(line)
+	return err
+}
+
+// Close closes the file.
+func (sink *FileDeadLetterSink) Close() error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	return sink.writer.Close()
+}
+
+// Tags added to the log groups written by LogstoreDeadLetterSink.
+const (
+	DeadLetterProjectTag   = "__dead_letter_project__"
+	DeadLetterLogstoreTag  = "__dead_letter_logstore__"
+	DeadLetterShardHashTag = "__dead_letter_shard_hash__"
+	DeadLetterErrorTag     = "__dead_letter_error__"
+	DeadLetterAttemptsTag  = "__dead_letter_attempts__"
+	DeadLetterTimeTag      = "__dead_letter_time__"
+)
+
+// LogstoreDeadLetterSink writes dead letters to a fallback logstore, the logs are
+// written as they are, and the origin and error of the batch are added as tags.
+type LogstoreDeadLetterSink struct {
+	client   sls.ClientInterface
+	project  string
+	logstore string
+}
+
+// NewLogstoreDeadLetterSink returns a sink writing to logstore of project with client,
+// which should not share the endpoint or credentials the batches failed with.
+func NewLogstoreDeadLetterSink(client sls.ClientInterface, project, logstore string) *LogstoreDeadLetterSink {
+	return &LogstoreDeadLetterSink{client: client, project: project, logstore: logstore}
+}
+
+func (sink *LogstoreDeadLetterSink) WriteDeadLetter(letter *DeadLetter) error {
+	logGroup := *letter.LogGroup
+	tag := func(key, value string) *sls.LogTag {
+		return &sls.LogTag{Key: proto.String(key), Value: proto.String(value)}
+	}
+	logGroup.LogTags = append(append(make([]*sls.LogTag, 0, len(logGroup.LogTags)+6), logGroup.LogTags...),
+		tag(DeadLetterProjectTag, letter.Project),
+		tag(DeadLetterLogstoreTag, letter.Logstore),
+		tag(DeadLetterTimeTag, strconv.FormatInt(time.Now().Unix(), 10)),
+		tag(DeadLetterAttemptsTag, strconv.Itoa(letter.AttemptCount)))
+	if letter.ShardHash != "" {
+		logGroup.LogTags = append(logGroup.LogTags, tag(DeadLetterShardHashTag, letter.ShardHash))
+	}
+	if letter.Err != nil {
+		logGroup.LogTags = append(logGroup.LogTags, tag(DeadLetterErrorTag, letter.Err.Code+": "+letter.Err.Message))
+	}
+	return sink.client.PutLogs(sink.project, sink.logstore, &logGroup)
+}
@@ -0,0 +1,112 @@
+package producer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type deadLetterSinks []DeadLetterSink
+
+func (sinks deadLetterSinks) WriteDeadLetter(letter *DeadLetter) error {
+	for _, sink := range sinks {
+		if err := sink.WriteDeadLetter(letter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type putLogsClient struct {
+	sls.ClientInterface
+	project, logstore string
+	logGroups         []*sls.LogGroup
+}
+
+func (client *putLogsClient) PutLogs(project, logstore string, logGroup *sls.LogGroup) error {
+	client.project, client.logstore = project, logstore
+	client.logGroups = append(client.logGroups, logGroup)
+	return nil
+}
+
+func TestDeadLetterSink(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errorCode":"Unauthorized","errorMessage":"expired"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errorCode":"InvalidParameter","errorMessage":"bad"}`))
+	}))
+	defer ts.Close()
+
+	fileName := filepath.Join(t.TempDir(), "dead_letter.log")
+	fileSink := NewFileDeadLetterSink(fileName, 10, 1)
+	fallback := &putLogsClient{}
+	config := GetDefaultProducerConfig()
+	config.Endpoint = ts.URL
+	config.CredentialsProvider = sls.NewStaticCredentialsProvider("id", "key", "")
+	config.Logger = log.NewNopLogger()
+	config.DisableRuntimeMetrics = true
+	config.LingerMs = 100
+	// the final attempt is not reserved
+	config.MaxReservedAttempts = 1
+	config.DeadLetterSink = deadLetterSinks{fileSink, NewLogstoreDeadLetterSink(fallback, "fallback-project", "fallback")}
+	producer, err := NewProducer(config)
+	require.NoError(t, err)
+	producer.Start()
+
+	log := GenerateLog(1700000000, map[string]string{"k": "v"})
+	require.NoError(t, producer.HashSendLogList("", "logstore", "hash", "topic", "source", []*sls.Log{log, log}))
+	result, err := producer.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.FailCount)
+	producer.SafeClose()
+	require.NoError(t, fileSink.Close())
+
+	file, err := os.Open(fileName)
+	require.NoError(t, err)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	require.True(t, scanner.Scan())
+	letter := &DeadLetter{}
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), letter))
+	assert.False(t, scanner.Scan())
+	assert.Equal(t, "logstore", letter.Logstore)
+	assert.NotEmpty(t, letter.ShardHash)
+	assert.Equal(t, "topic", letter.LogGroup.GetTopic())
+	require.Len(t, letter.LogGroup.Logs, 2)
+	assert.Equal(t, log.String(), letter.LogGroup.Logs[0].String())
+	require.Len(t, letter.Attempts, 1)
+	assert.Equal(t, "Unauthorized", letter.Attempts[0].ErrorCode)
+	assert.Equal(t, 2, letter.AttemptCount)
+	require.NotNil(t, letter.Err)
+	assert.Equal(t, "InvalidParameter", letter.Err.Code)
+
+	assert.Equal(t, "fallback-project", fallback.project)
+	assert.Equal(t, "fallback", fallback.logstore)
+	require.Len(t, fallback.logGroups, 1)
+	logGroup := fallback.logGroups[0]
+	assert.Len(t, logGroup.Logs, 2)
+	tags := map[string]string{}
+	for _, tag := range logGroup.LogTags {
+		tags[tag.GetKey()] = tag.GetValue()
+	}
+	assert.Equal(t, "logstore", tags[DeadLetterLogstoreTag])
+	assert.Equal(t, letter.ShardHash, tags[DeadLetterShardHashTag])
+	assert.Equal(t, "2", tags[DeadLetterAttemptsTag])
+	assert.Equal(t, "InvalidParameter: bad", tags[DeadLetterErrorTag])
+}
@@ -0,0 +1,97 @@
+package producer
+
+import (
+	"context"
+	"sort"
+)
+
+// BatchResult is the outcome of a batch waited for by Flush.
+type BatchResult struct {
+	Project  string
+	Logstore string
+	LogCount int
+	Result   *Result
+}
+
+// FlushResult is the outcome of the batches waited for by Flush, in the order they are created.
+type FlushResult struct {
+	Batches      []BatchResult
+	SuccessCount int
+	FailCount    int // including batches spilled or dropped
+}
+
+// Err returns the error of the first failed batch, nil if all batches are sent successfully.
+func (result *FlushResult) Err() error {
+	for _, batch := range result.Batches {
+		if !batch.Result.IsSuccessful() {
+			return batch.Result.Err()
+		}
+	}
+	return nil
+}
+
+// Flush sends the logs buffered in the producer without waiting for LingerMs, and waits until
+// every batch created before the call, including those being sent or retried, is sent
+// successfully or fails for good. Logs sent during Flush may not be waited for.
+//
+// If ctx is done before that, Flush returns the batches finished so far with the error of ctx.
+// Flush must be called after Start.
+func (producer *Producer) Flush(ctx context.Context) (*FlushResult, error) {
+	producer.batchesLock.Lock()
+	pending := make([]*ProducerBatch, 0, len(producer.batches))
+	for batch := range producer.batches {
+		pending = append(pending, batch)
+	}
+	producer.batchesLock.Unlock()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].createTimeMs < pending[j].createTimeMs })
+
+	logAccumulator := producer.logAccumulator
+	logAccumulator.lock.Lock()
+	// once the producer is closing, the mover sends the remaining batches instead,
+	// and the thread pool may be shut down after the lock is released
+	if !logAccumulator.shutDownFlag.Load() {
+		for key, batch := range logAccumulator.logGroupData {
+			if batch != nil {
+				logAccumulator.logGroupData[key] = nil
+				producer.threadPool.addTask(batch)
+			}
+		}
+	}
+	logAccumulator.lock.Unlock()
+
+	result := &FlushResult{Batches: make([]BatchResult, 0, len(pending))}
+	for _, batch := range pending {
+		select {
+		case <-batch.done:
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+		result.Batches = append(result.Batches, BatchResult{
+			Project:  batch.getProject(),
+			Logstore: batch.getLogstore(),
+			LogCount: batch.logCount(),
+			Result:   batch.result,
+		})
+		if batch.result.IsSuccessful() {
+			result.SuccessCount++
+		} else {
+			result.FailCount++
+		}
+	}
+	return result, nil
+}
+
+func (producer *Producer) trackBatch(producerBatch *ProducerBatch) {
+	producer.batchesLock.Lock()
+	producer.batches[producerBatch] = struct{}{}
+	producer.batchesLock.Unlock()
+}
+
+// finishBatch releases producerBatch once it is sent, spilled or failed for good, and wakes up Flush.
+func (producer *Producer) finishBatch(producerBatch *ProducerBatch) {
+	producer.batchesLock.Lock()
+	delete(producer.batches, producerBatch)
+	producer.batchesLock.Unlock()
+	close(producerBatch.done)
+	producerBatch.release()
+}
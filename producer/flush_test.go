@@ -0,0 +1,158 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducerFlush(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if strings.Contains(r.URL.Path, "bad") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errorCode":"InvalidParameter","errorMessage":"bad"}`))
+		}
+	}))
+	defer ts.Close()
+
+	config := GetDefaultProducerConfig()
+	config.Endpoint = ts.URL
+	config.CredentialsProvider = sls.NewStaticCredentialsProvider("id", "key", "")
+	config.LingerMs = 500
+	config.Logger = log.NewNopLogger()
+	config.DisableRuntimeMetrics = true
+	producer, err := NewProducer(config)
+	require.NoError(t, err)
+
+	log := GenerateLog(1700000000, map[string]string{"k": "v"})
+	require.NoError(t, producer.SendLog("", "good", "topic", "source", log))
+	require.NoError(t, producer.SendLogList("", "good", "topic", "source", []*sls.Log{log, log}))
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, producer.SendLog("", "bad", "topic", "source", log))
+
+	// batches are not sent before Start
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result, err := producer.Flush(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Empty(t, result.Batches)
+
+	producer.Start()
+	defer producer.SafeClose()
+	result, err = producer.Flush(context.Background())
+	require.NoError(t, err)
+	require.Len(t, result.Batches, 2)
+	assert.Equal(t, 1, result.SuccessCount)
+	assert.Equal(t, 1, result.FailCount)
+	assert.Equal(t, "good", result.Batches[0].Logstore)
+	assert.Equal(t, 3, result.Batches[0].LogCount)
+	assert.True(t, result.Batches[0].Result.IsSuccessful())
+	assert.Equal(t, "bad", result.Batches[1].Logstore)
+	assert.Equal(t, "InvalidParameter", result.Batches[1].Result.GetErrorCode())
+	assert.Equal(t, result.Batches[1].Result.Err(), result.Err())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	// the memory of the batches, headers included, is released
+	assert.Equal(t, int64(0), producer.Stats().PendingBytes)
+
+	// nothing is buffered
+	result, err = producer.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, result.Batches)
+	assert.NoError(t, result.Err())
+}
+
+func TestProducerFlushCloseTimeout(t *testing.T) {
+	t.Run("background", func(t *testing.T) { testProducerFlushCloseTimeout(t, false) })
+	t.Run("abandon", func(t *testing.T) { testProducerFlushCloseTimeout(t, true) })
+}
+
+func testProducerFlushCloseTimeout(t *testing.T, abandon bool) {
+	sending := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "slow") {
+			sending <- struct{}{}
+			<-unblock
+		}
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	config := GetDefaultProducerConfig()
+	config.Endpoint = ts.URL
+	config.CredentialsProvider = sls.NewStaticCredentialsProvider("id", "key", "")
+	config.LingerMs = 100
+	config.MaxIoWorkerCount = 1
+	config.AbandonOnCloseTimeout = abandon
+	config.Logger = log.NewNopLogger()
+	config.DisableRuntimeMetrics = true
+	producer, err := NewProducer(config)
+	require.NoError(t, err)
+	producer.Start()
+
+	log := GenerateLog(1700000000, map[string]string{"k": "v"})
+	require.NoError(t, producer.SendLog("", "slow", "topic", "source", log))
+	select {
+	case <-sending:
+	case <-time.After(10 * time.Second):
+		t.Fatal("batch is not sent")
+	}
+	// queued behind the batch being sent by the only io worker
+	callback := &spillTestCallback{results: make(chan *Result, 1)}
+	require.NoError(t, producer.SendLogWithCallBack("", "queued", "topic", "source", log, callback))
+	flushed := make(chan *FlushResult, 1)
+	go func() {
+		result, err := producer.Flush(context.Background())
+		assert.NoError(t, err)
+		flushed <- result
+	}()
+
+	assert.EqualError(t, producer.Close(100), TimeoutExecption)
+	if abandon {
+		select {
+		case result := <-callback.results:
+			assert.False(t, result.IsSuccessful())
+			assert.True(t, errors.Is(result.Err(), ErrProducerClosed))
+		case <-time.After(time.Second):
+			t.Fatal("the queued batch is not finished by Close")
+		}
+	} else {
+		select {
+		case <-callback.results:
+			t.Fatal("the queued batch is finished by Close")
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	// Flush returns once the batch being sent is done
+	unblock <- struct{}{}
+	select {
+	case result := <-flushed:
+		require.Len(t, result.Batches, 2)
+		assert.Equal(t, "slow", result.Batches[0].Logstore)
+		assert.True(t, result.Batches[0].Result.IsSuccessful())
+		assert.Equal(t, "queued", result.Batches[1].Logstore)
+		if abandon {
+			assert.True(t, errors.Is(result.Err(), ErrProducerClosed))
+		} else {
+			// the queued batch is sent in the background
+			assert.True(t, result.Batches[1].Result.IsSuccessful())
+			assert.NoError(t, result.Err())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Flush does not return")
+	}
+	assert.Equal(t, int64(0), producer.Stats().PendingBytes)
+}
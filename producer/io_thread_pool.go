@@ -14,6 +14,8 @@ type IoThreadPool struct {
 	ioworker               *IoWorker
 	logger                 log.Logger
 	stopped                *atomic.Bool
+	abandonCh              chan struct{} // closed to finish the tasks left without sending them
+	abandonOnce            sync.Once
 }
 
 func initIoThreadPool(ioworker *IoWorker, logger log.Logger) *IoThreadPool {
@@ -23,6 +25,7 @@ func initIoThreadPool(ioworker *IoWorker, logger log.Logger) *IoThreadPool {
 		ioworker:               ioworker,
 		logger:                 logger,
 		stopped:                atomic.NewBool(false),
+		abandonCh:              make(chan struct{}),
 	}
 }
 
@@ -39,7 +42,10 @@ func (threadPool *IoThreadPool) start(ioWorkerWaitGroup *sync.WaitGroup, ioThrea
 			return
 		}
 
-		threadPool.ioworker.startSendTask(ioWorkerWaitGroup)
+		if !threadPool.ioworker.startSendTask(ioWorkerWaitGroup, threadPool.abandonCh) {
+			threadPool.ioworker.producer.abandonBatch(task)
+			continue
+		}
 		go func(producerBatch *ProducerBatch) {
 			defer threadPool.ioworker.closeSendTask(ioWorkerWaitGroup)
 			threadPool.ioworker.sendToServer(producerBatch)
@@ -54,6 +60,11 @@ func (threadPool *IoThreadPool) ShutDown() {
 	}
 }
 
+// abandon finishes the tasks not being sent yet with abandonBatch, it must be called after ShutDown.
+func (threadPool *IoThreadPool) abandon() {
+	threadPool.abandonOnce.Do(func() { close(threadPool.abandonCh) })
+}
+
 func (threadPool *IoThreadPool) Stopped() bool {
 	return threadPool.stopped.Load()
 }
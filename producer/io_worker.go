@@ -1,6 +1,8 @@
 package producer
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -8,6 +10,7 @@ import (
 	sls "github.com/aliyun/aliyun-log-go-sdk"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
 	uberatomic "go.uber.org/atomic"
 )
 
@@ -42,29 +45,49 @@ func initIoWorker(client sls.ClientInterface, retryQueue *RetryQueue, logger log
 
 func (ioWorker *IoWorker) sendToServer(producerBatch *ProducerBatch) {
 	level.Debug(ioWorker.logger).Log("msg", "ioworker send data to server")
+	client := ioWorker.client
+	var span sls.Span
+	if tracer := ioWorker.producer.producerConfig.Tracer; tracer != nil {
+		var ctx context.Context
+		ctx, span = tracer.Start(context.Background(), "sls producer send",
+			sls.Attribute{Key: sls.AttrProject, Value: producerBatch.getProject()},
+			sls.Attribute{Key: sls.AttrLogstore, Value: producerBatch.getLogstore()},
+			sls.Attribute{Key: sls.AttrAttempt, Value: producerBatch.attemptCount + 1},
+			sls.Attribute{Key: sls.AttrLogCount, Value: producerBatch.logCount()},
+			sls.Attribute{Key: sls.AttrBatchSize, Value: producerBatch.totalDataSize})
+		defer span.End()
+		client = sls.BindContext(client, ctx)
+	}
 	sendBegin := time.Now()
 	var err error
 	if producerBatch.isUseMetricStoreUrl() {
 		// not use compress type now
-		err = ioWorker.client.PutLogsWithMetricStoreURL(producerBatch.getProject(), producerBatch.getLogstore(), producerBatch.logGroup)
+		logGroup := &sls.LogGroup{}
+		if err = proto.Unmarshal(producerBatch.logGroupBytes(), logGroup); err == nil {
+			err = client.PutLogsWithMetricStoreURL(producerBatch.getProject(), producerBatch.getLogstore(), logGroup)
+		}
 	} else {
 		req := &sls.PostLogStoreLogsRequest{
-			LogGroup:     producerBatch.logGroup,
+			RawLogGroup:  producerBatch.logGroupBytes(),
 			HashKey:      producerBatch.getShardHash(),
 			CompressType: ioWorker.producer.producerConfig.CompressType,
 			Processor:    ioWorker.producer.producerConfig.Processor,
 		}
-		err = ioWorker.client.PostLogStoreLogsV2(producerBatch.getProject(), producerBatch.getLogstore(), req)
+		err = client.PostLogStoreLogsV2(producerBatch.getProject(), producerBatch.getLogstore(), req)
 	}
 	sendEnd := time.Now()
+	if span != nil && err != nil {
+		sls.RecordSpanError(span, err)
+	}
 
 	// send ok
 	if err == nil {
 		level.Debug(ioWorker.logger).Log("msg", "sendToServer success")
-		defer ioWorker.producer.monitor.recordSuccess(sendBegin, sendEnd)
+		defer ioWorker.producer.monitor.recordSuccess(producerBatch, sendBegin, sendEnd)
 		producerBatch.OnSuccess(sendBegin)
 		// After successful delivery, producer removes the batch size sent out
-		atomic.AddInt64(&ioWorker.producer.producerLogGroupSize, -producerBatch.totalDataSize)
+		ioWorker.producer.releaseMemory(producerBatch.totalDataSize)
+		ioWorker.producer.finishBatch(producerBatch)
 		return
 	}
 
@@ -75,30 +98,94 @@ func (ioWorker *IoWorker) sendToServer(producerBatch *ProducerBatch) {
 		"requestId", slsError.RequestID,
 		"errorCode", slsError.Code,
 		"errorMessage", slsError.Message,
-		"logs", len(producerBatch.logGroup.Logs),
+		"logs", producerBatch.logCount(),
 		"canRetry", canRetry)
 	if !canRetry {
-		defer ioWorker.producer.monitor.recordFailure(sendBegin, sendEnd)
+		// only failures that may be recovered by sending the batch later are spilled,
+		// others would fail again each time the batch is replayed
+		if (ioWorker.retryQueueShutDownFlag.Load() || isNetworkError(slsError)) &&
+			ioWorker.spill(producerBatch, slsError, sendBegin) {
+			return
+		}
+		defer ioWorker.producer.monitor.recordFailure(producerBatch, sendBegin, sendEnd)
 		producerBatch.OnFail(slsError, sendBegin)
-		atomic.AddInt64(&ioWorker.producer.producerLogGroupSize, -producerBatch.totalDataSize)
+		ioWorker.writeDeadLetter(producerBatch)
+		ioWorker.producer.releaseMemory(producerBatch.totalDataSize)
+		ioWorker.producer.finishBatch(producerBatch)
+		return
+	}
+
+	// spill instead of holding the batch in memory if the producer is out of memory
+	if ioWorker.producer.memoryExceeded() &&
+		ioWorker.spill(producerBatch, slsError, sendBegin) {
 		return
 	}
 
 	// do retry
-	ioWorker.producer.monitor.recordRetry(sendEnd.Sub(sendBegin))
+	ioWorker.producer.monitor.recordRetry(producerBatch, sendEnd.Sub(sendBegin))
 	producerBatch.addAttempt(slsError, sendBegin)
 	producerBatch.nextRetryMs = producerBatch.getRetryBackoffIntervalMs() + time.Now().UnixMilli()
 	level.Debug(ioWorker.logger).Log("msg", "Submit to the retry queue after meeting the retry criteria。")
 	ioWorker.retryQueue.sendToRetryQueue(producerBatch, ioWorker.logger)
 }
 
+// spill persists producerBatch failed with err to the spill queue if it is enabled, err may be
+// recovered by sending the batch later and the batch is not spilled spillMaxTimes already,
+// it returns false if the batch is not spilled.
+func (ioWorker *IoWorker) spill(producerBatch *ProducerBatch, err *sls.Error, begin time.Time) bool {
+	spillQueue := ioWorker.producer.spill
+	if spillQueue == nil {
+		return false
+	}
+	if _, ok := ioWorker.noRetryStatusCodeMap[int(err.HTTPCode)]; ok {
+		return false
+	}
+	if producerBatch.spillTimes() >= spillMaxTimes {
+		level.Warn(ioWorker.logger).Log("msg", "batch is spilled too many times", "project", producerBatch.getProject(),
+			"logstore", producerBatch.getLogstore(), "logs", producerBatch.logCount(), "spillTimes", producerBatch.spillTimes())
+		return false
+	}
+	if spillErr := spillQueue.write(producerBatch); spillErr != nil {
+		level.Error(ioWorker.logger).Log("msg", "failed to spill batch", "logs", producerBatch.logCount(), "error", spillErr)
+		return false
+	}
+	level.Warn(ioWorker.logger).Log("msg", "spill batch to disk", "project", producerBatch.getProject(),
+		"logstore", producerBatch.getLogstore(), "logs", producerBatch.logCount(), "errorCode", err.Code)
+	ioWorker.producer.monitor.incSpill()
+	producerBatch.addAttempt(err, begin)
+	producerBatch.onSpilled()
+	ioWorker.producer.releaseMemory(producerBatch.totalDataSize)
+	ioWorker.producer.finishBatch(producerBatch)
+	return true
+}
+
+func (ioWorker *IoWorker) writeDeadLetter(producerBatch *ProducerBatch) {
+	sink := ioWorker.producer.producerConfig.DeadLetterSink
+	if sink == nil {
+		return
+	}
+	letter, err := deadLetterOf(producerBatch)
+	if err == nil {
+		err = sink.WriteDeadLetter(letter)
+	}
+	if err != nil {
+		level.Error(ioWorker.logger).Log("msg", "failed to write dead letter", "project", producerBatch.getProject(),
+			"logstore", producerBatch.getLogstore(), "logs", producerBatch.logCount(), "error", err)
+	}
+}
+
 func parseSlsError(err error) *sls.Error {
-	if slsError, ok := err.(*sls.Error); ok {
+	var slsError *sls.Error
+	if errors.As(err, &slsError) {
 		return slsError
 	}
-	return &sls.Error{
-		Message: err.Error(),
-	}
+	// keep err as the cause, so that errors.Is works on Result.Err
+	return sls.WrapError(err)
+}
+
+// isNetworkError reports whether err is returned without a response from the server
+func isNetworkError(err *sls.Error) bool {
+	return err.HTTPCode <= 0
 }
 
 func (ioWorker *IoWorker) canRetry(producerBatch *ProducerBatch, err *sls.Error) bool {
@@ -117,8 +204,20 @@ func (ioWorker *IoWorker) closeSendTask(ioWorkerWaitGroup *sync.WaitGroup) {
 	ioWorkerWaitGroup.Done()
 }
 
-func (ioWorker *IoWorker) startSendTask(ioWorkerWaitGroup *sync.WaitGroup) {
+// startSendTask waits for an idle io worker, it returns false if abandoned is closed before that.
+func (ioWorker *IoWorker) startSendTask(ioWorkerWaitGroup *sync.WaitGroup, abandoned <-chan struct{}) bool {
+	select {
+	case <-abandoned:
+		return false
+	default:
+	}
 	atomic.AddInt64(&ioWorker.taskCount, 1)
-	ioWorker.maxIoWorker <- 1
+	select {
+	case ioWorker.maxIoWorker <- 1:
+	case <-abandoned:
+		atomic.AddInt64(&ioWorker.taskCount, -1)
+		return false
+	}
 	ioWorkerWaitGroup.Add(1)
+	return true
 }
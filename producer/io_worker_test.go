@@ -0,0 +1,21 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSlsError(t *testing.T) {
+	err := parseSlsError(fmt.Errorf("send request: %w", context.DeadlineExceeded))
+	assert.Equal(t, int32(0), err.HTTPCode)
+	assert.Equal(t, "", err.Code)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	slsError := &sls.Error{HTTPCode: 500, Code: "InternalServerError"}
+	assert.Same(t, slsError, parseSlsError(fmt.Errorf("post logs: %w", slsError)))
+}
@@ -44,57 +44,63 @@ func (logAccumulator *LogAccumulator) addLogToProducerBatch(project, logstore, s
 		return errors.New("Producer has started and shut down and cannot write to new logs")
 	}
 	if log, ok := logData.(*sls.Log); ok {
-		logAccumulator.addLog(project, logstore, shardHash, logTopic, logSource, log, callback)
-		return nil
+		return logAccumulator.addLog(project, logstore, shardHash, logTopic, logSource, log, callback)
 	}
 	if logList, ok := logData.([]*sls.Log); ok {
-		logAccumulator.addLogList(project, logstore, shardHash, logTopic, logSource, logList, callback)
-		return nil
+		return logAccumulator.addLogList(project, logstore, shardHash, logTopic, logSource, logList, callback)
 	}
 	level.Error(logAccumulator.logger).Log("msg", "Invalid logType")
 	return errors.New("invalid logType")
 }
 
 func (logAccumulator *LogAccumulator) addLog(project, logstore, shardHash, logTopic, logSource string,
-	log *sls.Log, callback CallBack) {
+	log *sls.Log, callback CallBack) error {
 	key := logAccumulator.getKeyString(project, logstore, logTopic, shardHash, logSource)
-	logSize := int64(GetLogSizeCalculate(log))
-	atomic.AddInt64(&logAccumulator.producer.producerLogGroupSize, logSize)
 
 	logAccumulator.lock.Lock()
 	producerBatch := logAccumulator.getOrCreateProducerBatch(key, project, logstore, logTopic, logSource, shardHash)
-	producerBatch.addLog(log, logSize, callback)
+	logSize, err := producerBatch.addLog(log, callback)
+	if err != nil {
+		logAccumulator.lock.Unlock()
+		return err
+	}
+	atomic.AddInt64(&logAccumulator.producer.producerLogGroupSize, logSize)
 
 	if !producerBatch.meetSendCondition(logAccumulator.producerConfig) {
 		logAccumulator.lock.Unlock()
-		return
+		return nil
 	}
 
 	logAccumulator.logGroupData[key] = nil
 	logAccumulator.lock.Unlock()
 
 	logAccumulator.threadPool.addTask(producerBatch)
+	return nil
 }
 
 func (logAccumulator *LogAccumulator) addLogList(project, logstore, shardHash, logTopic, logSource string,
-	logList []*sls.Log, callback CallBack) {
+	logList []*sls.Log, callback CallBack) error {
 	key := logAccumulator.getKeyString(project, logstore, logTopic, shardHash, logSource)
-	logListSize := int64(GetLogListSize(logList))
-	atomic.AddInt64(&logAccumulator.producer.producerLogGroupSize, logListSize)
 
 	logAccumulator.lock.Lock()
 	producerBatch := logAccumulator.getOrCreateProducerBatch(key, project, logstore, logTopic, logSource, shardHash)
-	producerBatch.addLogList(logList, logListSize, callback)
+	logListSize, err := producerBatch.addLogList(logList, callback)
+	if err != nil {
+		logAccumulator.lock.Unlock()
+		return err
+	}
+	atomic.AddInt64(&logAccumulator.producer.producerLogGroupSize, logListSize)
 
 	if !producerBatch.meetSendCondition(logAccumulator.producerConfig) {
 		logAccumulator.lock.Unlock()
-		return
+		return nil
 	}
 
 	logAccumulator.logGroupData[key] = nil
 	logAccumulator.lock.Unlock()
 
 	logAccumulator.threadPool.addTask(producerBatch)
+	return nil
 }
 
 func (logAccumulator *LogAccumulator) getOrCreateProducerBatch(key, project, logstore, logTopic, logSource, shardHash string) *ProducerBatch {
@@ -104,7 +110,9 @@ func (logAccumulator *LogAccumulator) getOrCreateProducerBatch(key, project, log
 
 	logAccumulator.producer.monitor.incCreateBatch()
 	batch := newProducerBatch(logAccumulator.packIdGenrator, project, logstore, logTopic, logSource, shardHash, logAccumulator.producerConfig)
+	atomic.AddInt64(&logAccumulator.producer.producerLogGroupSize, batch.totalDataSize)
 	logAccumulator.logGroupData[key] = batch
+	logAccumulator.producer.trackBatch(batch)
 	return batch
 }
 
@@ -0,0 +1,153 @@
+package producer
+
+import (
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliyun/aliyun-log-go-sdk/internal"
+)
+
+// LogstoreStats is the cumulative statistics of batches sent to a logstore
+// since the producer was created.
+type LogstoreStats struct {
+	Project  string
+	Logstore string
+
+	SendSuccessCount int64 // batches sent successfully
+	SendFailCount    int64 // batches failed and will not be retried
+	RetryCount       int64 // failed attempts that will be retried
+	SuccessLogCount  int64
+	FailLogCount     int64
+	SendCount        int64         // attempts of sending, including retries
+	SendLatencySum   time.Duration // sum of latency of all attempts
+}
+
+// ProducerStats is the cumulative statistics of a producer.
+type ProducerStats struct {
+	Logstores []LogstoreStats // sorted by project and logstore
+
+	CreateBatchCount     int64
+	WaitMemoryCount      int64 // times SendLog blocked because of TotalSizeLnBytes
+	WaitMemoryLatencySum time.Duration
+	WaitMemoryFailCount  int64 // times SendLog failed with TimeoutExecption
+
+	PendingBytes    int64 // size of logs not sent or failed yet
+	MaxPendingBytes int64 // TotalSizeLnBytes
+
+	SpillCount int64 // batches spilled to SpillDir
+	SpillBytes int64 // size of segments in SpillDir
+
+	DropLogCount int64 // logs dropped by OverflowPolicy
+}
+
+// Stats returns the cumulative statistics of the producer, unlike the runtime metrics
+// printed to local log, it is never reset and is suitable for exporting to monitoring systems.
+func (producer *Producer) Stats() ProducerStats {
+	m := producer.monitor
+	stats := ProducerStats{
+		CreateBatchCount:     m.createBatch.Load(),
+		WaitMemoryCount:      m.waitMemory.Count.Load(),
+		WaitMemoryLatencySum: time.Duration(m.waitMemory.Sum.Load()) * time.Microsecond,
+		WaitMemoryFailCount:  m.waitMemoryFailCount.Load(),
+		PendingBytes:         atomic.LoadInt64(&producer.producerLogGroupSize),
+		MaxPendingBytes:      producer.producerConfig.TotalSizeLnBytes,
+		SpillCount:           m.spillCount.Load(),
+		SpillBytes:           producer.spill.size(),
+		DropLogCount:         m.dropLogCount.Load(),
+	}
+	m.logstores.Range(func(key, value interface{}) bool {
+		metrics := value.(*logstoreMetrics)
+		stats.Logstores = append(stats.Logstores, LogstoreStats{
+			Project:          metrics.project,
+			Logstore:         metrics.logstore,
+			SendSuccessCount: metrics.successCount.Load(),
+			SendFailCount:    metrics.failCount.Load(),
+			RetryCount:       metrics.retryCount.Load(),
+			SuccessLogCount:  metrics.successLogCount.Load(),
+			FailLogCount:     metrics.failLogCount.Load(),
+			SendCount:        metrics.sendBatch.Count.Load(),
+			SendLatencySum:   time.Duration(metrics.sendBatch.Sum.Load()) * time.Microsecond,
+		})
+		return true
+	})
+	sort.Slice(stats.Logstores, func(i, j int) bool {
+		if stats.Logstores[i].Project != stats.Logstores[j].Project {
+			return stats.Logstores[i].Project < stats.Logstores[j].Project
+		}
+		return stats.Logstores[i].Logstore < stats.Logstores[j].Logstore
+	})
+	return stats
+}
+
+// MetricsHandler returns a http.Handler serving Stats in the prometheus text
+// exposition format, metric names are prefixed with "sls_producer_".
+// It is the only prometheus integration, the sdk does not depend on the prometheus
+// client and provides no prometheus.Collector, so the handler is scraped directly.
+//
+//	http.Handle("/metrics/producer", producer.MetricsHandler())
+func (producer *Producer) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		internal.WritePrometheusText(w, producer.Stats().metricFamilies())
+	})
+}
+
+var logstoreLabelNames = []string{"project", "logstore"}
+
+var (
+	sendSuccessDesc    = &internal.MetricDesc{Name: "sls_producer_send_success_total", Help: "Batches sent successfully.", Type: "counter", LabelNames: logstoreLabelNames}
+	sendFailDesc       = &internal.MetricDesc{Name: "sls_producer_send_fail_total", Help: "Batches failed and not retried.", Type: "counter", LabelNames: logstoreLabelNames}
+	sendRetryDesc      = &internal.MetricDesc{Name: "sls_producer_send_retry_total", Help: "Failed attempts that are retried.", Type: "counter", LabelNames: logstoreLabelNames}
+	successLogsDesc    = &internal.MetricDesc{Name: "sls_producer_success_logs_total", Help: "Logs sent successfully.", Type: "counter", LabelNames: logstoreLabelNames}
+	failLogsDesc       = &internal.MetricDesc{Name: "sls_producer_fail_logs_total", Help: "Logs failed to send.", Type: "counter", LabelNames: logstoreLabelNames}
+	sendLatencyDesc    = &internal.MetricDesc{Name: "sls_producer_send_latency_seconds", Help: "Latency of each attempt of sending a batch to server, retries are sampled separately.", Type: "summary", LabelNames: logstoreLabelNames}
+	createBatchDesc    = &internal.MetricDesc{Name: "sls_producer_create_batch_total", Help: "Batches created.", Type: "counter"}
+	waitMemoryDesc     = &internal.MetricDesc{Name: "sls_producer_wait_memory_seconds", Help: "Time blocked in SendLog waiting for memory.", Type: "summary"}
+	waitMemoryFailDesc = &internal.MetricDesc{Name: "sls_producer_wait_memory_fail_total", Help: "SendLog calls failed after waiting for memory.", Type: "counter"}
+	pendingBytesDesc   = &internal.MetricDesc{Name: "sls_producer_pending_bytes", Help: "Size of logs buffered and not sent yet.", Type: "gauge"}
+	maxPendingDesc     = &internal.MetricDesc{Name: "sls_producer_max_pending_bytes", Help: "Max size of logs that can be buffered, TotalSizeLnBytes.", Type: "gauge"}
+	spillDesc          = &internal.MetricDesc{Name: "sls_producer_spill_total", Help: "Batches spilled to disk.", Type: "counter"}
+	dropLogsDesc       = &internal.MetricDesc{Name: "sls_producer_drop_logs_total", Help: "Logs dropped because producer is out of memory.", Type: "counter"}
+	spillBytesDesc     = &internal.MetricDesc{Name: "sls_producer_spill_bytes", Help: "Size of batches spilled to disk and not sent yet.", Type: "gauge"}
+
+	producerMetricDescs = []*internal.MetricDesc{sendSuccessDesc, sendFailDesc, sendRetryDesc, successLogsDesc, failLogsDesc,
+		sendLatencyDesc, createBatchDesc, waitMemoryDesc, waitMemoryFailDesc, pendingBytesDesc, maxPendingDesc,
+		spillDesc, dropLogsDesc, spillBytesDesc}
+)
+
+func (stats ProducerStats) metricFamilies() []internal.MetricFamily {
+	logstoreFamily := func(desc *internal.MetricDesc, value func(s *LogstoreStats) internal.MetricSample) internal.MetricFamily {
+		family := internal.MetricFamily{Desc: desc}
+		for i := range stats.Logstores {
+			s := &stats.Logstores[i]
+			sample := value(s)
+			sample.LabelValues = []string{s.Project, s.Logstore}
+			family.Samples = append(family.Samples, sample)
+		}
+		return family
+	}
+	valueOf := func(value float64) internal.MetricSample { return internal.MetricSample{Value: value} }
+	single := func(desc *internal.MetricDesc, sample internal.MetricSample) internal.MetricFamily {
+		return internal.MetricFamily{Desc: desc, Samples: []internal.MetricSample{sample}}
+	}
+	return []internal.MetricFamily{
+		logstoreFamily(sendSuccessDesc, func(s *LogstoreStats) internal.MetricSample { return valueOf(float64(s.SendSuccessCount)) }),
+		logstoreFamily(sendFailDesc, func(s *LogstoreStats) internal.MetricSample { return valueOf(float64(s.SendFailCount)) }),
+		logstoreFamily(sendRetryDesc, func(s *LogstoreStats) internal.MetricSample { return valueOf(float64(s.RetryCount)) }),
+		logstoreFamily(successLogsDesc, func(s *LogstoreStats) internal.MetricSample { return valueOf(float64(s.SuccessLogCount)) }),
+		logstoreFamily(failLogsDesc, func(s *LogstoreStats) internal.MetricSample { return valueOf(float64(s.FailLogCount)) }),
+		logstoreFamily(sendLatencyDesc, func(s *LogstoreStats) internal.MetricSample {
+			return internal.MetricSample{Value: s.SendLatencySum.Seconds(), Count: uint64(s.SendCount)}
+		}),
+		single(createBatchDesc, valueOf(float64(stats.CreateBatchCount))),
+		single(waitMemoryDesc, internal.MetricSample{Value: stats.WaitMemoryLatencySum.Seconds(), Count: uint64(stats.WaitMemoryCount)}),
+		single(waitMemoryFailDesc, valueOf(float64(stats.WaitMemoryFailCount))),
+		single(pendingBytesDesc, valueOf(float64(stats.PendingBytes))),
+		single(maxPendingDesc, valueOf(float64(stats.MaxPendingBytes))),
+		single(spillDesc, valueOf(float64(stats.SpillCount))),
+		single(dropLogsDesc, valueOf(float64(stats.DropLogCount))),
+		single(spillBytesDesc, valueOf(float64(stats.SpillBytes))),
+	}
+}
@@ -0,0 +1,58 @@
+package producer
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducerStats(t *testing.T) {
+	config := GetDefaultProducerConfig()
+	client := sls.CreateNormalInterfaceV2("127.0.0.1:1", sls.NewStaticCredentialsProvider("", "", ""))
+	producer := createProducerInternal(client, config, log.NewNopLogger())
+
+	batch := newProducerBatch(nil, "my-project", "my-store", "", "", "", config)
+	batch.addLog(GenerateLog(uint32(time.Now().Unix()), map[string]string{"k": "v"}), nil)
+	begin := time.Now()
+	producer.monitor.incCreateBatch()
+	producer.monitor.recordRetry(batch, time.Millisecond)
+	producer.monitor.recordSuccess(batch, begin, begin.Add(time.Second))
+	other := newProducerBatch(nil, "my-project", "another-store", "", "", "", config)
+	producer.monitor.recordFailure(other, begin, begin.Add(time.Second))
+
+	stats := producer.Stats()
+	assert.Equal(t, int64(1), stats.CreateBatchCount)
+	assert.Equal(t, config.TotalSizeLnBytes, stats.MaxPendingBytes)
+	assert.Len(t, stats.Logstores, 2)
+	assert.Equal(t, LogstoreStats{
+		Project:        "my-project",
+		Logstore:       "another-store",
+		SendFailCount:  1,
+		SendCount:      1,
+		SendLatencySum: time.Second,
+	}, stats.Logstores[0])
+	assert.Equal(t, "my-store", stats.Logstores[1].Logstore)
+	assert.Equal(t, int64(1), stats.Logstores[1].SendSuccessCount)
+	assert.Equal(t, int64(1), stats.Logstores[1].RetryCount)
+	assert.Equal(t, int64(1), stats.Logstores[1].SuccessLogCount)
+	assert.Equal(t, int64(2), stats.Logstores[1].SendCount)
+
+	// cumulative stats survive the periodic report
+	producer.monitor.getAndResetMetrics()
+	assert.Equal(t, stats, producer.Stats())
+
+	recorder := httptest.NewRecorder()
+	producer.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	assert.Contains(t, body, "# TYPE sls_producer_send_success_total counter\n")
+	assert.Contains(t, body, `sls_producer_send_success_total{project="my-project",logstore="my-store"} 1`+"\n")
+	assert.Contains(t, body, `sls_producer_send_latency_seconds_sum{project="my-project",logstore="another-store"} 1`+"\n")
+	assert.Contains(t, body, `sls_producer_send_latency_seconds_count{project="my-project",logstore="my-store"} 2`+"\n")
+	assert.Contains(t, body, "sls_producer_max_pending_bytes 1.048576e+08\n")
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain"))
+}
@@ -1,6 +1,7 @@
 package producer
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -19,10 +20,32 @@ type ProducerMetrics struct {
 
 	waitMemory          internal.TimeHistogram
 	waitMemoryFailCount atomic.Int32
+	spillCount          atomic.Int32
+	dropLogCount        atomic.Int32
 }
 
 type ProducerMonitor struct {
 	metrics atomic.Value // *ProducerMetrics
+
+	// cumulative metrics since the producer created, never reset
+	createBatch         atomic.Int64
+	waitMemory          internal.TimeHistogram
+	waitMemoryFailCount atomic.Int64
+	spillCount          atomic.Int64
+	dropLogCount        atomic.Int64
+	logstores           sync.Map // map[string]*logstoreMetrics
+}
+
+type logstoreMetrics struct {
+	project  string
+	logstore string
+
+	sendBatch       internal.TimeHistogram
+	successCount    atomic.Int64
+	failCount       atomic.Int64
+	retryCount      atomic.Int64
+	successLogCount atomic.Int64
+	failLogCount    atomic.Int64
 }
 
 func newProducerMonitor() *ProducerMonitor {
@@ -31,37 +54,75 @@ func newProducerMonitor() *ProducerMonitor {
 	return m
 }
 
-func (m *ProducerMonitor) recordSuccess(sendBegin time.Time, sendEnd time.Time) {
+func (m *ProducerMonitor) getLogstoreMetrics(project, logstore string) *logstoreMetrics {
+	key := project + Delimiter + logstore
+	if v, ok := m.logstores.Load(key); ok {
+		return v.(*logstoreMetrics)
+	}
+	v, _ := m.logstores.LoadOrStore(key, &logstoreMetrics{project: project, logstore: logstore})
+	return v.(*logstoreMetrics)
+}
+
+func (m *ProducerMonitor) recordSuccess(batch *ProducerBatch, sendBegin time.Time, sendEnd time.Time) {
 	metrics := m.metrics.Load().(*ProducerMetrics)
 	metrics.sendBatch.AddSample(float64(sendEnd.Sub(sendBegin).Microseconds()))
 	metrics.onSuccess.AddSample(float64(time.Since(sendEnd).Microseconds()))
+
+	total := m.getLogstoreMetrics(batch.getProject(), batch.getLogstore())
+	total.sendBatch.AddSample(float64(sendEnd.Sub(sendBegin).Microseconds()))
+	total.successCount.Add(1)
+	total.successLogCount.Add(int64(batch.logCount()))
 }
 
-func (m *ProducerMonitor) recordFailure(sendBegin time.Time, sendEnd time.Time) {
+func (m *ProducerMonitor) recordFailure(batch *ProducerBatch, sendBegin time.Time, sendEnd time.Time) {
 	metrics := m.metrics.Load().(*ProducerMetrics)
 	metrics.sendBatch.AddSample(float64(sendEnd.Sub(sendBegin).Microseconds()))
 	metrics.onFail.AddSample(float64(time.Since(sendEnd).Microseconds()))
+
+	total := m.getLogstoreMetrics(batch.getProject(), batch.getLogstore())
+	total.sendBatch.AddSample(float64(sendEnd.Sub(sendBegin).Microseconds()))
+	total.failCount.Add(1)
+	total.failLogCount.Add(int64(batch.logCount()))
 }
 
-func (m *ProducerMonitor) recordRetry(sendCost time.Duration) {
+func (m *ProducerMonitor) recordRetry(batch *ProducerBatch, sendCost time.Duration) {
 	metrics := m.metrics.Load().(*ProducerMetrics)
 	metrics.sendBatch.AddSample(float64(sendCost.Microseconds()))
 	metrics.retryCount.Add(1)
+
+	total := m.getLogstoreMetrics(batch.getProject(), batch.getLogstore())
+	total.sendBatch.AddSample(float64(sendCost.Microseconds()))
+	total.retryCount.Add(1)
 }
 
 func (m *ProducerMonitor) recordWaitMemory(start time.Time) {
 	metrics := m.metrics.Load().(*ProducerMetrics)
 	metrics.waitMemory.AddSample(float64(time.Since(start).Microseconds()))
+	m.waitMemory.AddSample(float64(time.Since(start).Microseconds()))
 }
 
 func (m *ProducerMonitor) incWaitMemoryFail() {
 	metrics := m.metrics.Load().(*ProducerMetrics)
 	metrics.waitMemoryFailCount.Add(1)
+	m.waitMemoryFailCount.Add(1)
 }
 
 func (m *ProducerMonitor) incCreateBatch() {
 	metrics := m.metrics.Load().(*ProducerMetrics)
 	metrics.createBatch.Add(1)
+	m.createBatch.Add(1)
+}
+
+func (m *ProducerMonitor) incSpill() {
+	metrics := m.metrics.Load().(*ProducerMetrics)
+	metrics.spillCount.Add(1)
+	m.spillCount.Add(1)
+}
+
+func (m *ProducerMonitor) incDrop(logs int) {
+	metrics := m.metrics.Load().(*ProducerMetrics)
+	metrics.dropLogCount.Add(int32(logs))
+	m.dropLogCount.Add(int64(logs))
 }
 
 func (m *ProducerMonitor) getAndResetMetrics() *ProducerMetrics {
@@ -83,6 +144,8 @@ func (m *ProducerMonitor) reportThread(reportInterval time.Duration, logger log.
 			"onFail", metrics.onFail.String(),
 			"waitMemory", metrics.waitMemory.String(),
 			"waitMemoryFailCount", metrics.waitMemoryFailCount.Load(),
+			"spillCount", metrics.spillCount.Load(),
+			"dropLogCount", metrics.dropLogCount.Load(),
 		)
 	}
 }
@@ -89,8 +89,11 @@ func (mover *Mover) clearEmptyKeys() {
 func (mover *Mover) sendRemaining() {
 	mover.logAccumulator.lock.Lock()
 	for _, batch := range mover.logAccumulator.logGroupData {
-		if batch != nil && batch.totalDataSize > 0 {
+		if batch != nil && batch.logCount() > 0 {
 			mover.threadPool.addTask(batch)
+		} else if batch != nil {
+			mover.ioWorker.producer.releaseMemory(batch.totalDataSize)
+			mover.ioWorker.producer.finishBatch(batch)
 		}
 	}
 	mover.logAccumulator.logGroupData = make(map[string]*ProducerBatch)
@@ -0,0 +1,231 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
+)
+
+// OverflowPolicy decides what SendLog does when the logs buffered by the
+// producer exceed TotalSizeLnBytes.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks until memory is freed, up to MaxBlockSec or the deadline of the context.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowFailFast returns ErrProducerMemoryFull at once.
+	OverflowFailFast
+	// OverflowDropNewest drops the logs being sent.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest batches not being sent, and blocks if there is none.
+	OverflowDropOldest
+)
+
+var (
+	// ErrProducerMemoryFull is returned by SendLog with OverflowFailFast if the producer is out of memory.
+	ErrProducerMemoryFull = errors.New("producer buffered logs exceed TotalSizeLnBytes")
+	// ErrLogDropped is the cause of Result.Err of logs dropped by OverflowDropNewest or OverflowDropOldest.
+	ErrLogDropped = errors.New("logs are dropped because producer is out of memory")
+)
+
+// DropCallBack receives the logs dropped by OverflowDropNewest and OverflowDropOldest.
+type DropCallBack = func(project, logstore string, logGroup *sls.LogGroup)
+
+// producerMemory wakes up senders blocked on memory when batches release it.
+type producerMemory struct {
+	waiters int32
+	lock    sync.Mutex
+	freed   chan struct{} // closed and replaced whenever memory is released
+}
+
+func newProducerMemory() *producerMemory {
+	return &producerMemory{freed: make(chan struct{})}
+}
+
+func (memory *producerMemory) freedChan() <-chan struct{} {
+	memory.lock.Lock()
+	defer memory.lock.Unlock()
+	return memory.freed
+}
+
+func (memory *producerMemory) notify() {
+	if atomic.LoadInt32(&memory.waiters) == 0 {
+		return
+	}
+	memory.lock.Lock()
+	close(memory.freed)
+	memory.freed = make(chan struct{})
+	memory.lock.Unlock()
+}
+
+func (producer *Producer) memoryExceeded() bool {
+	return atomic.LoadInt64(&producer.producerLogGroupSize) > producer.producerConfig.TotalSizeLnBytes
+}
+
+// releaseMemory removes size from the buffered logs and wakes up blocked senders.
+func (producer *Producer) releaseMemory(size int64) {
+	atomic.AddInt64(&producer.producerLogGroupSize, -size)
+	producer.memory.notify()
+}
+
+// waitTime waits for memory with the deadline of MaxBlockSec.
+func (producer *Producer) waitTime() error {
+	if !producer.memoryExceeded() {
+		return nil
+	}
+	ctx := context.Background()
+	if producer.producerConfig.MaxBlockSec >= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(producer.producerConfig.MaxBlockSec)*time.Second)
+		defer cancel()
+	}
+	err := producer.waitMemory(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		level.Error(producer.logger).Log("msg", "Over producer set maximum blocking time")
+		return errors.New(TimeoutExecption)
+	}
+	return err
+}
+
+// waitMemory waits until the buffered logs are below TotalSizeLnBytes as OverflowPolicy
+// configures, it returns errDropNewest if the logs being sent should be dropped.
+func (producer *Producer) waitMemory(ctx context.Context) error {
+	if !producer.memoryExceeded() {
+		return nil
+	}
+	switch producer.producerConfig.OverflowPolicy {
+	case OverflowFailFast:
+		producer.monitor.incWaitMemoryFail()
+		return ErrProducerMemoryFull
+	case OverflowDropNewest:
+		return errDropNewest
+	case OverflowDropOldest:
+		for producer.memoryExceeded() && producer.dropOldestBatch() {
+		}
+		if !producer.memoryExceeded() {
+			return nil
+		}
+	}
+
+	memory := producer.memory
+	atomic.AddInt32(&memory.waiters, 1)
+	defer atomic.AddInt32(&memory.waiters, -1)
+	defer producer.monitor.recordWaitMemory(time.Now())
+	for {
+		// get the channel before checking, so that memory freed in between is not missed
+		freed := memory.freedChan()
+		if !producer.memoryExceeded() {
+			return nil
+		}
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			producer.monitor.incWaitMemoryFail()
+			return ctx.Err()
+		}
+	}
+}
+
+var errDropNewest = errors.New("drop newest logs")
+
+// send adds logData to a batch once waitErr, the result of waiting for memory, allows it.
+// shardHash is nil if logData is not sent with a hash key.
+func (producer *Producer) send(waitErr error, project, logstore string, shardHash *string, topic, source string,
+	logData interface{}, callback CallBack) error {
+	if waitErr == errDropNewest {
+		producer.dropNewest(project, logstore, topic, source, logData, callback)
+		return nil
+	}
+	if waitErr != nil {
+		return waitErr
+	}
+	hash := ""
+	if shardHash != nil {
+		hash = *shardHash
+		if producer.shardRouter != nil {
+			hashKey := hash
+			if producer.producerConfig.AdjustShargHash {
+				hashKey = hashKeyOf(hash)
+			}
+			if beginKey, ok := producer.shardRouter.route(project, logstore, hashKey); ok {
+				return producer.logAccumulator.addLogToProducerBatch(project, logstore, beginKey, topic, source, logData, callback)
+			}
+		}
+		if producer.producerConfig.AdjustShargHash {
+			var err error
+			if hash, err = AdjustHash(hash, producer.buckets); err != nil {
+				return err
+			}
+		}
+	}
+	return producer.logAccumulator.addLogToProducerBatch(project, logstore, hash, topic, source, logData, callback)
+}
+
+func (producer *Producer) dropNewest(project, logstore, topic, source string, logData interface{}, callback CallBack) {
+	var logs []*sls.Log
+	switch data := logData.(type) {
+	case *sls.Log:
+		logs = []*sls.Log{data}
+	case []*sls.Log:
+		logs = data
+	}
+	level.Warn(producer.logger).Log("msg", "drop logs because producer is out of memory", "project", project,
+		"logstore", logstore, "logs", len(logs))
+	producer.monitor.incDrop(len(logs))
+	if producer.producerConfig.OnDrop != nil {
+		producer.producerConfig.OnDrop(project, logstore, &sls.LogGroup{Topic: &topic, Source: &source, Logs: logs})
+	}
+	if callback != nil {
+		result := initResult()
+		result.err = sls.NewClientError(ErrLogDropped)
+		callback.Fail(result)
+	}
+}
+
+// dropOldestBatch drops the oldest batch in the accumulator or the retry queue,
+// it returns false if there is none.
+func (producer *Producer) dropOldestBatch() bool {
+	logAccumulator := producer.logAccumulator
+	logAccumulator.lock.Lock()
+	var oldestKey string
+	var oldest *ProducerBatch
+	for key, batch := range logAccumulator.logGroupData {
+		if batch != nil && (oldest == nil || batch.createTimeMs < oldest.createTimeMs) {
+			oldestKey, oldest = key, batch
+		}
+	}
+	before := int64(math.MaxInt64)
+	if oldest != nil {
+		before = oldest.createTimeMs
+	}
+	if retryBatch := producer.mover.retryQueue.removeOldest(before); retryBatch != nil {
+		oldest = retryBatch
+	} else if oldest != nil {
+		logAccumulator.logGroupData[oldestKey] = nil
+	}
+	logAccumulator.lock.Unlock()
+	if oldest == nil {
+		return false
+	}
+
+	level.Warn(producer.logger).Log("msg", "drop oldest batch because producer is out of memory",
+		"project", oldest.getProject(), "logstore", oldest.getLogstore(), "logs", oldest.logCount())
+	producer.monitor.incDrop(oldest.logCount())
+	if producer.producerConfig.OnDrop != nil {
+		logGroup := &sls.LogGroup{}
+		if err := proto.Unmarshal(oldest.logGroupBytes(), logGroup); err == nil {
+			producer.producerConfig.OnDrop(oldest.getProject(), oldest.getLogstore(), logGroup)
+		}
+	}
+	oldest.failWith(ErrLogDropped)
+	producer.releaseMemory(oldest.totalDataSize)
+	producer.finishBatch(oldest)
+	return true
+}
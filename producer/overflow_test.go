@@ -0,0 +1,102 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOverflowTestProducer(policy OverflowPolicy) (*Producer, *[]*sls.LogGroup) {
+	config := GetDefaultProducerConfig()
+	config.TotalSizeLnBytes = 100
+	config.OverflowPolicy = policy
+	dropped := &[]*sls.LogGroup{}
+	config.OnDrop = func(project, logstore string, logGroup *sls.LogGroup) {
+		*dropped = append(*dropped, logGroup)
+	}
+	client := sls.CreateNormalInterfaceV2("127.0.0.1:1", sls.NewStaticCredentialsProvider("", "", ""))
+	return createProducerInternal(client, config, log.NewNopLogger()), dropped
+}
+
+func TestOverflowBlock(t *testing.T) {
+	producer, _ := newOverflowTestProducer(OverflowBlock)
+	producer.producerLogGroupSize = 200
+	log := GenerateLog(1700000000, map[string]string{"k": "v"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := producer.SendLogCtx(ctx, "project", "logstore", "topic", "source", log, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	producer.producerConfig.MaxBlockSec = 0
+	assert.EqualError(t, producer.SendLog("project", "logstore", "topic", "source", log), TimeoutExecption)
+
+	done := make(chan error)
+	go func() {
+		done <- producer.SendLogCtx(context.Background(), "project", "logstore", "topic", "source", log, nil)
+	}()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&producer.memory.waiters) == 1 }, time.Second, time.Millisecond)
+	producer.releaseMemory(50)
+	select {
+	case <-done:
+		t.Fatal("memory is still exceeded")
+	case <-time.After(10 * time.Millisecond):
+	}
+	producer.releaseMemory(100)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("sender is not woken up")
+	}
+	assert.Equal(t, int64(2), producer.Stats().WaitMemoryFailCount)
+}
+
+func TestOverflowFailFast(t *testing.T) {
+	producer, _ := newOverflowTestProducer(OverflowFailFast)
+	producer.producerLogGroupSize = 200
+	log := GenerateLog(1700000000, map[string]string{"k": "v"})
+	err := producer.SendLogCtx(context.Background(), "project", "logstore", "topic", "source", log, nil)
+	assert.True(t, errors.Is(err, ErrProducerMemoryFull))
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	producer, dropped := newOverflowTestProducer(OverflowDropNewest)
+	producer.producerLogGroupSize = 200
+	log := GenerateLog(1700000000, map[string]string{"k": "v"})
+	callback := &spillTestCallback{results: make(chan *Result, 1)}
+	require.NoError(t, producer.SendLogListCtx(context.Background(), "project", "logstore", "topic", "source", []*sls.Log{log, log}, callback))
+	result := <-callback.results
+	assert.True(t, errors.Is(result.Err(), ErrLogDropped))
+	require.Len(t, *dropped, 1)
+	assert.Equal(t, "topic", (*dropped)[0].GetTopic())
+	assert.Len(t, (*dropped)[0].Logs, 2)
+	assert.Empty(t, producer.logAccumulator.logGroupData)
+	assert.Equal(t, int64(2), producer.Stats().DropLogCount)
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	producer, dropped := newOverflowTestProducer(OverflowDropOldest)
+	first := GenerateLog(1700000000, map[string]string{"k": "first"})
+	second := GenerateLog(1700000000, map[string]string{"k": "second"})
+	require.NoError(t, producer.SendLog("project", "logstore", "first", "source", first))
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, producer.SendLog("project", "logstore", "second", "source", second))
+	producer.producerLogGroupSize = producer.producerConfig.TotalSizeLnBytes + 1
+
+	callback := &spillTestCallback{results: make(chan *Result, 1)}
+	require.NoError(t, producer.SendLogCtx(context.Background(), "project", "logstore", "third", "source", first, callback))
+	require.Len(t, *dropped, 1)
+	assert.Equal(t, "first", (*dropped)[0].GetTopic())
+	assert.Equal(t, first.String(), (*dropped)[0].Logs[0].String())
+	assert.Nil(t, producer.logAccumulator.logGroupData["project|logstore|first||source"])
+	assert.NotNil(t, producer.logAccumulator.logGroupData["project|logstore|second||source"])
+	assert.NotNil(t, producer.logAccumulator.logGroupData["project|logstore|third||source"])
+	assert.Empty(t, callback.results)
+}
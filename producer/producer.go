@@ -1,6 +1,7 @@
 package producer
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"sync/atomic"
@@ -16,6 +17,9 @@ const (
 	IllegalStateException = "IllegalStateException"
 )
 
+// ErrProducerClosed is the cause of Result.Err of a batch not sent yet when Close times out.
+var ErrProducerClosed = errors.New("producer is closed before the batch is sent")
+
 type Producer struct {
 	producerConfig        *ProducerConfig
 	logAccumulator        *LogAccumulator
@@ -28,6 +32,11 @@ type Producer struct {
 	logger                log.Logger
 	producerLogGroupSize  int64
 	monitor               *ProducerMonitor
+	spill                 *spillQueue // nil if SpillDir is not set
+	memory                *producerMemory
+	batchesLock           sync.Mutex
+	batches               map[*ProducerBatch]struct{} // batches not sent, spilled or failed for good yet
+	shardRouter           *shardRouter                // nil if ShardRouting is false
 }
 
 func NewProducer(producerConfig *ProducerConfig) (*Producer, error) {
@@ -38,7 +47,13 @@ func NewProducer(producerConfig *ProducerConfig) (*Producer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return createProducerInternal(client, finalProducerConfig, logger), nil
+	producer := createProducerInternal(client, finalProducerConfig, logger)
+	if finalProducerConfig.SpillDir != "" {
+		if producer.spill, err = openSpillQueue(finalProducerConfig, logger); err != nil {
+			return nil, err
+		}
+	}
+	return producer, nil
 }
 
 // Deprecated: use NewProducer instead.
@@ -47,7 +62,15 @@ func InitProducer(producerConfig *ProducerConfig) *Producer {
 	finalProducerConfig := validateProducerConfig(producerConfig, logger)
 
 	client, _ := createClient(finalProducerConfig, true, logger)
-	return createProducerInternal(client, finalProducerConfig, logger)
+	producer := createProducerInternal(client, finalProducerConfig, logger)
+	if finalProducerConfig.SpillDir != "" {
+		spill, err := openSpillQueue(finalProducerConfig, logger)
+		if err != nil {
+			level.Error(logger).Log("msg", "Failed to open spill directory, spilling is disabled.", "error", err)
+		}
+		producer.spill = spill
+	}
+	return producer
 }
 
 func createProducerInternal(client sls.ClientInterface, finalProducerConfig *ProducerConfig, logger log.Logger) *Producer {
@@ -77,6 +100,12 @@ func createProducerInternal(client sls.ClientInterface, finalProducerConfig *Pro
 	producer.ioThreadPoolWaitGroup = &sync.WaitGroup{}
 	producer.logger = logger
 	producer.monitor = newProducerMonitor()
+	producer.memory = newProducerMemory()
+	producer.batches = make(map[*ProducerBatch]struct{})
+	if finalProducerConfig.ShardRouting {
+		interval := time.Duration(finalProducerConfig.ShardRefreshIntervalMs) * time.Millisecond
+		producer.shardRouter = newShardRouter(client, interval, logger)
+	}
 	return producer
 }
 
@@ -93,6 +122,9 @@ func configureClient(client sls.ClientInterface, producerConfig *ProducerConfig)
 	if producerConfig.UserAgent != "" {
 		client.SetUserAgent(producerConfig.UserAgent)
 	}
+	if producerConfig.Tracer != nil {
+		sls.UseInterceptors(client, sls.NewTracingInterceptor(producerConfig.Tracer))
+	}
 }
 
 func createClient(producerConfig *ProducerConfig, allowStsFallback bool, logger log.Logger) (sls.ClientInterface, error) {
@@ -143,143 +175,65 @@ func validateProducerConfig(producerConfig *ProducerConfig, logger log.Logger) *
 		level.Warn(logger).Log("msg", "The LingerMs parameter cannot be less than 100 milliseconds and has been reset to the default value of 2000 milliseconds")
 		producerConfig.LingerMs = 2000
 	}
+	if producerConfig.ShardRefreshIntervalMs <= 0 {
+		producerConfig.ShardRefreshIntervalMs = 60 * 1000
+	}
+	if producerConfig.SpillDir != "" {
+		if producerConfig.SpillMaxBytes <= 0 {
+			producerConfig.SpillMaxBytes = 1024 * 1024 * 1024
+		}
+		if producerConfig.SpillSegmentBytes <= 0 {
+			producerConfig.SpillSegmentBytes = 64 * 1024 * 1024
+		}
+	}
 	return producerConfig
 }
 
 func (producer *Producer) HashSendLogWithCallBack(project, logstore, shardHash, topic, source string, log *sls.Log, callback CallBack) error {
-	err := producer.waitTime()
-	if err != nil {
-		return err
-	}
-	if producer.producerConfig.AdjustShargHash {
-		shardHash, err = AdjustHash(shardHash, producer.buckets)
-		if err != nil {
-			return err
-		}
-	}
-	return producer.logAccumulator.addLogToProducerBatch(project, logstore, shardHash, topic, source, log, callback)
+	return producer.send(producer.waitTime(), project, logstore, &shardHash, topic, source, log, callback)
 }
 
 func (producer *Producer) HashSendLogListWithCallBack(project, logstore, shardHash, topic, source string, logList []*sls.Log, callback CallBack) (err error) {
-
-	err = producer.waitTime()
-	if err != nil {
-		return err
-	}
-	if producer.producerConfig.AdjustShargHash {
-		shardHash, err = AdjustHash(shardHash, producer.buckets)
-		if err != nil {
-			return err
-		}
-	}
-	return producer.logAccumulator.addLogToProducerBatch(project, logstore, shardHash, topic, source, logList, callback)
+	return producer.send(producer.waitTime(), project, logstore, &shardHash, topic, source, logList, callback)
 }
 
 func (producer *Producer) SendLog(project, logstore, topic, source string, log *sls.Log) error {
-	err := producer.waitTime()
-	if err != nil {
-		return err
-	}
-	return producer.logAccumulator.addLogToProducerBatch(project, logstore, "", topic, source, log, nil)
+	return producer.send(producer.waitTime(), project, logstore, nil, topic, source, log, nil)
 }
 
 func (producer *Producer) SendLogList(project, logstore, topic, source string, logList []*sls.Log) (err error) {
-	err = producer.waitTime()
-	if err != nil {
-		return err
-	}
-
-	return producer.logAccumulator.addLogToProducerBatch(project, logstore, "", topic, source, logList, nil)
-
+	return producer.send(producer.waitTime(), project, logstore, nil, topic, source, logList, nil)
 }
 
 func (producer *Producer) HashSendLog(project, logstore, shardHash, topic, source string, log *sls.Log) error {
-	err := producer.waitTime()
-	if err != nil {
-		return err
-	}
-	if producer.producerConfig.AdjustShargHash {
-		shardHash, err = AdjustHash(shardHash, producer.buckets)
-		if err != nil {
-			return err
-		}
-	}
-	return producer.logAccumulator.addLogToProducerBatch(project, logstore, shardHash, topic, source, log, nil)
+	return producer.send(producer.waitTime(), project, logstore, &shardHash, topic, source, log, nil)
 }
 
 func (producer *Producer) HashSendLogList(project, logstore, shardHash, topic, source string, logList []*sls.Log) (err error) {
-	err = producer.waitTime()
-	if err != nil {
-		return err
-	}
-	if producer.producerConfig.AdjustShargHash {
-		shardHash, err = AdjustHash(shardHash, producer.buckets)
-		if err != nil {
-			return err
-		}
-	}
-	return producer.logAccumulator.addLogToProducerBatch(project, logstore, shardHash, topic, source, logList, nil)
-
+	return producer.send(producer.waitTime(), project, logstore, &shardHash, topic, source, logList, nil)
 }
 
 func (producer *Producer) SendLogWithCallBack(project, logstore, topic, source string, log *sls.Log, callback CallBack) error {
-	err := producer.waitTime()
-	if err != nil {
-		return err
-	}
-	return producer.logAccumulator.addLogToProducerBatch(project, logstore, "", topic, source, log, callback)
+	return producer.send(producer.waitTime(), project, logstore, nil, topic, source, log, callback)
 }
 
 func (producer *Producer) SendLogListWithCallBack(project, logstore, topic, source string, logList []*sls.Log, callback CallBack) (err error) {
-	err = producer.waitTime()
-	if err != nil {
-		return err
-	}
-	return producer.logAccumulator.addLogToProducerBatch(project, logstore, "", topic, source, logList, callback)
-
+	return producer.send(producer.waitTime(), project, logstore, nil, topic, source, logList, callback)
 }
 
-// todo: refactor this
-func (producer *Producer) waitTime() error {
-	if atomic.LoadInt64(&producer.producerLogGroupSize) <= producer.producerConfig.TotalSizeLnBytes {
-		return nil
-	}
-
-	// no wait
-	if producer.producerConfig.MaxBlockSec == 0 {
-		if atomic.LoadInt64(&producer.producerLogGroupSize) > producer.producerConfig.TotalSizeLnBytes {
-			level.Error(producer.logger).Log("msg", "Over producer set maximum blocking time")
-			return errors.New(TimeoutExecption)
-		}
-		return nil
-	}
-
-	defer producer.monitor.recordWaitMemory(time.Now())
-
-	// infinite wait
-	if producer.producerConfig.MaxBlockSec < 0 {
-		for atomic.LoadInt64(&producer.producerLogGroupSize) > producer.producerConfig.TotalSizeLnBytes {
-			time.Sleep(waitTimeUnit)
-		}
-		return nil
-	}
-
-	// todo: refine this, limited wait
-	for i := 0; i < producer.producerConfig.MaxBlockSec*waitUnitPerSec; i++ {
-		if atomic.LoadInt64(&producer.producerLogGroupSize) > producer.producerConfig.TotalSizeLnBytes {
-			time.Sleep(waitTimeUnit)
-		} else {
-			return nil
-		}
-	}
+// SendLogCtx sends log like SendLogWithCallBack, callback is optional. If the producer is out of
+// memory and OverflowPolicy blocks, it waits until memory is freed or ctx is done instead of
+// MaxBlockSec, and returns the error of ctx in the latter case.
+func (producer *Producer) SendLogCtx(ctx context.Context, project, logstore, topic, source string, log *sls.Log, callback CallBack) error {
+	return producer.send(producer.waitMemory(ctx), project, logstore, nil, topic, source, log, callback)
+}
 
-	producer.monitor.incWaitMemoryFail()
-	level.Error(producer.logger).Log("msg", "Over producer set maximum blocking time")
-	return errors.New(TimeoutExecption)
+// SendLogListCtx sends logList like SendLogListWithCallBack, and waits for memory like SendLogCtx.
+func (producer *Producer) SendLogListCtx(ctx context.Context, project, logstore, topic, source string, logList []*sls.Log, callback CallBack) error {
+	return producer.send(producer.waitMemory(ctx), project, logstore, nil, topic, source, logList, callback)
 }
 
 const waitTimeUnit = time.Millisecond * 10
-const waitUnitPerSec = int(time.Second / waitTimeUnit)
 
 func (producer *Producer) Start() {
 	producer.moverWaitGroup.Add(1)
@@ -290,25 +244,105 @@ func (producer *Producer) Start() {
 	if !producer.producerConfig.DisableRuntimeMetrics {
 		go producer.monitor.reportThread(time.Minute, producer.logger)
 	}
+	if producer.shardRouter != nil {
+		go producer.shardRouter.run()
+	}
+	if producer.spill != nil {
+		// the mover wait group makes close wait for the replay before shutting down the thread pool
+		producer.moverWaitGroup.Add(1)
+		go producer.replaySpilled(producer.moverWaitGroup)
+	}
+}
+
+// replaySpilled sends the batches spilled by previous producers, as long as the
+// producer has memory for them.
+func (producer *Producer) replaySpilled(wg *sync.WaitGroup) {
+	defer wg.Done()
+	producer.spill.replay(func(record *spillRecord, segment *spillSegment) bool {
+		size := int64(len(record.logGroup))
+		if !producer.waitReplayMemory(size) {
+			return false
+		}
+		atomic.AddInt64(&producer.producerLogGroupSize, size)
+		batch := newSpilledProducerBatch(record, segment, producer.producerConfig)
+		producer.trackBatch(batch)
+		producer.threadPool.addTask(batch)
+		return true
+	})
+}
+
+// waitReplayMemory waits until a replayed batch of size fits in TotalSizeLnBytes, or no
+// logs are buffered, it returns false once the producer is closing.
+func (producer *Producer) waitReplayMemory(size int64) bool {
+	memory := producer.memory
+	atomic.AddInt32(&memory.waiters, 1)
+	defer atomic.AddInt32(&memory.waiters, -1)
+	for {
+		// get the channel before checking, so that memory freed in between is not missed
+		freed := memory.freedChan()
+		if producer.logAccumulator.shutDownFlag.Load() {
+			return false
+		}
+		buffered := atomic.LoadInt64(&producer.producerLogGroupSize)
+		if buffered == 0 || buffered+size <= producer.producerConfig.TotalSizeLnBytes {
+			return true
+		}
+		<-freed
+	}
 }
 
 // Limited closing transfer parameter nil, safe closing transfer timeout time, timeout Ms parameter in milliseconds
+//
+// If the producer is not closed within timeoutMs, the batches left are sent in the background,
+// unless SpillDir is set or AbandonOnCloseTimeout is true, in which case the batches not being
+// sent yet are spilled, or fail with ErrProducerClosed, and the batches being sent are left to finish.
 func (producer *Producer) Close(timeoutMs int64) error {
 	startCloseTime := time.Now()
 	producer.sendCloseProdcerSignal()
 	producer.moverWaitGroup.Wait()
 	producer.threadPool.ShutDown()
-	for !producer.threadPool.Stopped() {
-		if time.Since(startCloseTime) > time.Duration(timeoutMs)*time.Millisecond {
-			level.Warn(producer.logger).Log("msg", "The producer timeout closes, and some of the cached data may not be sent properly")
+	stopped := make(chan struct{})
+	go func() {
+		producer.ioThreadPoolWaitGroup.Wait()
+		producer.ioWorkerWaitGroup.Wait()
+		close(stopped)
+	}()
+	timer := time.NewTimer(time.Duration(timeoutMs)*time.Millisecond - time.Since(startCloseTime))
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		level.Warn(producer.logger).Log("msg", "The producer timeout closes, and some of the cached data may not be sent properly")
+		if producer.spill == nil && !producer.producerConfig.AbandonOnCloseTimeout {
 			return errors.New(TimeoutExecption)
 		}
-		time.Sleep(100 * time.Millisecond)
-	}
+		producer.threadPool.abandon()
+		producer.ioThreadPoolWaitGroup.Wait()
+		producer.spill.sync()
+		// batches being sent may still be spilled, so the spill queue is closed once they are done
+		go func() {
+			<-stopped
+			producer.spill.close()
+		}()
+		return errors.New(TimeoutExecption)
+	}
+	producer.spill.close()
 	level.Info(producer.logger).Log("msg", "All groutines of producer have been shutdown")
 	return nil
 }
 
+// abandonBatch finishes producerBatch left unsent by Close, it is spilled if SpillDir is set.
+func (producer *Producer) abandonBatch(producerBatch *ProducerBatch) {
+	if producer.spill != nil && producerBatch.spillTimes() < spillMaxTimes && producer.spill.write(producerBatch) == nil {
+		producer.monitor.incSpill()
+		producerBatch.onSpilled()
+	} else {
+		producerBatch.failWith(ErrProducerClosed)
+	}
+	producer.releaseMemory(producerBatch.totalDataSize)
+	producer.finishBatch(producerBatch)
+}
+
 func (producer *Producer) SafeClose() {
 	producer.sendCloseProdcerSignal()
 	producer.moverWaitGroup.Wait()
@@ -317,15 +351,21 @@ func (producer *Producer) SafeClose() {
 	producer.ioThreadPoolWaitGroup.Wait()
 	level.Info(producer.logger).Log("msg", "IoThreadPool close finish")
 	producer.ioWorkerWaitGroup.Wait()
+	producer.spill.close()
 	level.Info(producer.logger).Log("msg", "Producer close finish")
 }
 
 func (producer *Producer) sendCloseProdcerSignal() {
 	level.Info(producer.logger).Log("msg", "producer start closing")
 	producer.closeStstokenChannel()
+	if producer.shardRouter != nil {
+		producer.shardRouter.stop()
+	}
 	producer.mover.moverShutDownFlag.Store(true)
 	producer.logAccumulator.shutDownFlag.Store(true)
 	producer.mover.ioWorker.retryQueueShutDownFlag.Store(true)
+	// wake up the replay waiting for memory
+	producer.memory.notify()
 }
 
 func (producer *Producer) closeStstokenChannel() {
@@ -24,7 +24,10 @@ type ProducerBatch struct {
 
 	// read only after seal
 	totalDataSize int64
-	logGroup      *sls.LogGroup
+	encoder       *sls.LogGroupEncoder // logs encoded as they are added, nil if replayed from spill
+	spilled       *spillRecord
+	segment       *spillSegment
+	done          chan struct{} // closed once the batch is sent, spilled or failed for good
 	callBackList  []CallBack
 
 	// transient fields, but rw by at most one thread
@@ -34,24 +37,17 @@ type ProducerBatch struct {
 }
 
 func newProducerBatch(packIdGenerator *PackIdGenerator, project, logstore, logTopic, logSource, shardHash string, config *ProducerConfig) *ProducerBatch {
-	logGroup := &sls.LogGroup{
-		Topic:  proto.String(logTopic),
-		Source: proto.String(logSource),
-		Logs:   make([]*sls.Log, 0, config.MaxBatchCount+4),
-	}
-
+	logTags := config.LogTags
 	if config.GeneratePackId {
-		logGroup.LogTags = append(make([]*sls.LogTag, 0, len(config.LogTags)+1), config.LogTags...)
-		logGroup.LogTags = append(logGroup.LogTags, &sls.LogTag{
+		logTags = append(make([]*sls.LogTag, 0, len(config.LogTags)+1), config.LogTags...)
+		logTags = append(logTags, &sls.LogTag{
 			Key:   &PACK_ID_KEY,
 			Value: proto.String(packIdGenerator.GeneratePackId(project, logstore)),
 		})
-	} else {
-		logGroup.LogTags = config.LogTags
 	}
 
 	producerBatch := &ProducerBatch{
-		logGroup:             logGroup,
+		encoder:              sls.NewLogGroupEncoder(logTopic, logSource, logTags),
 		maxRetryIntervalInMs: config.MaxRetryBackoffMs,
 		callBackList:         []CallBack{},
 		createTimeMs:         time.Now().UnixMilli(),
@@ -62,13 +58,37 @@ func newProducerBatch(packIdGenerator *PackIdGenerator, project, logstore, logTo
 		result:               initResult(),
 		maxReservedAttempts:  config.MaxReservedAttempts,
 		useMetricStoreUrl:    config.UseMetricStoreURL,
+		done:                 make(chan struct{}),
 	}
+	// the header of topic, source and tags is sent with the logs
+	producerBatch.totalDataSize = int64(producerBatch.encoder.Size())
 	if shardHash != "" {
 		producerBatch.shardHash = &shardHash
 	}
 	return producerBatch
 }
 
+// newSpilledProducerBatch returns a batch replayed from a segment of the spill queue.
+func newSpilledProducerBatch(record *spillRecord, segment *spillSegment, config *ProducerConfig) *ProducerBatch {
+	return &ProducerBatch{
+		spilled:              record,
+		segment:              segment,
+		totalDataSize:        int64(len(record.logGroup)),
+		maxRetryIntervalInMs: config.MaxRetryBackoffMs,
+		callBackList:         []CallBack{},
+		createTimeMs:         time.Now().UnixMilli(),
+		maxRetryTimes:        config.Retries,
+		baseRetryBackoffMs:   config.BaseRetryBackoffMs,
+		project:              record.project,
+		logstore:             record.logstore,
+		shardHash:            record.shardHash,
+		result:               initResult(),
+		maxReservedAttempts:  config.MaxReservedAttempts,
+		useMetricStoreUrl:    record.useMetricStoreUrl,
+		done:                 make(chan struct{}),
+	}
+}
+
 func (producerBatch *ProducerBatch) getProject() string {
 	return producerBatch.project
 }
@@ -86,23 +106,66 @@ func (producerBatch *ProducerBatch) isUseMetricStoreUrl() bool {
 }
 
 func (producerBatch *ProducerBatch) meetSendCondition(producerConfig *ProducerConfig) bool {
-	return producerBatch.totalDataSize >= producerConfig.MaxBatchSize || len(producerBatch.logGroup.Logs) >= producerConfig.MaxBatchCount
+	return producerBatch.totalDataSize >= producerConfig.MaxBatchSize || producerBatch.encoder.Len() >= producerConfig.MaxBatchCount
 }
 
-func (producerBatch *ProducerBatch) addLog(log *sls.Log, size int64, callback CallBack) {
-	producerBatch.logGroup.Logs = append(producerBatch.logGroup.Logs, log)
-	producerBatch.totalDataSize += size
+// addLog encodes log into the batch and returns its encoded size
+func (producerBatch *ProducerBatch) addLog(log *sls.Log, callback CallBack) (int64, error) {
+	size, err := producerBatch.encoder.AppendLog(log)
+	if err != nil {
+		return 0, err
+	}
+	producerBatch.totalDataSize += int64(size)
 	if callback != nil {
 		producerBatch.callBackList = append(producerBatch.callBackList, callback)
 	}
+	return int64(size), nil
 }
 
-func (producerBatch *ProducerBatch) addLogList(logList []*sls.Log, size int64, callback CallBack) {
-	producerBatch.logGroup.Logs = append(producerBatch.logGroup.Logs, logList...)
-	producerBatch.totalDataSize += size
+// addLogList encodes logList into the batch and returns their encoded size, no log is added on error
+func (producerBatch *ProducerBatch) addLogList(logList []*sls.Log, callback CallBack) (int64, error) {
+	size, err := producerBatch.encoder.AppendLogs(logList)
+	if err != nil {
+		return 0, err
+	}
+	producerBatch.totalDataSize += int64(size)
 	if callback != nil {
 		producerBatch.callBackList = append(producerBatch.callBackList, callback)
 	}
+	return int64(size), nil
+}
+
+func (producerBatch *ProducerBatch) logCount() int {
+	if producerBatch.encoder == nil {
+		return producerBatch.spilled.logCount
+	}
+	return producerBatch.encoder.Len()
+}
+
+// spillTimes returns the number of times the batch is spilled before
+func (producerBatch *ProducerBatch) spillTimes() int {
+	if producerBatch.spilled == nil {
+		return 0
+	}
+	return producerBatch.spilled.spillTimes
+}
+
+// logGroupBytes returns the encoded LogGroup of the batch
+func (producerBatch *ProducerBatch) logGroupBytes() []byte {
+	if producerBatch.encoder == nil {
+		return producerBatch.spilled.logGroup
+	}
+	return producerBatch.encoder.Bytes()
+}
+
+// release returns the buffer of the batch once it is sent, spilled or failed for good
+func (producerBatch *ProducerBatch) release() {
+	if producerBatch.encoder != nil {
+		producerBatch.encoder.Release()
+	}
+	if producerBatch.segment != nil {
+		producerBatch.segment.done()
+	}
 }
 
 func (producerBatch *ProducerBatch) OnSuccess(begin time.Time) {
@@ -123,8 +186,27 @@ func (producerBatch *ProducerBatch) OnFail(err *sls.Error, begin time.Time) {
 	}
 }
 
+// failWith notifies the callbacks that the batch is given up without being sent, because of cause
+func (producerBatch *ProducerBatch) failWith(cause error) {
+	producerBatch.addAttempt(sls.NewClientError(cause), time.Now())
+	for _, callBack := range producerBatch.callBackList {
+		callBack.Fail(producerBatch.result)
+	}
+}
+
+// onSpilled notifies the callbacks implementing SpillCallBack that the batch is spilled
+func (producerBatch *ProducerBatch) onSpilled() {
+	producerBatch.addAttempt(sls.NewClientError(ErrBatchSpilled), time.Now())
+	for _, callBack := range producerBatch.callBackList {
+		if spillCallBack, ok := callBack.(SpillCallBack); ok {
+			spillCallBack.Spilled(producerBatch.result)
+		}
+	}
+}
+
 func (producerBatch *ProducerBatch) addAttempt(err *sls.Error, begin time.Time) {
 	producerBatch.result.successful = (err == nil)
+	producerBatch.result.err = err
 	producerBatch.attemptCount += 1
 
 	if producerBatch.attemptCount > producerBatch.maxReservedAttempts {
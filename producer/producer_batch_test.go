@@ -0,0 +1,41 @@
+package producer
+
+import (
+	"testing"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducerBatch(t *testing.T) {
+	config := GetDefaultProducerConfig()
+	config.GeneratePackId = true
+	config.LogTags = []*sls.LogTag{{Key: proto.String("env"), Value: proto.String("test")}}
+	batch := newProducerBatch(newPackIdGenerator(), "project", "logstore", "topic", "source", "", config)
+	defer batch.release()
+
+	log := GenerateLog(1700000000, map[string]string{"k": "v"})
+	size, err := batch.addLog(log, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(log.Size()+2), size)
+	_, err = batch.addLogList([]*sls.Log{log, {}}, nil)
+	assert.Error(t, err)
+	size, err = batch.addLogList([]*sls.Log{log, log}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2*(log.Size()+2)), size)
+	assert.Equal(t, 3, batch.logCount())
+
+	logGroup := &sls.LogGroup{}
+	require.NoError(t, proto.Unmarshal(batch.encoder.Bytes(), logGroup))
+	assert.Equal(t, "topic", logGroup.GetTopic())
+	assert.Equal(t, "source", logGroup.GetSource())
+	require.Len(t, logGroup.LogTags, 2)
+	assert.Equal(t, "env", logGroup.LogTags[0].GetKey())
+	assert.Equal(t, PACK_ID_KEY, logGroup.LogTags[1].GetKey())
+	require.Len(t, logGroup.Logs, 3)
+	assert.Equal(t, log.String(), logGroup.Logs[2].String())
+	// the size of a batch is that of its encoded LogGroup, as for batches replayed from spill
+	assert.Equal(t, int64(len(batch.logGroupBytes())), batch.totalDataSize)
+}
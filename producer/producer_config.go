@@ -16,6 +16,8 @@ type ProducerConfig struct {
 	TotalSizeLnBytes    int64
 	MaxIoWorkerCount    int64
 	MaxBlockSec         int
+	OverflowPolicy      OverflowPolicy // defaults to OverflowBlock
+	OnDrop              DropCallBack   // optional, receives logs dropped by OverflowPolicy
 	MaxBatchSize        int64
 	MaxBatchCount       int
 	LingerMs            int64
@@ -26,6 +28,18 @@ type ProducerConfig struct {
 	AdjustShargHash     bool
 	Buckets             int
 
+	// Optional, defaults to false.
+	// If ShardRouting is true, the shard hash of HashSendLog is routed to the begin key of the
+	// readwrite shard owning it, so that logs are batched per shard, instead of the buckets of
+	// AdjustShargHash. The shard hash is hashed with md5 first if AdjustShargHash is true, or
+	// used as the hash key as it is otherwise. Until the shards of a logstore are listed,
+	// the shard hash is handled as if ShardRouting is false.
+	ShardRouting bool
+	// Optional, defaults to 60s.
+	// ShardRefreshIntervalMs is the interval of listing shards of logstores for ShardRouting,
+	// logstores not sent to for 5 intervals are no longer listed until they are sent to again.
+	ShardRefreshIntervalMs int64
+
 	// Optional, defaults to nil.
 	// The logger is used to record the runtime status of the consumer.
 	// The logs generated by the logger will only be stored locally.
@@ -76,6 +90,42 @@ type ProducerConfig struct {
 	AuthVersion      sls.AuthVersionType
 	CompressType     int    // only work for logstore now
 	Processor        string // ingest processor
+
+	// Optional, defaults to "" which disables spilling.
+	// SpillDir is the directory where batches are persisted if they cannot be sent,
+	// because retries are exhausted by network errors, the producer is closing, or
+	// TotalSizeLnBytes is exceeded while retrying. Spilled batches are sent by the next
+	// producer started with the same SpillDir, their callbacks are not called, except
+	// Spilled of those implementing SpillCallBack. A batch failing after being spilled
+	// 3 times is failed for good, and written to DeadLetterSink.
+	// Spilled batches are sent at least once: those being sent when the process exits
+	// or Close times out are replayed again by the next producer.
+	// A SpillDir must not be shared by producers running at the same time.
+	SpillDir string
+	// Optional, defaults to false.
+	// AbandonOnCloseTimeout fails the batches not being sent yet with ErrProducerClosed
+	// when Close times out, instead of leaving them to be sent in the background.
+	// They are spilled instead if SpillDir is set, in which case it is always done.
+	AbandonOnCloseTimeout bool
+	// Optional, defaults to 1GB.
+	// SpillMaxBytes is the maximum size of SpillDir, batches failing to spill are dropped.
+	SpillMaxBytes int64
+	// Optional, defaults to 64MB.
+	// SpillSegmentBytes is the size of a segment file in SpillDir before a new one is created.
+	SpillSegmentBytes int64
+	// Optional, defaults to SpillSyncOnRotate.
+	SpillSync SpillSyncPolicy
+
+	// Optional, defaults to nil.
+	// DeadLetterSink receives the batches failed for good, eg. NewFileDeadLetterSink and
+	// NewLogstoreDeadLetterSink, so that they are not lost without callbacks.
+	// With SpillDir set, batches whose retries are exhausted by network errors are spilled instead of written to it.
+	DeadLetterSink DeadLetterSink
+
+	// Optional, defaults to nil.
+	// If set, a span is created for every batch sent to server and every
+	// http request sent by the producer's client.
+	Tracer sls.Tracer
 }
 
 func GetDefaultProducerConfig() *ProducerConfig {
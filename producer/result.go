@@ -1,5 +1,7 @@
 package producer
 
+import sls "github.com/aliyun/aliyun-log-go-sdk"
+
 type Attempt struct {
 	Success      bool
 	RequestId    string
@@ -26,6 +28,7 @@ func createAttempt(success bool,
 type Result struct {
 	attemptList []*Attempt
 	successful  bool
+	err         *sls.Error
 }
 
 func (result *Result) IsSuccessful() bool {
@@ -36,6 +39,15 @@ func (result *Result) GetReservedAttempts() []*Attempt {
 	return result.attemptList
 }
 
+// Err returns the error of the last attempt, nil if the batch is sent successfully.
+// It can be matched with errors.Is, eg. errors.Is(result.Err(), sls.ErrQuotaExceeded).
+func (result *Result) Err() error {
+	if result.err == nil {
+		return nil
+	}
+	return result.err
+}
+
 func (result *Result) GetErrorCode() string {
 	if len(result.attemptList) == 0 {
 		return ""
@@ -52,6 +52,22 @@ func (retryQueue *RetryQueue) getRetryBatch(moverShutDownFlag bool) (producerBat
 	return producerBatchList
 }
 
+// removeOldest removes and returns the batch created earliest, if it is created before createTimeMs.
+func (retryQueue *RetryQueue) removeOldest(createTimeMs int64) *ProducerBatch {
+	retryQueue.mutex.Lock()
+	defer retryQueue.mutex.Unlock()
+	oldest := -1
+	for i, batch := range retryQueue.batch {
+		if batch.createTimeMs < createTimeMs && (oldest < 0 || batch.createTimeMs < retryQueue.batch[oldest].createTimeMs) {
+			oldest = i
+		}
+	}
+	if oldest < 0 {
+		return nil
+	}
+	return heap.Remove(retryQueue, oldest).(*ProducerBatch)
+}
+
 func (retryQueue *RetryQueue) Len() int {
 	return len(retryQueue.batch)
 }
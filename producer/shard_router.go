@@ -0,0 +1,167 @@
+package producer
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// maxHashKey is the exclusive end key of the last shard, which owns it all the same
+const maxHashKey = "ffffffffffffffffffffffffffffffff"
+
+// shardRouterIdleIntervals is the number of refresh intervals after which a logstore
+// not routed is forgotten, so that its shards are no longer listed
+const shardRouterIdleIntervals = 5
+
+// shardRouter routes hash keys to the begin key of the readwrite shard owning them,
+// so that logs of the same shard are sent in a batch. The shards of a logstore are
+// listed once it is routed, and refreshed periodically to follow split and merge.
+type shardRouter struct {
+	client   sls.ClientInterface
+	logger   log.Logger
+	interval time.Duration
+
+	lock      sync.RWMutex
+	logstores map[string]*logstoreShards
+	refreshCh chan struct{}
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+}
+
+type logstoreShards struct {
+	project   string
+	logstore  string
+	ranges    []shardRange // readwrite shards sorted by begin key, nil until listed
+	refreshed time.Time
+	routed    int64 // unix nano of the last route, accessed atomically
+}
+
+type shardRange struct {
+	begin string // inclusive
+	end   string // exclusive
+}
+
+func newShardRouter(client sls.ClientInterface, interval time.Duration, logger log.Logger) *shardRouter {
+	return &shardRouter{
+		client:    client,
+		logger:    logger,
+		interval:  interval,
+		logstores: make(map[string]*logstoreShards),
+		refreshCh: make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// route returns the begin key of the shard owning hashKey, it returns false if
+// the shards of the logstore are not listed yet.
+func (router *shardRouter) route(project, logstore, hashKey string) (string, bool) {
+	key := project + Delimiter + logstore
+	router.lock.RLock()
+	shards, ok := router.logstores[key]
+	var ranges []shardRange
+	if ok {
+		ranges = shards.ranges
+		atomic.StoreInt64(&shards.routed, time.Now().UnixNano())
+	}
+	router.lock.RUnlock()
+	if !ok {
+		router.lock.Lock()
+		if _, ok := router.logstores[key]; !ok {
+			router.logstores[key] = &logstoreShards{project: project, logstore: logstore, routed: time.Now().UnixNano()}
+		}
+		router.lock.Unlock()
+		select {
+		case router.refreshCh <- struct{}{}:
+		default:
+		}
+		return "", false
+	}
+
+	hashKey = strings.ToLower(hashKey)
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].end > hashKey || ranges[i].end == maxHashKey })
+	if i == len(ranges) || ranges[i].begin > hashKey {
+		return "", false
+	}
+	return ranges[i].begin, true
+}
+
+func (router *shardRouter) run() {
+	ticker := time.NewTicker(router.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-router.stopCh:
+			return
+		case <-ticker.C:
+		case <-router.refreshCh:
+		}
+		router.refresh()
+	}
+}
+
+func (router *shardRouter) stop() {
+	router.stopOnce.Do(func() { close(router.stopCh) })
+}
+
+// refresh forgets logstores not routed for shardRouterIdleIntervals, and lists the
+// shards of the others never listed or listed an interval ago.
+func (router *shardRouter) refresh() {
+	now := time.Now()
+	var stale []*logstoreShards
+	router.lock.Lock()
+	for key, shards := range router.logstores {
+		if now.Sub(time.Unix(0, atomic.LoadInt64(&shards.routed))) >= shardRouterIdleIntervals*router.interval {
+			delete(router.logstores, key)
+			continue
+		}
+		if shards.ranges == nil || now.Sub(shards.refreshed) >= router.interval {
+			stale = append(stale, shards)
+		}
+	}
+	router.lock.Unlock()
+
+	for _, shards := range stale {
+		ranges, err := router.listShards(shards.project, shards.logstore)
+		if err != nil {
+			// keep routing with the shards listed before
+			level.Warn(router.logger).Log("msg", "failed to list shards", "project", shards.project,
+				"logstore", shards.logstore, "error", err)
+			continue
+		}
+		router.lock.Lock()
+		shards.ranges, shards.refreshed = ranges, now
+		router.lock.Unlock()
+	}
+}
+
+func (router *shardRouter) listShards(project, logstore string) ([]shardRange, error) {
+	shards, err := router.client.ListShards(project, logstore)
+	if err != nil {
+		return nil, err
+	}
+	ranges := make([]shardRange, 0, len(shards))
+	for _, shard := range shards {
+		if strings.EqualFold(shard.Status, "readwrite") {
+			ranges = append(ranges, shardRange{
+				begin: strings.ToLower(shard.InclusiveBeginKey),
+				end:   strings.ToLower(shard.ExclusiveBeginKey),
+			})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].begin < ranges[j].begin })
+	return ranges, nil
+}
+
+// hashKeyOf returns the md5 of shardHash in hex, as the hash key of AdjustHash before truncated.
+func hashKeyOf(shardHash string) string {
+	sum := md5.Sum([]byte(shardHash))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,144 @@
+package producer
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type listShardsClient struct {
+	sls.ClientInterface
+	lock   sync.Mutex
+	shards []*sls.Shard
+	err    error
+}
+
+func (client *listShardsClient) ListShards(project, logstore string) ([]*sls.Shard, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	return client.shards, client.err
+}
+
+func (client *listShardsClient) setShards(shards []*sls.Shard, err error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.shards, client.err = shards, err
+}
+
+const (
+	shardKey0 = "00000000000000000000000000000000"
+	shardKey4 = "40000000000000000000000000000000"
+	shardKey8 = "80000000000000000000000000000000"
+	shardKeyF = "ffffffffffffffffffffffffffffffff"
+)
+
+func TestShardRouter(t *testing.T) {
+	client := &listShardsClient{shards: []*sls.Shard{
+		{ShardID: 1, Status: "readwrite", InclusiveBeginKey: shardKey8, ExclusiveBeginKey: shardKeyF},
+		{ShardID: 0, Status: "readwrite", InclusiveBeginKey: shardKey0, ExclusiveBeginKey: shardKey8},
+	}}
+	router := newShardRouter(client, time.Hour, log.NewNopLogger())
+	_, ok := router.route("project", "logstore", "7f000000000000000000000000000000")
+	assert.False(t, ok)
+	router.refresh()
+
+	for hashKey, beginKey := range map[string]string{
+		shardKey0:                          shardKey0,
+		"7FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF": shardKey0,
+		shardKey8:                          shardKey8,
+		"c0000000000000000000000000000000": shardKey8,
+		// the exclusive end key of the last shard is owned by it
+		shardKeyF: shardKey8,
+	} {
+		routed, ok := router.route("project", "logstore", hashKey)
+		assert.True(t, ok, hashKey)
+		assert.Equal(t, beginKey, routed, hashKey)
+	}
+
+	// split of shard 0
+	client.setShards([]*sls.Shard{
+		{ShardID: 0, Status: "readonly", InclusiveBeginKey: shardKey0, ExclusiveBeginKey: shardKey8},
+		{ShardID: 1, Status: "readwrite", InclusiveBeginKey: shardKey8, ExclusiveBeginKey: shardKeyF},
+		{ShardID: 2, Status: "readwrite", InclusiveBeginKey: shardKey0, ExclusiveBeginKey: shardKey4},
+		{ShardID: 3, Status: "readwrite", InclusiveBeginKey: shardKey4, ExclusiveBeginKey: shardKey8},
+	}, nil)
+	router.refresh()
+	routed, _ := router.route("project", "logstore", "50000000000000000000000000000000")
+	assert.Equal(t, shardKey0, routed, "refreshed within the interval")
+	router.logstores["project|logstore"].refreshed = time.Time{}
+	router.refresh()
+	routed, _ = router.route("project", "logstore", "50000000000000000000000000000000")
+	assert.Equal(t, shardKey4, routed)
+
+	// the shards listed before are kept on error
+	client.setShards(nil, errors.New("unavailable"))
+	router.logstores["project|logstore"].refreshed = time.Time{}
+	router.refresh()
+	routed, _ = router.route("project", "logstore", "50000000000000000000000000000000")
+	assert.Equal(t, shardKey4, routed)
+}
+
+func TestProducerShardRouting(t *testing.T) {
+	client := &listShardsClient{shards: []*sls.Shard{
+		{ShardID: 0, Status: "readwrite", InclusiveBeginKey: shardKey0, ExclusiveBeginKey: shardKey8},
+		{ShardID: 1, Status: "readwrite", InclusiveBeginKey: shardKey8, ExclusiveBeginKey: shardKeyF},
+	}}
+	config := GetDefaultProducerConfig()
+	config.ShardRouting = true
+	config.Logger = log.NewNopLogger()
+	producer := createProducerInternal(client, validateProducerConfig(config, config.Logger), config.Logger)
+
+	log := GenerateLog(1700000000, map[string]string{"k": "v"})
+	require.NoError(t, producer.HashSendLog("project", "logstore", "a", "before", "source", log))
+	producer.shardRouter.refresh()
+	for _, shardHash := range []string{"a", "b", "c", "d", "e", "f"} {
+		require.NoError(t, producer.HashSendLog("project", "logstore", shardHash, "topic", "source", log))
+	}
+	// the first log is sent before the shards are listed
+	adjusted, err := AdjustHash("a", config.Buckets)
+	require.NoError(t, err)
+	require.NotNil(t, producer.logAccumulator.logGroupData["project|logstore|before|"+adjusted+"|source"])
+	delete(producer.logAccumulator.logGroupData, "project|logstore|before|"+adjusted+"|source")
+	for key := range producer.logAccumulator.logGroupData {
+		assert.Contains(t, []string{
+			"project|logstore|topic|" + shardKey0 + "|source",
+			"project|logstore|topic|" + shardKey8 + "|source",
+		}, key)
+	}
+	assert.Len(t, producer.logAccumulator.logGroupData, 2)
+}
+
+func TestShardRouterEvict(t *testing.T) {
+	client := &listShardsClient{shards: []*sls.Shard{
+		{ShardID: 0, Status: "readwrite", InclusiveBeginKey: shardKey0, ExclusiveBeginKey: shardKeyF},
+	}}
+	router := newShardRouter(client, time.Hour, log.NewNopLogger())
+	router.route("project", "idle", shardKey8)
+	router.route("project", "busy", shardKey8)
+	router.refresh()
+	require.Len(t, router.logstores, 2)
+
+	// a logstore not routed for shardRouterIdleIntervals is forgotten
+	idle := time.Now().Add(-shardRouterIdleIntervals * time.Hour).UnixNano()
+	router.logstores["project|idle"].routed = idle
+	router.logstores["project|busy"].routed = idle
+	routed, ok := router.route("project", "busy", shardKeyF)
+	assert.True(t, ok)
+	assert.Equal(t, shardKey0, routed)
+	router.refresh()
+	assert.Len(t, router.logstores, 1)
+	assert.Contains(t, router.logstores, "project|busy")
+
+	// and listed again once it is routed
+	_, ok = router.route("project", "idle", shardKey8)
+	assert.False(t, ok)
+	router.refresh()
+	_, ok = router.route("project", "idle", shardKey8)
+	assert.True(t, ok)
+}
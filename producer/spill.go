@@ -0,0 +1,484 @@
+package producer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// SpillSyncPolicy controls when spilled segments are fsynced.
+type SpillSyncPolicy int
+
+const (
+	// SpillSyncOnRotate fsyncs a segment when it is full or the producer is closed.
+	SpillSyncOnRotate SpillSyncPolicy = iota
+	// SpillSyncAlways fsyncs a segment after every batch spilled.
+	SpillSyncAlways
+	// SpillSyncNever leaves flushing spilled segments to the operating system.
+	SpillSyncNever
+)
+
+// ErrBatchSpilled is the cause of Result.Err of a batch which is persisted to
+// ProducerConfig.SpillDir instead of being sent, it is sent on next start of a producer.
+var ErrBatchSpilled = errors.New("producer batch is spilled to disk")
+
+// SpillCallBack is a CallBack notified with Spilled instead of Success or Fail
+// when its batch is spilled, the callbacks of a spilled batch are not called
+// otherwise, as the batch is sent later by another producer.
+type SpillCallBack interface {
+	CallBack
+	Spilled(result *Result)
+}
+
+// spillMaxTimes is the number of times a batch may be spilled, a batch failing
+// after as many replays is failed for good and written to the DeadLetterSink.
+const spillMaxTimes = 3
+
+var errSpillFull = errors.New("spill directory exceeds SpillMaxBytes")
+
+const (
+	spillSegmentSuffix  = ".spill"
+	spillCorruptSuffix  = ".corrupt"
+	spillOffsetSuffix   = ".offset" // appended to a segment whose replay is interrupted
+	spillRecordHeadSize = 8         // length and crc32 of the record, little endian
+)
+
+var (
+	spillMagic    = []byte("SLSSPIL1")
+	spillCrcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// spillRecord is a sealed batch persisted in a segment, encoded as protobuf:
+//
+//	1: project, 2: logstore, 3: shard hash, 4: log group, 5: log count, 6: use metric store url, 7: spill times
+type spillRecord struct {
+	project           string
+	logstore          string
+	shardHash         *string
+	logGroup          []byte
+	logCount          int
+	useMetricStoreUrl bool
+	spillTimes        int // number of times the batch is spilled, including this one
+}
+
+func (record *spillRecord) marshal() []byte {
+	buf := make([]byte, spillRecordHeadSize, spillRecordHeadSize+len(record.logGroup)+len(record.project)+len(record.logstore)+64)
+	buf = appendSpillBytes(buf, 1, []byte(record.project))
+	buf = appendSpillBytes(buf, 2, []byte(record.logstore))
+	if record.shardHash != nil {
+		buf = appendSpillBytes(buf, 3, []byte(*record.shardHash))
+	}
+	buf = appendSpillBytes(buf, 4, record.logGroup)
+	buf = appendSpillVarint(buf, 5, uint64(record.logCount))
+	if record.useMetricStoreUrl {
+		buf = appendSpillVarint(buf, 6, 1)
+	}
+	buf = appendSpillVarint(buf, 7, uint64(record.spillTimes))
+	payload := buf[spillRecordHeadSize:]
+	binary.LittleEndian.PutUint32(buf, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.Checksum(payload, spillCrcTable))
+	return buf
+}
+
+func appendSpillBytes(buf []byte, field uint64, value []byte) []byte {
+	buf = binary.AppendUvarint(buf, field<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendSpillVarint(buf []byte, field, value uint64) []byte {
+	buf = binary.AppendUvarint(buf, field<<3)
+	return binary.AppendUvarint(buf, value)
+}
+
+func unmarshalSpillRecord(payload []byte) (*spillRecord, error) {
+	record := &spillRecord{}
+	for len(payload) > 0 {
+		key, n := binary.Uvarint(payload)
+		if n <= 0 {
+			return nil, errors.New("invalid spill record key")
+		}
+		payload = payload[n:]
+		value, n := binary.Uvarint(payload)
+		if n <= 0 {
+			return nil, errors.New("invalid spill record value")
+		}
+		payload = payload[n:]
+		var data []byte
+		switch key & 7 {
+		case 0:
+		case 2:
+			if value > uint64(len(payload)) {
+				return nil, errors.New("truncated spill record")
+			}
+			data, payload = payload[:value], payload[value:]
+		default:
+			return nil, fmt.Errorf("unexpected wire type %d in spill record", key&7)
+		}
+		switch key >> 3 {
+		case 1:
+			record.project = string(data)
+		case 2:
+			record.logstore = string(data)
+		case 3:
+			shardHash := string(data)
+			record.shardHash = &shardHash
+		case 4:
+			record.logGroup = data
+		case 5:
+			record.logCount = int(value)
+		case 6:
+			record.useMetricStoreUrl = value != 0
+		case 7:
+			record.spillTimes = int(value)
+		}
+	}
+	if record.logGroup == nil {
+		return nil, errors.New("spill record without log group")
+	}
+	return record, nil
+}
+
+// spillQueue persists sealed batches to segments in a directory, the segments
+// found when it is opened are replayed and removed once their batches are done.
+type spillQueue struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+	syncPolicy   SpillSyncPolicy
+	logger       log.Logger
+
+	lock        sync.Mutex
+	seq         uint64
+	file        *os.File
+	fileSize    int64
+	totalBytes  int64
+	closed      bool
+	recoverable []string // segments written by previous producers
+}
+
+func openSpillQueue(config *ProducerConfig, logger log.Logger) (*spillQueue, error) {
+	if err := os.MkdirAll(config.SpillDir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(config.SpillDir)
+	if err != nil {
+		return nil, err
+	}
+	queue := &spillQueue{
+		dir:          config.SpillDir,
+		maxBytes:     config.SpillMaxBytes,
+		segmentBytes: config.SpillSegmentBytes,
+		syncPolicy:   config.SpillSync,
+		logger:       logger,
+	}
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, spillSegmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spillSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		queue.totalBytes += info.Size()
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	for _, seq := range seqs {
+		queue.recoverable = append(queue.recoverable, queue.segmentPath(seq))
+		queue.seq = seq
+	}
+	return queue, nil
+}
+
+func (queue *spillQueue) segmentPath(seq uint64) string {
+	return filepath.Join(queue.dir, fmt.Sprintf("%020d%s", seq, spillSegmentSuffix))
+}
+
+// write persists producerBatch to the current segment, rotating it when it is full.
+func (queue *spillQueue) write(producerBatch *ProducerBatch) error {
+	data := (&spillRecord{
+		project:           producerBatch.getProject(),
+		logstore:          producerBatch.getLogstore(),
+		shardHash:         producerBatch.getShardHash(),
+		logGroup:          producerBatch.logGroupBytes(),
+		logCount:          producerBatch.logCount(),
+		useMetricStoreUrl: producerBatch.isUseMetricStoreUrl(),
+		spillTimes:        producerBatch.spillTimes() + 1,
+	}).marshal()
+
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if queue.closed {
+		return errors.New("spill queue is closed")
+	}
+	if queue.file != nil && queue.fileSize+int64(len(data)) > queue.segmentBytes {
+		queue.closeSegment()
+	}
+	size := int64(len(data))
+	if queue.file == nil {
+		size += int64(len(spillMagic))
+	}
+	if queue.totalBytes+size > queue.maxBytes {
+		return errSpillFull
+	}
+	if queue.file == nil {
+		if err := queue.createSegment(); err != nil {
+			return err
+		}
+	}
+	n, err := queue.file.Write(data)
+	queue.fileSize += int64(n)
+	queue.totalBytes += int64(n)
+	if err != nil {
+		// a torn record ends the segment on recovery, so later records go to a new one
+		queue.closeSegment()
+		return err
+	}
+	if queue.syncPolicy == SpillSyncAlways {
+		return queue.file.Sync()
+	}
+	return nil
+}
+
+func (queue *spillQueue) createSegment() error {
+	queue.seq++
+	file, err := os.OpenFile(queue.segmentPath(queue.seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	n, err := file.Write(spillMagic)
+	queue.totalBytes += int64(n)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	queue.file, queue.fileSize = file, int64(n)
+	return nil
+}
+
+func (queue *spillQueue) closeSegment() {
+	if queue.syncPolicy != SpillSyncNever {
+		if err := queue.file.Sync(); err != nil {
+			level.Warn(queue.logger).Log("msg", "failed to sync spill segment", "file", queue.file.Name(), "error", err)
+		}
+	}
+	queue.file.Close()
+	queue.file, queue.fileSize = nil, 0
+}
+
+// size returns the total size of segments in the directory.
+func (queue *spillQueue) size() int64 {
+	if queue == nil {
+		return 0
+	}
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return queue.totalBytes
+}
+
+// sync fsyncs the current segment unless the policy is SpillSyncNever.
+func (queue *spillQueue) sync() {
+	if queue == nil {
+		return
+	}
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if queue.file != nil && queue.syncPolicy != SpillSyncNever {
+		if err := queue.file.Sync(); err != nil {
+			level.Warn(queue.logger).Log("msg", "failed to sync spill segment", "file", queue.file.Name(), "error", err)
+		}
+	}
+}
+
+func (queue *spillQueue) close() {
+	if queue == nil {
+		return
+	}
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if queue.file != nil {
+		queue.closeSegment()
+	}
+	queue.closed = true
+}
+
+// replay calls fn with the records of the segments written by previous producers
+// in order, until fn returns false to leave the record and the rest unread. A segment is removed once it is read to the
+// end and every record passed to fn is released with spillSegment.done.
+//
+// If the replay of a segment is interrupted, the number of records passed to fn is saved
+// once they are all released, and the next producer replays the segment from there.
+// Records being sent when the process exits are not saved, so they may be sent again.
+//
+// Recovery tolerates corruption: a segment with an unknown header is renamed
+// with suffix .corrupt, and the records following a torn or corrupted record
+// of a segment are dropped.
+func (queue *spillQueue) replay(fn func(record *spillRecord, segment *spillSegment) bool) {
+	queue.lock.Lock()
+	paths := queue.recoverable
+	queue.recoverable = nil
+	queue.lock.Unlock()
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			level.Error(queue.logger).Log("msg", "failed to read spill segment", "file", path, "error", err)
+			continue
+		}
+		if len(data) < len(spillMagic) || string(data[:len(spillMagic)]) != string(spillMagic) {
+			level.Warn(queue.logger).Log("msg", "drop spill segment with unknown header", "file", path)
+			if err := os.Rename(path, path+spillCorruptSuffix); err == nil {
+				queue.release(int64(len(data)))
+				os.Remove(path + spillOffsetSuffix)
+			}
+			continue
+		}
+		records, dropped := parseSpillSegment(data[len(spillMagic):])
+		if dropped > 0 {
+			level.Warn(queue.logger).Log("msg", "drop corrupted tail of spill segment", "file", path, "bytes", dropped, "records", len(records))
+		}
+		offset := queue.readOffset(path, len(records))
+		segment := &spillSegment{queue: queue, path: path, size: int64(len(data)), refs: 1,
+			records: len(records), offset: offset, replayed: len(records)}
+		level.Info(queue.logger).Log("msg", "replay spill segment", "file", path, "records", len(records)-offset)
+		for j := offset; j < len(records); j++ {
+			atomic.AddInt32(&segment.refs, 1)
+			if !fn(records[j], segment) {
+				atomic.AddInt32(&segment.refs, -1)
+				// the segment and the unread ones are kept for the next producer
+				segment.replayed = j
+				segment.done()
+				return
+			}
+		}
+		segment.done()
+	}
+}
+
+// readOffset returns the number of records of the segment at path replayed by previous producers.
+func (queue *spillQueue) readOffset(path string, records int) int {
+	data, err := os.ReadFile(path + spillOffsetSuffix)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			level.Warn(queue.logger).Log("msg", "failed to read spill offset", "file", path, "error", err)
+		}
+		return 0
+	}
+	offset, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || offset < 0 {
+		level.Warn(queue.logger).Log("msg", "ignore invalid spill offset", "file", path, "offset", string(data))
+		return 0
+	}
+	if offset > records {
+		return records
+	}
+	return offset
+}
+
+// writeOffset saves the number of records of the segment at path that are replayed,
+// the file is replaced by a rename so that a torn write leaves the previous offset.
+func (queue *spillQueue) writeOffset(path string, offset int) error {
+	tmp := path + spillOffsetSuffix + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(strconv.Itoa(offset))
+	if err == nil && queue.syncPolicy != SpillSyncNever {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path+spillOffsetSuffix)
+}
+
+// parseSpillSegment returns the records of a segment until the first invalid
+// one, and the number of bytes following it.
+func parseSpillSegment(data []byte) (records []*spillRecord, dropped int) {
+	for len(data) > 0 {
+		if len(data) < spillRecordHeadSize {
+			return records, len(data)
+		}
+		length := binary.LittleEndian.Uint32(data)
+		if uint64(length) > uint64(len(data)-spillRecordHeadSize) {
+			return records, len(data)
+		}
+		payload := data[spillRecordHeadSize : spillRecordHeadSize+int(length)]
+		if crc32.Checksum(payload, spillCrcTable) != binary.LittleEndian.Uint32(data[4:]) {
+			return records, len(data)
+		}
+		record, err := unmarshalSpillRecord(payload)
+		if err != nil {
+			return records, len(data)
+		}
+		records = append(records, record)
+		data = data[spillRecordHeadSize+int(length):]
+	}
+	return records, 0
+}
+
+func (queue *spillQueue) release(size int64) {
+	queue.lock.Lock()
+	queue.totalBytes -= size
+	queue.lock.Unlock()
+}
+
+// spillSegment is a segment being replayed, referenced by its replayed batches.
+type spillSegment struct {
+	queue    *spillQueue
+	path     string
+	size     int64
+	refs     int32
+	records  int // records parsed from the segment
+	offset   int // records replayed by previous producers
+	replayed int // records replayed so far, less than records if the replay is interrupted
+}
+
+// done releases a reference to the segment, the segment is removed with the last one,
+// unless its replay is interrupted, in which case the replayed records are saved instead.
+func (segment *spillSegment) done() {
+	if atomic.AddInt32(&segment.refs, -1) != 0 {
+		return
+	}
+	logger := segment.queue.logger
+	if segment.replayed < segment.records {
+		if segment.replayed == segment.offset {
+			return
+		}
+		if err := segment.queue.writeOffset(segment.path, segment.replayed); err != nil {
+			level.Warn(logger).Log("msg", "failed to save spill offset", "file", segment.path, "error", err)
+		}
+		return
+	}
+	if err := os.Remove(segment.path); err != nil {
+		level.Warn(logger).Log("msg", "failed to remove spill segment", "file", segment.path, "error", err)
+		return
+	}
+	if err := os.Remove(segment.path + spillOffsetSuffix); err != nil && !os.IsNotExist(err) {
+		level.Warn(logger).Log("msg", "failed to remove spill offset", "file", segment.path, "error", err)
+	}
+	segment.queue.release(segment.size)
+}
@@ -0,0 +1,369 @@
+package producer
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSpillTestConfig(dir string) *ProducerConfig {
+	config := GetDefaultProducerConfig()
+	config.SpillDir = dir
+	config.SpillSegmentBytes = 256
+	config.Logger = log.NewNopLogger()
+	return validateProducerConfig(config, config.Logger)
+}
+
+func newSpillTestBatch(config *ProducerConfig, shardHash string, content string) *ProducerBatch {
+	batch := newProducerBatch(newPackIdGenerator(), "project", "logstore", "topic", "source", shardHash, config)
+	batch.addLog(GenerateLog(1700000000, map[string]string{"content": content}), nil)
+	return batch
+}
+
+func spillSegments(t *testing.T, dir string) []string {
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+spillSegmentSuffix))
+	require.NoError(t, err)
+	return segments
+}
+
+func TestSpillQueue(t *testing.T) {
+	config := newSpillTestConfig(t.TempDir())
+	queue, err := openSpillQueue(config, config.Logger)
+	require.NoError(t, err)
+	var expected [][]byte
+	for i := 0; i < 10; i++ {
+		batch := newSpillTestBatch(config, []string{"", "hash"}[i%2], string(rune('a'+i)))
+		require.NoError(t, queue.write(batch))
+		expected = append(expected, batch.logGroupBytes())
+	}
+	queue.close()
+	assert.Greater(t, len(spillSegments(t, config.SpillDir)), 1)
+
+	queue, err = openSpillQueue(config, config.Logger)
+	require.NoError(t, err)
+	assert.Greater(t, queue.size(), int64(0))
+	require.NoError(t, queue.write(newSpillTestBatch(config, "", "new")))
+	var segments []*spillSegment
+	var replayed [][]byte
+	queue.replay(func(record *spillRecord, segment *spillSegment) bool {
+		assert.Equal(t, "project", record.project)
+		assert.Equal(t, "logstore", record.logstore)
+		assert.Equal(t, 1, record.logCount)
+		assert.Equal(t, 1, record.spillTimes)
+		if len(replayed)%2 == 1 {
+			assert.Equal(t, "hash", *record.shardHash)
+		} else {
+			assert.Nil(t, record.shardHash)
+		}
+		replayed = append(replayed, record.logGroup)
+		segments = append(segments, segment)
+		return true
+	})
+	// segments written by the queue itself are not replayed
+	assert.Equal(t, expected, replayed)
+	for _, segment := range segments {
+		segment.done()
+	}
+	assert.Len(t, spillSegments(t, config.SpillDir), 1)
+	queue.close()
+}
+
+func TestSpillQueueRecovery(t *testing.T) {
+	config := newSpillTestConfig(t.TempDir())
+	config.SpillSegmentBytes = 1024 * 1024
+	queue, err := openSpillQueue(config, config.Logger)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, queue.write(newSpillTestBatch(config, "", "content")))
+	}
+	queue.close()
+	segments := spillSegments(t, config.SpillDir)
+	require.Len(t, segments, 1)
+
+	// a torn record at the end of a segment
+	data, err := ioutil.ReadFile(segments[0])
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(segments[0], data[:len(data)-3], 0644))
+	// a segment with an unknown header
+	corrupt := filepath.Join(config.SpillDir, "00000000000000000009"+spillSegmentSuffix)
+	require.NoError(t, ioutil.WriteFile(corrupt, []byte("garbage"), 0644))
+
+	queue, err = openSpillQueue(config, config.Logger)
+	require.NoError(t, err)
+	count := 0
+	queue.replay(func(record *spillRecord, segment *spillSegment) bool {
+		count++
+		segment.done()
+		return true
+	})
+	assert.Equal(t, 2, count)
+	assert.Empty(t, spillSegments(t, config.SpillDir))
+	_, err = os.Stat(corrupt + spillCorruptSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), queue.size())
+
+	// stop replaying, the segment is kept for next time
+	queue, err = openSpillQueue(config, config.Logger)
+	require.NoError(t, err)
+	for _, content := range []string{"a", "b", "c"} {
+		require.NoError(t, queue.write(newSpillTestBatch(config, "", content)))
+	}
+	queue.close()
+	queue, err = openSpillQueue(config, config.Logger)
+	require.NoError(t, err)
+	var replayed *spillSegment
+	queue.replay(func(record *spillRecord, segment *spillSegment) bool {
+		if replayed != nil {
+			return false
+		}
+		replayed = segment
+		return true
+	})
+	segments = spillSegments(t, config.SpillDir)
+	require.Len(t, segments, 1)
+	// the offset is saved once the replayed record is done
+	_, err = os.Stat(segments[0] + spillOffsetSuffix)
+	assert.True(t, os.IsNotExist(err))
+	replayed.done()
+	offset, err := ioutil.ReadFile(segments[0] + spillOffsetSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(offset))
+
+	// the records replayed before are skipped
+	queue, err = openSpillQueue(config, config.Logger)
+	require.NoError(t, err)
+	var contents []string
+	queue.replay(func(record *spillRecord, segment *spillSegment) bool {
+		logGroup := &sls.LogGroup{}
+		require.NoError(t, proto.Unmarshal(record.logGroup, logGroup))
+		contents = append(contents, logGroup.Logs[0].Contents[0].GetValue())
+		segment.done()
+		return true
+	})
+	assert.Equal(t, []string{"b", "c"}, contents)
+	assert.Empty(t, spillSegments(t, config.SpillDir))
+	_, err = os.Stat(segments[0] + spillOffsetSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSpillQueueMaxBytes(t *testing.T) {
+	config := newSpillTestConfig(t.TempDir())
+	config.SpillMaxBytes = 100
+	queue, err := openSpillQueue(config, config.Logger)
+	require.NoError(t, err)
+	defer queue.close()
+	require.NoError(t, queue.write(newSpillTestBatch(config, "", "content")))
+	assert.True(t, errors.Is(queue.write(newSpillTestBatch(config, "", "content")), errSpillFull))
+}
+
+func TestSpillTimes(t *testing.T) {
+	config := newSpillTestConfig(t.TempDir())
+	queue, err := openSpillQueue(config, config.Logger)
+	require.NoError(t, err)
+	require.NoError(t, queue.write(newSpillTestBatch(config, "", "content")))
+	queue.close()
+
+	for i := 1; i <= 2; i++ {
+		queue, err = openSpillQueue(config, config.Logger)
+		require.NoError(t, err)
+		queue.replay(func(record *spillRecord, segment *spillSegment) bool {
+			assert.Equal(t, i, record.spillTimes)
+			batch := newSpilledProducerBatch(record, segment, config)
+			assert.Equal(t, i, batch.spillTimes())
+			require.NoError(t, queue.write(batch))
+			batch.release()
+			return true
+		})
+		queue.close()
+	}
+}
+
+type spillTestCallback struct {
+	results chan *Result
+	spilled chan *Result
+}
+
+func (callback *spillTestCallback) Success(result *Result) { callback.results <- result }
+func (callback *spillTestCallback) Fail(result *Result)    { callback.results <- result }
+func (callback *spillTestCallback) Spilled(result *Result) { callback.spilled <- result }
+
+func TestProducerSpill(t *testing.T) {
+	var lock sync.Mutex
+	var received []*sls.LogGroup
+	// a closed server refuses connections
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		logGroup := &sls.LogGroup{}
+		if err := proto.Unmarshal(body, logGroup); err == nil {
+			lock.Lock()
+			received = append(received, logGroup)
+			lock.Unlock()
+		}
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	newProducer := func(endpoint string) *Producer {
+		config := GetDefaultProducerConfig()
+		config.Endpoint = endpoint
+		config.CredentialsProvider = sls.NewStaticCredentialsProvider("id", "key", "")
+		config.CompressType = sls.Compress_None
+		config.Retries = 0
+		config.LingerMs = 100
+		config.SpillDir = dir
+		config.Logger = log.NewNopLogger()
+		config.DisableRuntimeMetrics = true
+		producer, err := NewProducer(config)
+		require.NoError(t, err)
+		return producer
+	}
+
+	producer := newProducer(down.URL)
+	producer.Start()
+	callback := &spillTestCallback{results: make(chan *Result, 1), spilled: make(chan *Result, 1)}
+	log := GenerateLog(1700000000, map[string]string{"content": "spilled"})
+	require.NoError(t, producer.SendLogWithCallBack("", "logstore", "topic", "source", log, callback))
+	select {
+	case result := <-callback.spilled:
+		assert.False(t, result.IsSuccessful())
+		assert.True(t, errors.Is(result.Err(), ErrBatchSpilled))
+		// the failed send and the spill are both recorded
+		require.Len(t, result.GetReservedAttempts(), 2)
+		assert.Equal(t, "ClientError", result.GetErrorCode())
+		assert.Equal(t, ErrBatchSpilled.Error(), result.GetErrorMessage())
+	case <-time.After(10 * time.Second):
+		t.Fatal("callback is not notified")
+	}
+	producer.SafeClose()
+	assert.Empty(t, callback.results)
+	assert.Len(t, spillSegments(t, dir), 1)
+	assert.Equal(t, int64(1), producer.Stats().SpillCount)
+
+	producer = newProducer(ts.URL)
+	assert.Greater(t, producer.Stats().SpillBytes, int64(0))
+	producer.Start()
+	require.Eventually(t, func() bool { return len(spillSegments(t, dir)) == 0 }, 10*time.Second, 10*time.Millisecond)
+	require.NoError(t, producer.Close(10000))
+	producer.spill.lock.Lock()
+	assert.True(t, producer.spill.closed)
+	producer.spill.lock.Unlock()
+	lock.Lock()
+	defer lock.Unlock()
+	require.Len(t, received, 1)
+	assert.Equal(t, "topic", received[0].GetTopic())
+	require.Len(t, received[0].Logs, 1)
+	assert.Equal(t, log.String(), received[0].Logs[0].String())
+	assert.Equal(t, int64(0), producer.Stats().SpillBytes)
+}
+
+func TestReplayWaitMemory(t *testing.T) {
+	config := GetDefaultProducerConfig()
+	config.Logger = log.NewNopLogger()
+	config.DisableRuntimeMetrics = true
+	config.TotalSizeLnBytes = 100
+	producer, err := NewProducer(config)
+	require.NoError(t, err)
+	atomic.StoreInt64(&producer.producerLogGroupSize, 80)
+	assert.True(t, producer.waitReplayMemory(20))
+
+	waitResult := func() chan bool {
+		result := make(chan bool, 1)
+		go func() { result <- producer.waitReplayMemory(50) }()
+		return result
+	}
+	result := waitResult()
+	select {
+	case <-result:
+		t.Fatal("replay does not wait for memory")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// woken up by the memory released
+	producer.releaseMemory(40)
+	select {
+	case ok := <-result:
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("replay is not woken up by the memory released")
+	}
+
+	// woken up by closing the producer
+	atomic.StoreInt64(&producer.producerLogGroupSize, 80)
+	result = waitResult()
+	time.Sleep(50 * time.Millisecond)
+	producer.sendCloseProdcerSignal()
+	select {
+	case ok := <-result:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("replay is not woken up by closing the producer")
+	}
+}
+
+type deadLetterFunc func(letter *DeadLetter) error
+
+func (fn deadLetterFunc) WriteDeadLetter(letter *DeadLetter) error { return fn(letter) }
+
+func TestProducerSpillLastingError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"errorCode":"Unauthorized","errorMessage":"denied"}`))
+	}))
+	defer ts.Close()
+
+	letters := make(chan *DeadLetter, 1)
+	config := GetDefaultProducerConfig()
+	config.Endpoint = ts.URL
+	config.CredentialsProvider = sls.NewStaticCredentialsProvider("id", "key", "")
+	config.Retries = 0
+	config.LingerMs = 100
+	config.SpillDir = t.TempDir()
+	config.DeadLetterSink = deadLetterFunc(func(letter *DeadLetter) error {
+		letters <- letter
+		return nil
+	})
+	config.Logger = log.NewNopLogger()
+	config.DisableRuntimeMetrics = true
+	producer, err := NewProducer(config)
+	require.NoError(t, err)
+	producer.Start()
+	defer producer.SafeClose()
+
+	// a batch failed with an error lasting across restarts is not spilled
+	callback := &spillTestCallback{results: make(chan *Result, 1), spilled: make(chan *Result, 1)}
+	require.NoError(t, producer.SendLogWithCallBack("", "logstore", "topic", "source",
+		GenerateLog(1700000000, map[string]string{"content": "denied"}), callback))
+	select {
+	case result := <-callback.results:
+		assert.Equal(t, "Unauthorized", result.GetErrorCode())
+	case <-time.After(10 * time.Second):
+		t.Fatal("callback is not called")
+	}
+	select {
+	case letter := <-letters:
+		assert.Equal(t, "logstore", letter.Logstore)
+	case <-time.After(10 * time.Second):
+		t.Fatal("dead letter is not written")
+	}
+	assert.Empty(t, callback.spilled)
+	assert.Empty(t, spillSegments(t, config.SpillDir))
+
+	// a batch spilled spillMaxTimes already is not spilled again
+	batch := newSpilledProducerBatch(&spillRecord{project: "project", logstore: "logstore",
+		logGroup: []byte{}, spillTimes: spillMaxTimes}, nil, producer.producerConfig)
+	assert.False(t, producer.mover.ioWorker.spill(batch, sls.NewClientError(errors.New("network")), time.Now()))
+}
@@ -0,0 +1,71 @@
+package producer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type parentKey struct{}
+
+type testSpan struct{}
+
+func (testSpan) SetAttributes(attrs ...sls.Attribute) {}
+func (testSpan) RecordError(err error)                {}
+func (testSpan) End()                                 {}
+
+// testTracer records the name of the parent of every span started.
+type testTracer struct {
+	lock    sync.Mutex
+	parents map[string]string
+}
+
+func (tracer *testTracer) Start(ctx context.Context, name string, attrs ...sls.Attribute) (context.Context, sls.Span) {
+	parent, _ := ctx.Value(parentKey{}).(string)
+	tracer.lock.Lock()
+	tracer.parents[name] = parent
+	tracer.lock.Unlock()
+	return context.WithValue(ctx, parentKey{}, name), testSpan{}
+}
+
+func TestProducerTracingWithTokenClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	tracer := &testTracer{parents: map[string]string{}}
+	config := GetDefaultProducerConfig()
+	config.Endpoint = ts.URL
+	config.UpdateStsToken = func() (accessKeyID, accessKeySecret, securityToken string, expireTime time.Time, err error) {
+		return "id", "key", "token", time.Now().Add(time.Hour), nil
+	}
+	config.StsTokenShutDown = make(chan struct{})
+	config.Tracer = tracer
+	config.LingerMs = 100
+	config.Logger = log.NewNopLogger()
+	config.DisableRuntimeMetrics = true
+	producer, err := NewProducer(config)
+	require.NoError(t, err)
+	_, ok := producer.mover.ioWorker.client.(*sls.TokenAutoUpdateClient)
+	require.True(t, ok)
+	producer.Start()
+	defer producer.SafeClose()
+
+	require.NoError(t, producer.SendLog("", "logstore", "topic", "source", GenerateLog(1700000000, map[string]string{"k": "v"})))
+	result, err := producer.Flush(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.SuccessCount)
+
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+	// the request span is a child of the batch span
+	assert.Equal(t, "", tracer.parents["sls producer send"])
+	assert.Equal(t, "sls producer send", tracer.parents["sls POST /logstores/{}"])
+}
@@ -24,6 +24,8 @@ func GetTimeMs(t int64) int64 {
 	return t / 1000 / 1000
 }
 
+// GetLogSizeCalculate estimates the size of log from its keys and values,
+// batches account the encoded size of logs instead.
 func GetLogSizeCalculate(log *sls.Log) int {
 	sizeInBytes := 4
 	logContent := log.GetContents()
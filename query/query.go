@@ -0,0 +1,65 @@
+// Package query builds SLS queries, the search syntax and the analytic SQL,
+// with values quoted and escaped, so that queries are not assembled by string
+// concatenation.
+//
+//	q, err := query.New(query.And(
+//		query.F("status").Ge(500),
+//		query.Not(query.F("path").Prefix("/health")),
+//		query.Text(userInput),
+//	)).Analyze(query.Select(
+//		query.DateTrunc("minute", query.TimeColumn).As("t"),
+//		query.CountAll().As("errors"),
+//	).GroupBy(query.Col("t")).OrderBy(query.Col("t")).Limit(100, 0)).Build()
+//
+// The result is the query of GetLogRequest, GetHistogramsRequest, PullLogRequest
+// and alert queries:
+//
+//	status >= 500 and not path:/health* and "..." | SELECT date_trunc('minute', "__time__") AS "t", ...
+package query
+
+import "fmt"
+
+// Query is a query made of a search part and an optional analytic SQL.
+type Query struct {
+	search Expr
+	sql    *SQL
+}
+
+// New returns a query matching search, All if nil.
+func New(search Expr) *Query {
+	if search == nil {
+		search = All()
+	}
+	return &Query{search: search}
+}
+
+// Analyze runs sql on the logs matching the search part.
+func (q *Query) Analyze(sql *SQL) *Query {
+	q.sql = sql
+	return q
+}
+
+// Build validates the query and returns it as a string.
+func (q *Query) Build() (string, error) {
+	if err := q.search.validate(); err != nil {
+		return "", fmt.Errorf("invalid search: %w", err)
+	}
+	query := q.search.String()
+	if q.sql == nil {
+		return query, nil
+	}
+	sql, err := q.sql.Build()
+	if err != nil {
+		return "", fmt.Errorf("invalid sql: %w", err)
+	}
+	return query + " | " + sql, nil
+}
+
+// MustBuild is like Build but panics if the query is invalid, for queries known at compile time.
+func (q *Query) MustBuild() string {
+	query, err := q.Build()
+	if err != nil {
+		panic(err)
+	}
+	return query
+}
@@ -0,0 +1,80 @@
+package query
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	cases := []struct {
+		expr     Expr
+		expected string
+	}{
+		{All(), `*`},
+		{Text(`say "hi" \ or not`), `"say \"hi\" \\ or not"`},
+		{F("status").Eq("200"), `status:"200"`},
+		{F("user-agent").Eq("curl"), `"user-agent":"curl"`},
+		{F("request.path").Prefix("/api"), `request.path:/api*`},
+		{F("trace_id").Exists(), `trace_id:*`},
+		{F("latency").Ge(1.5), `latency >= 1.5`},
+		{F("status").Between(500, 599), `status in [500 599]`},
+		{And(F("a").Eq("1"), Or(F("b").Eq("2"), F("c").Eq("3"))), `a:"1" and (b:"2" or c:"3")`},
+		{Or(And(F("a").Eq("1"), F("b").Eq("2")), F("c").Eq("3")), `a:"1" and b:"2" or c:"3"`},
+		{Not(Or(F("a").Eq("1"), F("b").Eq("2"))), `not (a:"1" or b:"2")`},
+		{And(Or(F("a").Eq("1"))), `a:"1"`},
+	}
+	for _, c := range cases {
+		q, err := New(c.expr).Build()
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, q)
+	}
+
+	for _, invalid := range []Expr{
+		Text(""), F("").Eq("1"), F("a").Eq(""), F("a").Prefix("x y"), F("a").Between(2, 1),
+		F("a").Gt(math.NaN()), And(), Not(nil), Or(F("a").Eq("1"), F("b").Prefix("\"")),
+	} {
+		_, err := New(invalid).Build()
+		assert.Error(t, err, invalid.String())
+	}
+}
+
+func TestAnalytic(t *testing.T) {
+	q, err := New(And(F("status").Ge(500), Text("timeout"))).Analyze(
+		Select(
+			DateTrunc("minute", TimeColumn).As("t"),
+			CountAll().As("errors"),
+			ApproxDistinct(Col("client_ip")).As("clients"),
+		).
+			Where(Col("path").Like("/api/%").And(Col("method").In("GET", "HEAD").Or(Col("retry").IsNull()))).
+			GroupBy(Col("t")).
+			Having(CountAll().Gt(10)).
+			OrderByDesc(Col("errors")).
+			Limit(100, 20)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, `status >= 500 and "timeout" | SELECT date_trunc('minute', "__time__") AS "t", `+
+		`count(*) AS "errors", approx_distinct("client_ip") AS "clients" `+
+		`WHERE "path" LIKE '/api/%' AND ("method" IN ('GET', 'HEAD') OR "retry" IS NULL) `+
+		`GROUP BY "t" HAVING count(*) > 10 ORDER BY "errors" DESC LIMIT 20, 100`, q)
+
+	q, err = New(nil).Analyze(Select(Col(`we"ird`)).Where(Col("name").Eq("o'brien").Not())).Build()
+	require.NoError(t, err)
+	assert.Equal(t, `* | SELECT "we""ird" WHERE NOT "name" = 'o''brien'`, q)
+
+	for _, invalid := range []*SQL{
+		Select(),
+		Select(Col("")),
+		Select(CountAll().As("")),
+		Select(DateTrunc("fortnight", TimeColumn)),
+		Select(Func("drop table", Col("a"))),
+		Select(Col("a")).Where(Value{}),
+		Select(Col("a")).Where(Col("a").In()),
+		Select(Col("a")).Where(Col("a").Eq(struct{}{})),
+		Select(Col("a")).Limit(-1, 0),
+	} {
+		_, err := New(nil).Analyze(invalid).Build()
+		assert.Error(t, err)
+	}
+}
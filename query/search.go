@@ -0,0 +1,221 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expr is an expression of the search syntax.
+type Expr interface {
+	// String returns the expression in the search syntax, it does not validate the expression.
+	String() string
+	validate() error
+	precedence() int
+}
+
+// precedences of the search operators, atoms bind tighter than any operator
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precAtom
+)
+
+var (
+	plainFieldRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	// characters of the search syntax that can not appear in an unquoted term
+	specialChars = " \t\r\n\"':()[]{}<>=,;|*?\\"
+)
+
+// term is an atom of the search syntax
+type term struct {
+	text string
+	err  error
+}
+
+func (t *term) String() string  { return t.text }
+func (t *term) validate() error { return t.err }
+func (t *term) precedence() int { return precAtom }
+
+// All matches every log, it is the search part of analytic queries without conditions.
+func All() Expr {
+	return &term{text: "*"}
+}
+
+// Text matches logs containing text in any indexed field, text is quoted so
+// that it is matched as a phrase and the keywords of the syntax lose their meaning.
+func Text(text string) Expr {
+	if text == "" {
+		return &term{err: fmt.Errorf("empty search text")}
+	}
+	return &term{text: quote(text)}
+}
+
+// Field references an indexed field in the search syntax.
+type Field struct {
+	name string
+}
+
+// F returns a reference to the indexed field name, json sub fields are separated by dots.
+func F(name string) Field {
+	return Field{name: name}
+}
+
+func (f Field) key() (string, error) {
+	if f.name == "" {
+		return "", fmt.Errorf("empty field name")
+	}
+	if plainFieldRe.MatchString(f.name) {
+		return f.name, nil
+	}
+	return quote(f.name), nil
+}
+
+func (f Field) term(format string, args ...interface{}) Expr {
+	key, err := f.key()
+	if err != nil {
+		return &term{err: err}
+	}
+	return &term{text: key + fmt.Sprintf(format, args...)}
+}
+
+// Eq matches logs whose field contains value, value is quoted and matched as a phrase.
+func (f Field) Eq(value string) Expr {
+	if value == "" {
+		return &term{err: fmt.Errorf("empty value of field %s", f.name)}
+	}
+	return f.term(":%s", quote(value))
+}
+
+// Prefix matches logs whose field contains a word starting with prefix.
+func (f Field) Prefix(prefix string) Expr {
+	if prefix == "" || strings.ContainsAny(prefix, specialChars) {
+		return &term{err: fmt.Errorf("invalid prefix %q of field %s", prefix, f.name)}
+	}
+	return f.term(":%s*", prefix)
+}
+
+// Exists matches logs that have the field.
+func (f Field) Exists() Expr {
+	return f.term(":*")
+}
+
+// NumEq, Gt, Ge, Lt and Le compare a numeric field, which must be indexed as long or double.
+func (f Field) NumEq(value float64) Expr { return f.compare("=", value) }
+func (f Field) Gt(value float64) Expr    { return f.compare(">", value) }
+func (f Field) Ge(value float64) Expr    { return f.compare(">=", value) }
+func (f Field) Lt(value float64) Expr    { return f.compare("<", value) }
+func (f Field) Le(value float64) Expr    { return f.compare("<=", value) }
+
+func (f Field) compare(op string, value float64) Expr {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return &term{err: fmt.Errorf("invalid number %v of field %s", value, f.name)}
+	}
+	return f.term(" %s %s", op, formatNumber(value))
+}
+
+// Between matches logs whose numeric field is in [lo, hi].
+func (f Field) Between(lo, hi float64) Expr {
+	if math.IsNaN(lo) || math.IsNaN(hi) || lo > hi {
+		return &term{err: fmt.Errorf("invalid range [%v, %v] of field %s", lo, hi, f.name)}
+	}
+	return f.term(" in [%s %s]", formatNumber(lo), formatNumber(hi))
+}
+
+type logical struct {
+	op    string
+	exprs []Expr
+}
+
+// And matches logs matching all exprs.
+func And(exprs ...Expr) Expr {
+	return &logical{op: "and", exprs: exprs}
+}
+
+// Or matches logs matching any of exprs.
+func Or(exprs ...Expr) Expr {
+	return &logical{op: "or", exprs: exprs}
+}
+
+func (l *logical) precedence() int {
+	if len(l.exprs) == 1 {
+		return l.exprs[0].precedence()
+	}
+	if l.op == "or" {
+		return precOr
+	}
+	return precAnd
+}
+
+func (l *logical) validate() error {
+	if len(l.exprs) == 0 {
+		return fmt.Errorf("%s without expressions", l.op)
+	}
+	for _, e := range l.exprs {
+		if e == nil {
+			return fmt.Errorf("nil expression in %s", l.op)
+		}
+		if err := e.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *logical) String() string {
+	parts := make([]string, 0, len(l.exprs))
+	for _, e := range l.exprs {
+		if e == nil {
+			continue
+		}
+		parts = append(parts, wrap(e, l.precedence()))
+	}
+	return strings.Join(parts, " "+l.op+" ")
+}
+
+type not struct {
+	expr Expr
+}
+
+// Not matches logs not matching expr.
+func Not(expr Expr) Expr {
+	return &not{expr: expr}
+}
+
+func (n *not) precedence() int { return precNot }
+
+func (n *not) validate() error {
+	if n.expr == nil {
+		return fmt.Errorf("nil expression in not")
+	}
+	return n.expr.validate()
+}
+
+func (n *not) String() string {
+	if n.expr == nil {
+		return "not"
+	}
+	return "not " + wrap(n.expr, precNot)
+}
+
+// wrap returns e in parentheses if it binds looser than prec
+func wrap(e Expr, prec int) string {
+	if e.precedence() < prec {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+// quote returns s in double quotes, with backslashes and double quotes escaped
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,341 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Value is an expression of the analytic SQL: a column, a literal, a function
+// call or a condition. Errors of invalid values are reported when the query is built.
+type Value struct {
+	sql   string
+	prec  int
+	alias string
+	err   error
+}
+
+// precedences of the SQL operators, atoms bind tighter than any operator
+const (
+	sqlPrecOr = iota + 1
+	sqlPrecAnd
+	sqlPrecNot
+	sqlPrecCompare
+	sqlPrecAtom
+)
+
+var (
+	funcNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	timeUnits  = map[string]bool{
+		"second": true, "minute": true, "hour": true, "day": true,
+		"week": true, "month": true, "quarter": true, "year": true,
+	}
+)
+
+// TimeColumn is the column of the log time, in unix seconds.
+var TimeColumn = Col("__time__")
+
+// Col references a column, the name is quoted so that it may contain any character.
+func Col(name string) Value {
+	if name == "" {
+		return Value{err: fmt.Errorf("empty column name")}
+	}
+	return Value{sql: `"` + strings.ReplaceAll(name, `"`, `""`) + `"`, prec: sqlPrecAtom}
+}
+
+// Lit returns a literal of a string, bool or number, strings are quoted and escaped.
+func Lit(v interface{}) Value {
+	switch v := v.(type) {
+	case string:
+		return Value{sql: "'" + strings.ReplaceAll(v, "'", "''") + "'", prec: sqlPrecAtom}
+	case bool:
+		return Value{sql: strconv.FormatBool(v), prec: sqlPrecAtom}
+	case int:
+		return Value{sql: strconv.Itoa(v), prec: sqlPrecAtom}
+	case int64:
+		return Value{sql: strconv.FormatInt(v, 10), prec: sqlPrecAtom}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return Value{err: fmt.Errorf("invalid number %v", v)}
+		}
+		return Value{sql: formatNumber(v), prec: sqlPrecAtom}
+	}
+	return Value{err: fmt.Errorf("unsupported literal %v of type %T", v, v)}
+}
+
+// Raw returns sql as is, it is not validated, never pass user input to it.
+func Raw(sql string) Value {
+	return Value{sql: sql, prec: sqlPrecAtom}
+}
+
+// Func calls the function name with args, which are Values or literals.
+func Func(name string, args ...interface{}) Value {
+	if !funcNameRe.MatchString(name) {
+		return Value{err: fmt.Errorf("invalid function name %q", name)}
+	}
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		v := valueOf(arg)
+		if err := v.check(); err != nil {
+			return Value{err: fmt.Errorf("%s: %w", name, err)}
+		}
+		parts = append(parts, v.sql)
+	}
+	return Value{sql: name + "(" + strings.Join(parts, ", ") + ")", prec: sqlPrecAtom}
+}
+
+// CountAll is count(*).
+func CountAll() Value { return Value{sql: "count(*)", prec: sqlPrecAtom} }
+
+func Count(v Value) Value          { return Func("count", v) }
+func Sum(v Value) Value            { return Func("sum", v) }
+func Avg(v Value) Value            { return Func("avg", v) }
+func Min(v Value) Value            { return Func("min", v) }
+func Max(v Value) Value            { return Func("max", v) }
+func ApproxDistinct(v Value) Value { return Func("approx_distinct", v) }
+
+// DateTrunc truncates the timestamp v to unit, one of second, minute, hour, day, week, month, quarter and year.
+func DateTrunc(unit string, v Value) Value {
+	if !timeUnits[unit] {
+		return Value{err: fmt.Errorf("invalid time unit %q", unit)}
+	}
+	return Func("date_trunc", Lit(unit), v)
+}
+
+// FromUnixTime converts the unix time v, such as TimeColumn, to a timestamp.
+func FromUnixTime(v Value) Value { return Func("from_unixtime", v) }
+
+// DateFormat formats the timestamp v with a MySQL style format, such as '%Y-%m-%d %H:%i:%s'.
+func DateFormat(v Value, format string) Value { return Func("date_format", v, Lit(format)) }
+
+// TimeSeries groups the unix time v by window, such as '1m', and fills the missing windows with padding.
+func TimeSeries(v Value, window, format, padding string) Value {
+	return Func("time_series", v, Lit(window), Lit(format), Lit(padding))
+}
+
+// As names the value in a select list.
+func (v Value) As(alias string) Value {
+	if alias == "" {
+		v.err = fmt.Errorf("empty alias of %s", v.sql)
+		return v
+	}
+	v.alias = alias
+	return v
+}
+
+func (v Value) Eq(other interface{}) Value { return v.compare("=", other) }
+func (v Value) Ne(other interface{}) Value { return v.compare("<>", other) }
+func (v Value) Gt(other interface{}) Value { return v.compare(">", other) }
+func (v Value) Ge(other interface{}) Value { return v.compare(">=", other) }
+func (v Value) Lt(other interface{}) Value { return v.compare("<", other) }
+func (v Value) Le(other interface{}) Value { return v.compare("<=", other) }
+
+// Like matches v with a pattern where % matches any characters and _ a single one.
+func (v Value) Like(pattern string) Value { return v.compare("LIKE", Lit(pattern)) }
+
+// In matches v with any of values.
+func (v Value) In(values ...interface{}) Value {
+	if len(values) == 0 {
+		return Value{err: fmt.Errorf("IN without values")}
+	}
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		item := valueOf(value)
+		if err := item.check(); err != nil {
+			return Value{err: err}
+		}
+		parts = append(parts, item.sql)
+	}
+	return v.binary("IN", Value{sql: "(" + strings.Join(parts, ", ") + ")", prec: sqlPrecAtom}, sqlPrecCompare)
+}
+
+// IsNull matches null values.
+func (v Value) IsNull() Value {
+	if err := v.check(); err != nil {
+		return Value{err: err}
+	}
+	return Value{sql: wrapSQL(v, sqlPrecCompare) + " IS NULL", prec: sqlPrecCompare}
+}
+
+func (v Value) And(other Value) Value { return v.binary("AND", other, sqlPrecAnd) }
+func (v Value) Or(other Value) Value  { return v.binary("OR", other, sqlPrecOr) }
+
+// Not negates the condition v.
+func (v Value) Not() Value {
+	if err := v.check(); err != nil {
+		return Value{err: err}
+	}
+	return Value{sql: "NOT " + wrapSQL(v, sqlPrecNot), prec: sqlPrecNot}
+}
+
+func (v Value) compare(op string, other interface{}) Value {
+	return v.binary(op, valueOf(other), sqlPrecCompare)
+}
+
+func (v Value) binary(op string, other Value, prec int) Value {
+	if err := v.check(); err != nil {
+		return Value{err: err}
+	}
+	if err := other.check(); err != nil {
+		return Value{err: err}
+	}
+	// comparisons are not associative, wrap operands of the same precedence
+	rightPrec := prec
+	if prec == sqlPrecCompare {
+		rightPrec++
+	}
+	return Value{sql: wrapSQL(v, prec) + " " + op + " " + wrapSQL(other, rightPrec), prec: prec}
+}
+
+// check returns the error of v, zero Values are invalid
+func (v Value) check() error {
+	if v.err != nil {
+		return v.err
+	}
+	if v.sql == "" {
+		return fmt.Errorf("empty value")
+	}
+	return nil
+}
+
+func valueOf(v interface{}) Value {
+	if value, ok := v.(Value); ok {
+		return value
+	}
+	return Lit(v)
+}
+
+func wrapSQL(v Value, prec int) string {
+	if v.prec < prec {
+		return "(" + v.sql + ")"
+	}
+	return v.sql
+}
+
+type orderBy struct {
+	value Value
+	desc  bool
+}
+
+// SQL is the analytic part of a query, which is run on the logs matching the search part.
+type SQL struct {
+	columns []Value
+	where   *Value
+	groupBy []Value
+	having  *Value
+	orderBy []orderBy
+
+	hasLimit bool
+	limit    int
+	offset   int
+}
+
+// Select starts an analytic SQL selecting columns.
+func Select(columns ...Value) *SQL {
+	return &SQL{columns: columns}
+}
+
+// Where filters the rows with cond, in addition to the search part.
+func (s *SQL) Where(cond Value) *SQL {
+	s.where = &cond
+	return s
+}
+
+func (s *SQL) GroupBy(values ...Value) *SQL {
+	s.groupBy = append(s.groupBy, values...)
+	return s
+}
+
+func (s *SQL) Having(cond Value) *SQL {
+	s.having = &cond
+	return s
+}
+
+func (s *SQL) OrderBy(v Value) *SQL {
+	s.orderBy = append(s.orderBy, orderBy{value: v})
+	return s
+}
+
+func (s *SQL) OrderByDesc(v Value) *SQL {
+	s.orderBy = append(s.orderBy, orderBy{value: v, desc: true})
+	return s
+}
+
+// Limit returns at most n rows, skipping the first offset ones.
+func (s *SQL) Limit(n, offset int) *SQL {
+	s.hasLimit, s.limit, s.offset = true, n, offset
+	return s
+}
+
+// Build validates s and returns it as a SQL statement.
+func (s *SQL) Build() (string, error) {
+	if len(s.columns) == 0 {
+		return "", fmt.Errorf("select without columns")
+	}
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	for i, c := range s.columns {
+		if err := c.check(); err != nil {
+			return "", err
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(c.sql)
+		if c.alias != "" {
+			b.WriteString(" AS " + Col(c.alias).sql)
+		}
+	}
+	if s.where != nil {
+		if err := s.where.check(); err != nil {
+			return "", err
+		}
+		b.WriteString(" WHERE " + s.where.sql)
+	}
+	if len(s.groupBy) > 0 {
+		b.WriteString(" GROUP BY ")
+		for i, g := range s.groupBy {
+			if err := g.check(); err != nil {
+				return "", err
+			}
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(g.sql)
+		}
+	}
+	if s.having != nil {
+		if err := s.having.check(); err != nil {
+			return "", err
+		}
+		b.WriteString(" HAVING " + s.having.sql)
+	}
+	if len(s.orderBy) > 0 {
+		b.WriteString(" ORDER BY ")
+		for i, o := range s.orderBy {
+			if err := o.value.check(); err != nil {
+				return "", err
+			}
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(o.value.sql)
+			if o.desc {
+				b.WriteString(" DESC")
+			}
+		}
+	}
+	if s.hasLimit {
+		if s.limit < 0 || s.offset < 0 {
+			return "", fmt.Errorf("invalid limit %d, offset %d", s.limit, s.offset)
+		}
+		if s.offset > 0 {
+			fmt.Fprintf(&b, " LIMIT %d, %d", s.offset, s.limit)
+		} else {
+			fmt.Fprintf(&b, " LIMIT %d", s.limit)
+		}
+	}
+	return b.String(), nil
+}
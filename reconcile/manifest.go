@@ -0,0 +1,211 @@
+// Package reconcile applies the desired state of a project, declared in a YAML
+// or JSON manifest, to SLS.
+//
+// Resources are described with the same fields as the json of the sdk models,
+// indexes are nested in logstores and logtail config bindings in machine groups:
+//
+//	project: my-project
+//	logstores:
+//	  - logstoreName: access-log
+//	    ttl: 30
+//	    shardCount: 2
+//	    index:
+//	      line: {token: [",", " "], caseSensitive: false}
+//	machineGroups:
+//	  - groupName: web
+//	    machineIdentifyType: ip
+//	    machineList: [192.168.1.1]
+//	    configs: [nginx-access]
+//	configs: [...]
+//	alerts: [...]
+//	dashboards: [...]
+//
+// Only the fields set in the manifest are compared with the current state and
+// updated, other fields keep their current value.
+//
+//	manifest, err := reconcile.LoadManifest("project.yaml")
+//	plan, err := reconcile.NewPlan(ctx, client, manifest, reconcile.Options{})
+//	fmt.Print(plan)
+//	err = plan.Apply(ctx, client)
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"gopkg.in/yaml.v2"
+)
+
+// Resource is a resource declared in a manifest.
+type Resource[T any] struct {
+	// Spec is decoded from the fields set in the manifest
+	Spec T
+	// fields set in the manifest, decoded as json
+	fields map[string]interface{}
+}
+
+func newResource[T any](fields map[string]interface{}) (*Resource[T], error) {
+	r := &Resource[T]{fields: fields}
+	buf, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &r.Spec); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// LogStore is a logstore declared in a manifest, along with its optional index.
+type LogStore struct {
+	*Resource[sls.LogStore]
+	Index *Resource[sls.Index]
+}
+
+// MachineGroup is a machine group declared in a manifest, Configs are the
+// names of the logtail configs applied to it.
+type MachineGroup struct {
+	*Resource[sls.MachineGroup]
+	Configs []string
+}
+
+// Manifest is the desired state of a project. A nil list means the kind is not
+// managed by the manifest, while an empty one means there is none of the kind.
+type Manifest struct {
+	Project       string
+	LogStores     []*LogStore
+	MachineGroups []*MachineGroup
+	Configs       []*Resource[sls.LogConfig]
+	Alerts        []*Resource[sls.Alert]
+	Dashboards    []*Resource[sls.Dashboard]
+}
+
+// LoadManifest reads and parses the manifest file at path.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// ParseManifest parses a YAML or JSON manifest.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	// round trip through json, so that values compare with the ones returned by SLS
+	buf, err := json.Marshal(normalizeYAML(doc))
+	if err != nil {
+		return nil, err
+	}
+	var root struct {
+		Project       string                   `json:"project"`
+		LogStores     []map[string]interface{} `json:"logstores"`
+		MachineGroups []map[string]interface{} `json:"machineGroups"`
+		Configs       []map[string]interface{} `json:"configs"`
+		Alerts        []map[string]interface{} `json:"alerts"`
+		Dashboards    []map[string]interface{} `json:"dashboards"`
+	}
+	if err := json.Unmarshal(buf, &root); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Project: root.Project}
+	if root.LogStores != nil {
+		m.LogStores = []*LogStore{}
+	}
+	if root.MachineGroups != nil {
+		m.MachineGroups = []*MachineGroup{}
+	}
+	for i, fields := range root.LogStores {
+		logstore := &LogStore{}
+		if indexFields, ok := fields["index"]; ok {
+			delete(fields, "index")
+			indexMap, ok := indexFields.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("logstores[%d].index is not an object", i)
+			}
+			if logstore.Index, err = newResource[sls.Index](indexMap); err != nil {
+				return nil, fmt.Errorf("logstores[%d].index: %w", i, err)
+			}
+		}
+		if logstore.Resource, err = newResource[sls.LogStore](fields); err != nil {
+			return nil, fmt.Errorf("logstores[%d]: %w", i, err)
+		}
+		if logstore.Spec.Name == "" {
+			return nil, fmt.Errorf("logstores[%d].logstoreName is empty", i)
+		}
+		m.LogStores = append(m.LogStores, logstore)
+	}
+	for i, fields := range root.MachineGroups {
+		group := &MachineGroup{}
+		if configs, ok := fields["configs"]; ok {
+			delete(fields, "configs")
+			buf, _ := json.Marshal(configs)
+			if err := json.Unmarshal(buf, &group.Configs); err != nil {
+				return nil, fmt.Errorf("machineGroups[%d].configs: %w", i, err)
+			}
+		}
+		if group.Resource, err = newResource[sls.MachineGroup](fields); err != nil {
+			return nil, fmt.Errorf("machineGroups[%d]: %w", i, err)
+		}
+		if group.Spec.Name == "" {
+			return nil, fmt.Errorf("machineGroups[%d].groupName is empty", i)
+		}
+		m.MachineGroups = append(m.MachineGroups, group)
+	}
+	if m.Configs, err = parseResources(root.Configs, "configs", func(c *sls.LogConfig) string { return c.Name }); err != nil {
+		return nil, err
+	}
+	if m.Alerts, err = parseResources(root.Alerts, "alerts", func(a *sls.Alert) string { return a.Name }); err != nil {
+		return nil, err
+	}
+	if m.Dashboards, err = parseResources(root.Dashboards, "dashboards", func(d *sls.Dashboard) string { return d.DashboardName }); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseResources[T any](items []map[string]interface{}, kind string, name func(*T) string) ([]*Resource[T], error) {
+	if items == nil {
+		return nil, nil
+	}
+	resources := make([]*Resource[T], 0, len(items))
+	for i, fields := range items {
+		r, err := newResource[T](fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %w", kind, i, err)
+		}
+		if name(&r.Spec) == "" {
+			return nil, fmt.Errorf("%s[%d] has no name", kind, i)
+		}
+		resources = append(resources, r)
+	}
+	return resources, nil
+}
+
+// normalizeYAML converts the map[interface{}]interface{} decoded by yaml to map[string]interface{}
+func normalizeYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeYAML(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalizeYAML(value)
+		}
+		return v
+	}
+	return v
+}
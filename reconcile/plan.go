@@ -0,0 +1,544 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+)
+
+// Action is the kind of change made to a resource.
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Resource kinds of changes.
+const (
+	KindLogStore     = "logstore"
+	KindIndex        = "index"
+	KindMachineGroup = "machinegroup"
+	KindConfig       = "config"
+	KindBinding      = "binding"
+	KindAlert        = "alert"
+	KindDashboard    = "dashboard"
+)
+
+const listPageSize = 500
+
+// Options controls how a plan is computed.
+type Options struct {
+	// Prune deletes the logstores, machine groups, configs, config bindings, alerts and
+	// dashboards of the project which are not declared in the manifest. Kinds whose
+	// list is nil in the manifest are left untouched.
+	Prune bool
+}
+
+// Change is a change to be made to one resource.
+type Change struct {
+	Action Action
+	Kind   string
+	// Name of the resource, "config@group" for bindings
+	Name string
+	// Fields set in the manifest whose current value differs, for updates only
+	Fields []string
+
+	apply func(client sls.ClientInterface) error
+}
+
+func (c *Change) String() string {
+	sign := map[Action]string{Create: "+", Update: "~", Delete: "-"}[c.Action]
+	s := fmt.Sprintf("%s %s %s", sign, c.Kind, c.Name)
+	if len(c.Fields) > 0 {
+		s += " (" + strings.Join(c.Fields, ", ") + ")"
+	}
+	return s
+}
+
+// Plan is the list of changes making a project match a manifest, in the order
+// they are applied. Computing a plan does not change anything, so it can be
+// used as a dry run.
+type Plan struct {
+	Project string
+	Changes []*Change
+}
+
+// String formats the plan for humans, one change per line.
+func (p *Plan) String() string {
+	counts := map[Action]int{}
+	for _, c := range p.Changes {
+		counts[c.Action]++
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "project %s: %d to create, %d to update, %d to delete\n",
+		p.Project, counts[Create], counts[Update], counts[Delete])
+	for _, c := range p.Changes {
+		b.WriteString("  " + c.String() + "\n")
+	}
+	return b.String()
+}
+
+// Empty reports whether the project already matches the manifest.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Apply makes the changes of the plan in order, it stops at the first failed one.
+// Apply is idempotent as long as the plan is computed again before applying it,
+// changes already made are not in the new plan.
+func (p *Plan) Apply(ctx context.Context, client sls.ClientInterface) error {
+	for _, c := range p.Changes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.apply(sls.BindContext(client, ctx)); err != nil {
+			return fmt.Errorf("%s %s %s: %w", c.Action, c.Kind, c.Name, err)
+		}
+	}
+	return nil
+}
+
+// Apply computes the plan making the project match the manifest and applies it,
+// the plan is returned even if applying it fails.
+func Apply(ctx context.Context, client sls.ClientInterface, manifest *Manifest, opts Options) (*Plan, error) {
+	plan, err := NewPlan(ctx, client, manifest, opts)
+	if err != nil {
+		return nil, err
+	}
+	return plan, plan.Apply(ctx, client)
+}
+
+// NewPlan reads the current state of the project of the manifest and computes
+// the changes making it match the manifest.
+func NewPlan(ctx context.Context, client sls.ClientInterface, manifest *Manifest, opts Options) (*Plan, error) {
+	if manifest.Project == "" {
+		return nil, errors.New("project of manifest is empty")
+	}
+	b := &planner{
+		ctx:     ctx,
+		client:  sls.BindContext(client, ctx),
+		project: manifest.Project,
+		opts:    opts,
+		plan:    &Plan{Project: manifest.Project},
+	}
+	// resources are created in dependency order and deleted in the reverse order
+	steps := []func(*Manifest) error{
+		b.planLogStores,
+		b.planMachineGroups,
+		b.planConfigs,
+		b.planBindings,
+		b.planDashboards,
+		b.planAlerts,
+	}
+	for _, step := range steps {
+		if err := step(manifest); err != nil {
+			return nil, err
+		}
+	}
+	b.plan.Changes = append(b.plan.Changes, b.deletes...)
+	return b.plan, nil
+}
+
+type planner struct {
+	ctx     context.Context
+	client  sls.ClientInterface
+	project string
+	opts    Options
+	plan    *Plan
+	// deletes are appended after all creates and updates, in the reverse order
+	deletes []*Change
+}
+
+func (b *planner) add(c *Change) {
+	b.plan.Changes = append(b.plan.Changes, c)
+}
+
+func (b *planner) addDelete(c *Change) {
+	b.deletes = append([]*Change{c}, b.deletes...)
+}
+
+// planResource adds a create or update change for desired, get returns nil if the resource does not exist
+func planResource[T any](b *planner, kind, name string, desired *Resource[T], get func() (interface{}, error),
+	create func(c sls.ClientInterface, spec *T) error, update func(c sls.ClientInterface, spec *T) error) error {
+	current, err := get()
+	if err != nil {
+		return fmt.Errorf("get %s %s: %w", kind, name, err)
+	}
+	if current == nil {
+		b.add(&Change{Action: Create, Kind: kind, Name: name, apply: func(c sls.ClientInterface) error {
+			return create(c, &desired.Spec)
+		}})
+		return nil
+	}
+	fields, err := diffFields(desired.fields, current)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	merged, err := mergeSpec[T](current, desired.fields)
+	if err != nil {
+		return err
+	}
+	b.add(&Change{Action: Update, Kind: kind, Name: name, Fields: fields, apply: func(c sls.ClientInterface) error {
+		return update(c, merged)
+	}})
+	return nil
+}
+
+// notFound turns a not found error into a nil resource
+func notFound[T any](v *T, err error) (interface{}, error) {
+	if errors.Is(err, sls.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (b *planner) planLogStores(m *Manifest) error {
+	declared := map[string]bool{}
+	for _, logstore := range m.LogStores {
+		name := logstore.Spec.Name
+		declared[name] = true
+		err := planResource(b, KindLogStore, name, logstore.Resource, func() (interface{}, error) {
+			return notFound(b.client.GetLogStore(b.project, name))
+		}, func(c sls.ClientInterface, spec *sls.LogStore) error {
+			return c.CreateLogStoreV2(b.project, spec)
+		}, func(c sls.ClientInterface, spec *sls.LogStore) error {
+			return c.UpdateLogStoreV2(b.project, spec)
+		})
+		if err != nil {
+			return err
+		}
+		if logstore.Index == nil {
+			continue
+		}
+		err = planResource(b, KindIndex, name, logstore.Index, func() (interface{}, error) {
+			return notFound(b.client.GetIndex(b.project, name))
+		}, func(c sls.ClientInterface, spec *sls.Index) error {
+			return c.CreateIndex(b.project, name, *spec)
+		}, func(c sls.ClientInterface, spec *sls.Index) error {
+			return c.UpdateIndex(b.project, name, *spec)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !b.opts.Prune || m.LogStores == nil {
+		return nil
+	}
+	names, err := b.client.ListLogStore(b.project)
+	if err != nil {
+		return fmt.Errorf("list logstores: %w", err)
+	}
+	b.pruneNames(KindLogStore, names, declared, func(c sls.ClientInterface, name string) error {
+		return c.DeleteLogStore(b.project, name)
+	})
+	return nil
+}
+
+func (b *planner) planMachineGroups(m *Manifest) error {
+	declared := map[string]bool{}
+	for _, group := range m.MachineGroups {
+		name := group.Spec.Name
+		declared[name] = true
+		err := planResource(b, KindMachineGroup, name, group.Resource, func() (interface{}, error) {
+			return notFound(b.client.GetMachineGroup(b.project, name))
+		}, func(c sls.ClientInterface, spec *sls.MachineGroup) error {
+			return c.CreateMachineGroup(b.project, spec)
+		}, func(c sls.ClientInterface, spec *sls.MachineGroup) error {
+			return c.UpdateMachineGroup(b.project, spec)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !b.opts.Prune || m.MachineGroups == nil {
+		return nil
+	}
+	names, err := sls.NewMachineGroupPager(b.client, b.project, listPageSize).All(b.ctx)
+	if err != nil {
+		return fmt.Errorf("list machine groups: %w", err)
+	}
+	b.pruneNames(KindMachineGroup, names, declared, func(c sls.ClientInterface, name string) error {
+		return c.DeleteMachineGroup(b.project, name)
+	})
+	return nil
+}
+
+func (b *planner) planConfigs(m *Manifest) error {
+	declared := map[string]bool{}
+	for _, config := range m.Configs {
+		name := config.Spec.Name
+		declared[name] = true
+		err := planResource(b, KindConfig, name, config, func() (interface{}, error) {
+			return notFound(b.client.GetConfig(b.project, name))
+		}, func(c sls.ClientInterface, spec *sls.LogConfig) error {
+			return c.CreateConfig(b.project, spec)
+		}, func(c sls.ClientInterface, spec *sls.LogConfig) error {
+			return c.UpdateConfig(b.project, spec)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !b.opts.Prune || m.Configs == nil {
+		return nil
+	}
+	names, err := sls.NewConfigPager(b.client, b.project, listPageSize).All(b.ctx)
+	if err != nil {
+		return fmt.Errorf("list configs: %w", err)
+	}
+	b.pruneNames(KindConfig, names, declared, func(c sls.ClientInterface, name string) error {
+		return c.DeleteConfig(b.project, name)
+	})
+	return nil
+}
+
+func (b *planner) planBindings(m *Manifest) error {
+	for _, group := range m.MachineGroups {
+		groupName := group.Spec.Name
+		applied := map[string]bool{}
+		// a machine group to be created has no config applied
+		if !b.planned(Create, KindMachineGroup, groupName) {
+			names, err := b.client.GetAppliedConfigs(b.project, groupName)
+			if err != nil {
+				return fmt.Errorf("get applied configs of machine group %s: %w", groupName, err)
+			}
+			for _, name := range names {
+				applied[name] = true
+			}
+		}
+		desired := map[string]bool{}
+		for _, configName := range group.Configs {
+			desired[configName] = true
+			if applied[configName] {
+				continue
+			}
+			configName := configName
+			b.add(&Change{Action: Create, Kind: KindBinding, Name: configName + "@" + groupName, apply: func(c sls.ClientInterface) error {
+				return c.ApplyConfigToMachineGroup(b.project, configName, groupName)
+			}})
+		}
+		if !b.opts.Prune {
+			continue
+		}
+		for _, configName := range sortedKeys(applied) {
+			if desired[configName] {
+				continue
+			}
+			configName := configName
+			b.addDelete(&Change{Action: Delete, Kind: KindBinding, Name: configName + "@" + groupName, apply: func(c sls.ClientInterface) error {
+				return c.RemoveConfigFromMachineGroup(b.project, configName, groupName)
+			}})
+		}
+	}
+	return nil
+}
+
+func (b *planner) planDashboards(m *Manifest) error {
+	declared := map[string]bool{}
+	for _, dashboard := range m.Dashboards {
+		name := dashboard.Spec.DashboardName
+		declared[name] = true
+		err := planResource(b, KindDashboard, name, dashboard, func() (interface{}, error) {
+			return notFound(b.client.GetDashboard(b.project, name))
+		}, func(c sls.ClientInterface, spec *sls.Dashboard) error {
+			return c.CreateDashboard(b.project, *spec)
+		}, func(c sls.ClientInterface, spec *sls.Dashboard) error {
+			return c.UpdateDashboard(b.project, *spec)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !b.opts.Prune || m.Dashboards == nil {
+		return nil
+	}
+	names, err := sls.NewDashboardPager(b.client, b.project, "", listPageSize).All(b.ctx)
+	if err != nil {
+		return fmt.Errorf("list dashboards: %w", err)
+	}
+	b.pruneNames(KindDashboard, names, declared, func(c sls.ClientInterface, name string) error {
+		return c.DeleteDashboard(b.project, name)
+	})
+	return nil
+}
+
+func (b *planner) planAlerts(m *Manifest) error {
+	declared := map[string]bool{}
+	for _, alert := range m.Alerts {
+		name := alert.Spec.Name
+		declared[name] = true
+		err := planResource(b, KindAlert, name, alert, func() (interface{}, error) {
+			return notFound(b.client.GetAlert(b.project, name))
+		}, func(c sls.ClientInterface, spec *sls.Alert) error {
+			return c.CreateAlert(b.project, spec)
+		}, func(c sls.ClientInterface, spec *sls.Alert) error {
+			return c.UpdateAlert(b.project, spec)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !b.opts.Prune || m.Alerts == nil {
+		return nil
+	}
+	alerts, err := sls.NewAlertPager(b.client, b.project, "", "", listPageSize).All(b.ctx)
+	if err != nil {
+		return fmt.Errorf("list alerts: %w", err)
+	}
+	names := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		names = append(names, alert.Name)
+	}
+	b.pruneNames(KindAlert, names, declared, func(c sls.ClientInterface, name string) error {
+		return c.DeleteAlert(b.project, name)
+	})
+	return nil
+}
+
+// pruneNames adds delete changes for the resources not declared in the manifest
+func (b *planner) pruneNames(kind string, names []string, declared map[string]bool, remove func(c sls.ClientInterface, name string) error) {
+	sort.Strings(names)
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		if declared[name] {
+			continue
+		}
+		b.addDelete(&Change{Action: Delete, Kind: kind, Name: name, apply: func(c sls.ClientInterface) error {
+			return remove(c, name)
+		}})
+	}
+}
+
+func (b *planner) planned(action Action, kind, name string) bool {
+	for _, c := range b.plan.Changes {
+		if c.Action == action && c.Kind == kind && c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// diffFields returns the top level fields set in desired whose current value differs
+func diffFields(desired map[string]interface{}, current interface{}) ([]string, error) {
+	currentMap, err := toMap(current)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	for key, value := range desired {
+		if !contains(value, currentMap[key]) {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// contains reports whether current has the value of every field set in desired,
+// fields missing in current are regarded as their zero value, and so are empty lists and maps
+func contains(desired, current interface{}) bool {
+	if current == nil {
+		switch d := desired.(type) {
+		case map[string]interface{}:
+			for _, value := range d {
+				if !contains(value, nil) {
+					return false
+				}
+			}
+			return true
+		case []interface{}:
+			return len(d) == 0
+		}
+		return desired == nil || reflect.ValueOf(desired).IsZero()
+	}
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		c, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range d {
+			if !contains(value, c[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		c, ok := current.([]interface{})
+		if !ok || len(c) != len(d) {
+			return false
+		}
+		for i := range d {
+			if !contains(d[i], c[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(desired, current)
+}
+
+// mergeSpec overlays the fields set in the manifest on the current resource
+func mergeSpec[T any](current interface{}, fields map[string]interface{}) (*T, error) {
+	merged, err := toMap(current)
+	if err != nil {
+		return nil, err
+	}
+	mergeMaps(merged, fields)
+	buf, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	spec := new(T)
+	if err := json.Unmarshal(buf, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func mergeMaps(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, ok := value.(map[string]interface{})
+		dstMap, ok2 := dst[key].(map[string]interface{})
+		if ok && ok2 {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+func toMap(v interface{}) (map[string]interface{}, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,119 @@
+package reconcile
+
+import (
+	"context"
+	"testing"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/aliyun/aliyun-log-go-sdk/slstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `
+project: my-project
+logstores:
+  - logstoreName: access-log
+    ttl: 30
+    shardCount: 2
+    index:
+      line:
+        token: [",", " "]
+        caseSensitive: false
+  - logstoreName: error-log
+    ttl: 7
+`
+
+func TestPlanAndApply(t *testing.T) {
+	server := slstest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	ctx := context.Background()
+	_, err := client.CreateProject("my-project", "")
+	require.NoError(t, err)
+	require.NoError(t, client.CreateLogStore("my-project", "legacy-log", 1, 1, false, 0))
+
+	manifest, err := ParseManifest([]byte(testManifest))
+	require.NoError(t, err)
+	plan, err := NewPlan(ctx, client, manifest, Options{Prune: true})
+	require.NoError(t, err)
+	assert.Equal(t, "project my-project: 3 to create, 0 to update, 1 to delete\n"+
+		"  + logstore access-log\n"+
+		"  + index access-log\n"+
+		"  + logstore error-log\n"+
+		"  - logstore legacy-log\n", plan.String())
+
+	require.NoError(t, plan.Apply(ctx, client))
+	logstores, err := client.ListLogStore("my-project")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"access-log", "error-log"}, logstores)
+	index, err := client.GetIndex("my-project", "access-log")
+	require.NoError(t, err)
+	assert.Equal(t, []string{",", " "}, index.Line.Token)
+
+	plan, err = NewPlan(ctx, client, manifest, Options{Prune: true})
+	require.NoError(t, err)
+	assert.True(t, plan.Empty(), plan.String())
+
+	manifest.LogStores[1].fields["ttl"] = float64(14)
+	plan, err = Apply(ctx, client, manifest, Options{})
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 1)
+	assert.Equal(t, "~ logstore error-log (ttl)", plan.Changes[0].String())
+	logstore, err := client.GetLogStore("my-project", "error-log")
+	require.NoError(t, err)
+	assert.Equal(t, 14, logstore.TTL)
+}
+
+// createCountingClient counts the logstores created through it
+type createCountingClient struct {
+	sls.ClientInterface
+	created int
+}
+
+func (c *createCountingClient) CreateLogStoreV2(project string, logstore *sls.LogStore) error {
+	c.created++
+	return c.ClientInterface.CreateLogStoreV2(project, logstore)
+}
+
+func TestApplyWithAnotherClient(t *testing.T) {
+	server := slstest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	_, err := client.CreateProject("my-project", "")
+	require.NoError(t, err)
+
+	manifest, err := ParseManifest([]byte(testManifest))
+	require.NoError(t, err)
+	planCtx, cancel := context.WithCancel(context.Background())
+	plan, err := NewPlan(planCtx, client, manifest, Options{})
+	require.NoError(t, err)
+	cancel()
+
+	// the plan is applied with the client and context given to Apply, not those it is computed with
+	another := &createCountingClient{ClientInterface: server.NewClient()}
+	require.NoError(t, plan.Apply(context.Background(), another))
+	assert.Equal(t, 2, another.created)
+	logstores, err := client.ListLogStore("my-project")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"access-log", "error-log"}, logstores)
+}
+
+func TestDiffFieldsMissingInCurrent(t *testing.T) {
+	current := map[string]interface{}{"groupName": "group", "groupAttribute": map[string]interface{}{"groupTopic": "topic"}}
+	desired := map[string]interface{}{
+		"groupName":      "group",
+		"machineList":    []interface{}{},
+		"groupAttribute": map[string]interface{}{"groupTopic": "topic", "externalName": ""},
+		"labels":         map[string]interface{}{},
+	}
+	fields, err := diffFields(desired, current)
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	desired["machineList"] = []interface{}{"127.0.0.1"}
+	desired["labels"] = map[string]interface{}{"env": "prod"}
+	fields, err = diffFields(desired, current)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"labels", "machineList"}, fields)
+}
@@ -7,10 +7,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"time"
 
-	"github.com/cenkalti/backoff"
 	"github.com/go-kit/kit/log/level"
 	"golang.org/x/net/context"
 )
@@ -37,57 +35,6 @@ func newDefaultHTTPClient(requestTimeout time.Duration) *http.Client {
 	}
 }
 
-func retryReadErrorCheck(ctx context.Context, err error) (bool, error) {
-	if err == nil {
-		return false, nil
-	}
-	switch e := err.(type) {
-	case *url.Error:
-		return true, e
-	case *Error:
-		if RetryOnServerErrorEnabled {
-			if e.HTTPCode >= 500 && e.HTTPCode <= 599 {
-				return true, e
-			}
-		}
-	case *BadResponseError:
-		if RetryOnServerErrorEnabled {
-			if e.HTTPCode >= 500 && e.HTTPCode <= 599 {
-				return true, e
-			}
-		}
-	default:
-		return false, e
-	}
-
-	return false, err
-}
-
-func retryWriteErrorCheck(ctx context.Context, err error) (bool, error) {
-	if err == nil {
-		return false, nil
-	}
-
-	switch e := err.(type) {
-	case *Error:
-		if RetryOnServerErrorEnabled {
-			if e.HTTPCode == 500 || e.HTTPCode == 502 || e.HTTPCode == 503 {
-				return true, e
-			}
-		}
-	case *BadResponseError:
-		if RetryOnServerErrorEnabled {
-			if e.HTTPCode == 500 || e.HTTPCode == 502 || e.HTTPCode == 503 {
-				return true, e
-			}
-		}
-	default:
-		return false, e
-	}
-
-	return false, err
-}
-
 // request sends a request to SLS.
 // mock param only for test, default is []
 func request(project *LogProject, method, uri string, headers map[string]string,
@@ -95,50 +42,26 @@ func request(project *LogProject, method, uri string, headers map[string]string,
 
 	var r *http.Response
 	var slsErr error
-	var err error
 	var mockErr *mockErrorRetry
 
 	project.init()
-	ctx, cancel := context.WithTimeout(context.Background(), project.retryTimeout)
+	ctx, cancel := context.WithTimeout(project.context(), project.retryTimeout)
 	defer cancel()
 
-	//fmt.Println("request ", project, method, uri, headers, body)
-	// all GET method is read function
-	if method == http.MethodGet {
-		err = RetryWithCondition(ctx, backoff.NewExponentialBackOff(), func() (bool, error) {
-			if len(mock) == 0 {
-				//fmt.Println("real request", project, method, uri, headers, body)
-				r, slsErr = realRequest(ctx, project, method, uri, headers, body)
-				//fmt.Println("real request done")
-			} else {
-				r, mockErr = nil, mock[0].(*mockErrorRetry)
-				mockErr.RetryCnt--
-				if mockErr.RetryCnt <= 0 {
-					r = &http.Response{}
-					slsErr = nil
-					return false, nil
-				}
-				slsErr = &mockErr.Err
-			}
-			return retryReadErrorCheck(ctx, slsErr)
-		})
-	} else {
-		err = RetryWithCondition(ctx, backoff.NewExponentialBackOff(), func() (bool, error) {
-			if len(mock) == 0 {
-				r, slsErr = realRequest(ctx, project, method, uri, headers, body)
-			} else {
-				r, mockErr = nil, mock[0].(*mockErrorRetry)
-				mockErr.RetryCnt--
-				if mockErr.RetryCnt <= 0 {
-					r = &http.Response{}
-					slsErr = nil
-					return false, nil
-				}
-				slsErr = &mockErr.Err
-			}
-			return retryWriteErrorCheck(ctx, slsErr)
-		})
-	}
+	err := RetryWithPolicy(ctx, project.getRetryPolicy(ctx), method, func() error {
+		if len(mock) == 0 {
+			r, slsErr = realRequest(ctx, project, method, uri, headers, body)
+			return slsErr
+		}
+		r, mockErr = nil, mock[0].(*mockErrorRetry)
+		mockErr.RetryCnt--
+		if mockErr.RetryCnt <= 0 {
+			r, slsErr = &http.Response{}, nil
+			return nil
+		}
+		slsErr = &mockErr.Err
+		return slsErr
+	})
 
 	if err != nil {
 		return r, err
@@ -150,6 +73,24 @@ func request(project *LogProject, method, uri string, headers map[string]string,
 // @note if error is nil, you must call http.Response.Body.Close() to finalize reader
 func realRequest(ctx context.Context, project *LogProject, method, uri string, headers map[string]string,
 	body []byte) (*http.Response, error) {
+	if len(project.interceptors) == 0 {
+		return doRealRequest(ctx, project, method, uri, headers, body)
+	}
+	rt := chainInterceptors(project.interceptors, func(req *RoundTripRequest) (*http.Response, error) {
+		return doRealRequest(req.Context, project, req.Method, req.URI, req.Headers, req.Body)
+	})
+	return rt(&RoundTripRequest{
+		Context: ctx,
+		Project: project.Name,
+		Method:  method,
+		URI:     uri,
+		Headers: headers,
+		Body:    body,
+	})
+}
+
+func doRealRequest(ctx context.Context, project *LogProject, method, uri string, headers map[string]string,
+	body []byte) (*http.Response, error) {
 
 	// The caller should provide 'x-log-bodyrawsize' header
 	if _, ok := headers[HTTPHeaderBodyRawSize]; !ok {
@@ -215,7 +156,7 @@ func realRequest(ctx context.Context, project *LogProject, method, uri string, h
 
 	// Handle the endpoint
 	urlStr := fmt.Sprintf("%s%s", baseURL, uri)
-	req, err := http.NewRequest(method, urlStr, reader)
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, reader)
 	if err != nil {
 		return nil, NewClientError(err)
 	}
@@ -248,6 +189,7 @@ func realRequest(ctx context.Context, project *LogProject, method, uri string, h
 			return nil, NewBadResponseError(string(buf), resp.Header, resp.StatusCode)
 		}
 		err.RequestID = resp.Header.Get(RequestIDHeader)
+		err.retryAfter = parseRetryAfter(resp.Header.Get(retryAfterHeader))
 		return nil, err
 	}
 	if IsDebugLevelMatched(5) {
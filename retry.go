@@ -1,6 +1,8 @@
 package sls
 
 import (
+	"time"
+
 	"golang.org/x/net/context"
 
 	"github.com/cenkalti/backoff"
@@ -82,6 +84,35 @@ func RetryWithCondition(ctx context.Context, b backoff.BackOff, o ConditionOpera
 	}
 }
 
+// RetryWithPolicy execute the input operation immediately at first, and retry
+// it as long as policy allows, until it succeeds or ctx is done.
+// method is the http method of the requests sent by the operation.
+func RetryWithPolicy(ctx context.Context, policy RetryPolicy, method string, o backoff.Operation) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "stopped retrying err: %v", err)
+		default:
+		}
+		err = o()
+		if err == nil {
+			return nil
+		}
+		delay, ok := policy.NextBackOff(method, attempt, err)
+		if !ok {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Wrapf(ctx.Err(), "stopped retrying err: %v", err)
+		}
+	}
+}
+
 // RetryWithAttempt ...
 func RetryWithAttempt(ctx context.Context, maxAttempt int, o ConditionOperation) error {
 	b := backoff.NewExponentialBackOff()
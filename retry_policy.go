@@ -0,0 +1,193 @@
+package sls
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const retryAfterHeader = "Retry-After"
+
+// RetryPolicy decides whether a failed request to SLS is retried, and how long
+// to wait before the next attempt. The retry timeout of the client still bounds
+// the total time spent on a call, whatever the policy returns.
+//
+// A policy may be shared by many goroutines, it must be safe for concurrent use.
+type RetryPolicy interface {
+	// NextBackOff is called after each failed attempt of a request sent with the
+	// http method, attempt starts from 1. It returns the delay before the next
+	// attempt, or false to stop retrying and return err to the caller.
+	NextBackOff(method string, attempt int, err error) (time.Duration, bool)
+}
+
+// RetryPolicyFunc is an adapter to use an ordinary function as a RetryPolicy.
+type RetryPolicyFunc func(method string, attempt int, err error) (time.Duration, bool)
+
+// NextBackOff calls f(method, attempt, err).
+func (f RetryPolicyFunc) NextBackOff(method string, attempt int, err error) (time.Duration, bool) {
+	return f(method, attempt, err)
+}
+
+// NoRetryPolicy never retries, the error of the first attempt is returned.
+var NoRetryPolicy RetryPolicy = RetryPolicyFunc(func(string, int, error) (time.Duration, bool) {
+	return 0, false
+})
+
+// ExponentialRetryPolicy retries with an exponentially growing and randomized interval.
+// The zero value of a field means its default, listed in DefaultRetryPolicy.
+type ExponentialRetryPolicy struct {
+	// MaxAttempts limits the number of attempts including the first one,
+	// 0 means unlimited, only bounded by the retry timeout.
+	MaxAttempts int
+	// InitialInterval is the delay before the second attempt.
+	InitialInterval time.Duration
+	// MaxInterval is the ceiling of the delay between two attempts.
+	MaxInterval time.Duration
+	// Multiplier is the growth factor of the delay after each attempt.
+	Multiplier float64
+	// Jitter randomizes the delay in [delay * (1 - Jitter), delay * (1 + Jitter)],
+	// set it to a negative value to disable randomization.
+	Jitter float64
+	// Retryable reports whether err is retryable, DefaultRetryable is used if nil.
+	Retryable func(method string, err error) bool
+	// RetryThrottled also retries throttling errors, see IsThrottlingError.
+	// The delay is at least the Retry-After hint returned by the server, if any.
+	RetryThrottled bool
+}
+
+// DefaultRetryPolicy returns the policy used when neither the client nor the call
+// sets one, it retries DefaultRetryable errors until the retry timeout, waiting
+// 500ms before the second attempt, growing by 1.5 times with a jitter of 0.5, up to 60s.
+func DefaultRetryPolicy() *ExponentialRetryPolicy {
+	return &ExponentialRetryPolicy{
+		InitialInterval: 500 * time.Millisecond,
+		MaxInterval:     60 * time.Second,
+		Multiplier:      1.5,
+		Jitter:          0.5,
+	}
+}
+
+var defaultRetryPolicy RetryPolicy = DefaultRetryPolicy()
+
+// NextBackOff implements RetryPolicy.
+func (p *ExponentialRetryPolicy) NextBackOff(method string, attempt int, err error) (time.Duration, bool) {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	throttled := p.RetryThrottled && IsThrottlingError(err)
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	if !throttled && !retryable(method, err) {
+		return 0, false
+	}
+
+	initial, maxInterval, multiplier, jitter := p.InitialInterval, p.MaxInterval, p.Multiplier, p.Jitter
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	if maxInterval <= 0 {
+		maxInterval = 60 * time.Second
+	}
+	if multiplier <= 0 {
+		multiplier = 1.5
+	}
+	if jitter == 0 {
+		jitter = 0.5
+	} else if jitter < 0 {
+		jitter = 0
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(maxInterval) {
+		delay = float64(maxInterval)
+	}
+	delay = delay * (1 - jitter + 2*jitter*rand.Float64())
+	backOff := time.Duration(delay)
+	if throttled {
+		if hint := RetryAfter(err); hint > backOff {
+			backOff = hint
+		}
+	}
+	return backOff, true
+}
+
+// DefaultRetryable reports whether err is retried by the default policy.
+// Network errors are retried for GET requests only, 5xx errors are retried for
+// GET requests and 500, 502 and 503 for the others, unless RetryOnServerErrorEnabled is false.
+func DefaultRetryable(method string, err error) bool {
+	var httpCode int
+	switch e := err.(type) {
+	case *url.Error:
+		return method == http.MethodGet
+	case *Error:
+		httpCode = int(e.HTTPCode)
+	case *BadResponseError:
+		httpCode = e.HTTPCode
+	default:
+		return false
+	}
+	if !RetryOnServerErrorEnabled {
+		return false
+	}
+	if method == http.MethodGet {
+		return httpCode >= 500 && httpCode <= 599
+	}
+	return httpCode == 500 || httpCode == 502 || httpCode == 503
+}
+
+// IsThrottlingError reports whether err is returned because a quota is exceeded,
+// eg. WriteQuotaExceed and ShardReadQuotaExceed, or the status code is 429.
+func IsThrottlingError(err error) bool {
+	return errors.Is(err, ErrThrottled)
+}
+
+// RetryAfter returns the delay hinted by the Retry-After header of the
+// response that err is parsed from, 0 if there is no hint.
+func RetryAfter(err error) time.Duration {
+	switch e := err.(type) {
+	case *Error:
+		return e.retryAfter
+	case *BadResponseError:
+		return parseRetryAfter(http.Header(e.RespHeader).Get(retryAfterHeader))
+	}
+	return 0
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+type retryPolicyKey struct{}
+
+// ContextWithRetryPolicy returns a copy of ctx carrying policy, calls bound to
+// the returned context by WithContext use policy instead of the client's one.
+//
+//	ctx := sls.ContextWithRetryPolicy(ctx, &sls.ExponentialRetryPolicy{MaxAttempts: 2})
+//	resp, err := client.WithContext(ctx).GetLogsV2(project, logstore, req)
+func ContextWithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// RetryPolicyFromContext returns the policy set by ContextWithRetryPolicy, or nil.
+func RetryPolicyFromContext(ctx context.Context) RetryPolicy {
+	policy, _ := ctx.Value(retryPolicyKey{}).(RetryPolicy)
+	return policy
+}
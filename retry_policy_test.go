@@ -0,0 +1,76 @@
+package sls
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultRetryable(t *testing.T) {
+	assert.True(t, DefaultRetryable(http.MethodGet, &url.Error{}))
+	assert.False(t, DefaultRetryable(http.MethodPost, &url.Error{}))
+	assert.True(t, DefaultRetryable(http.MethodGet, &Error{HTTPCode: 504}))
+	assert.False(t, DefaultRetryable(http.MethodPost, &Error{HTTPCode: 504}))
+	assert.True(t, DefaultRetryable(http.MethodPost, &BadResponseError{HTTPCode: 502}))
+	assert.False(t, DefaultRetryable(http.MethodGet, &Error{HTTPCode: 404}))
+}
+
+func TestExponentialRetryPolicy(t *testing.T) {
+	policy := &ExponentialRetryPolicy{
+		MaxAttempts:     4,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     300 * time.Millisecond,
+		Multiplier:      2,
+		Jitter:          -1,
+	}
+	serverErr := &Error{HTTPCode: 500}
+	var delays []time.Duration
+	for attempt := 1; ; attempt++ {
+		delay, ok := policy.NextBackOff(http.MethodPost, attempt, serverErr)
+		if !ok {
+			break
+		}
+		delays = append(delays, delay)
+	}
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}, delays)
+
+	throttled := &Error{HTTPCode: 403, Code: WRITE_QUOTA_EXCEED, retryAfter: 2 * time.Second}
+	_, ok := policy.NextBackOff(http.MethodPost, 1, throttled)
+	assert.False(t, ok)
+	policy.RetryThrottled = true
+	delay, ok := policy.NextBackOff(http.MethodPost, 1, throttled)
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, delay)
+}
+
+func TestRetryPolicyPerClientAndPerCall(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set(retryAfterHeader, "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"errorCode":"ServerBusy","errorMessage":"server busy"}`))
+	}))
+	defer ts.Close()
+
+	client := CreateNormalInterfaceWithContext(ts.URL, NewStaticCredentialsProvider("id", "key", ""))
+	client.(ClientInterfaceWithRetryPolicy).SetRetryPolicy(&ExponentialRetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond})
+	_, err := client.GetLogStore("", "my-store")
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Equal(t, time.Second, RetryAfter(err))
+
+	atomic.StoreInt32(&attempts, 0)
+	ctx := ContextWithRetryPolicy(context.Background(), NoRetryPolicy)
+	_, err = client.WithContext(ctx).GetLogStore("", "my-store")
+	slsErr, ok := err.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, "ServerBusy", slsErr.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
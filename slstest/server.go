@@ -0,0 +1,777 @@
+// Package slstest provides an in-process fake SLS server for hermetic tests.
+//
+// The server speaks enough of the SLS REST protocol for sls.Client,
+// producer.Producer and consumerLibrary.ConsumerWorker to run against it:
+// project and logstore CRUD, index CRUD, PostLogStoreLogs with lz4, zstd or
+// uncompressed protobuf bodies, ListShards, GetCursor, PullLogs, and consumer
+// group heartbeat and checkpoints. Signatures are not verified and query based
+// consumption is not supported.
+//
+//	server := slstest.NewServer()
+//	defer server.Close()
+//	client := server.NewClient()
+//
+// Producers and consumers are pointed to the server by using Endpoint and HTTPClient:
+//
+//	config := producer.GetDefaultProducerConfig()
+//	config.Endpoint = server.Endpoint
+//	config.HTTPClient = server.HTTPClient()
+package slstest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	"github.com/gogo/protobuf/proto"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// DefaultEndpoint is the endpoint of servers created by NewServer,
+// ".test" is a reserved top level domain which never resolves.
+const DefaultEndpoint = "sls.test"
+
+// Server is an in-process fake SLS server, all state is kept in memory.
+type Server struct {
+	// Endpoint to be used by clients, project names are prepended to it in
+	// the Host header, it must be used along with HTTPClient.
+	Endpoint string
+
+	httpServer *httptest.Server
+	httpClient *http.Client
+	zstd       sls.LogCompressor
+	requestID  int64
+
+	lock     sync.Mutex
+	projects map[string]*project
+}
+
+type project struct {
+	meta      sls.LogProject
+	logstores map[string]*logstore
+}
+
+type logstore struct {
+	meta           json.RawMessage
+	shards         []*shard
+	index          json.RawMessage
+	consumerGroups map[string]*consumerGroup
+	nextShard      int // round robin for PostLogStoreLogs without hash key
+}
+
+type shard struct {
+	meta      sls.Shard
+	logGroups []*storedLogGroup
+}
+
+type storedLogGroup struct {
+	receiveTime time.Time
+	data        []byte // marshaled sls.LogGroup
+}
+
+type consumerGroup struct {
+	meta        sls.ConsumerGroup
+	checkpoints map[int]*sls.ConsumerGroupCheckPoint
+	heartbeats  map[string]time.Time // consumer name to last heartbeat
+}
+
+// NewServer starts a fake SLS server, it should be closed by Close.
+func NewServer() *Server {
+	s := &Server{
+		Endpoint: DefaultEndpoint,
+		zstd:     sls.NewZstdCompressor(zstd.SpeedFastest),
+		projects: map[string]*project{},
+	}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	addr := s.httpServer.Listener.Addr().String()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, addr)
+	}
+	s.httpClient = &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// HTTPClient returns a http client which sends every request to the server,
+// whatever the host of the request is.
+func (s *Server) HTTPClient() *http.Client {
+	return s.httpClient
+}
+
+// NewClient returns a client sending requests to the server.
+func (s *Server) NewClient() sls.ClientInterface {
+	client := sls.CreateNormalInterfaceV2(s.Endpoint, sls.NewStaticCredentialsProvider("slstest", "slstest", ""))
+	client.SetHTTPClient(s.httpClient)
+	return client
+}
+
+// LogGroups returns all log groups received by a shard, nil if the shard does not exist.
+func (s *Server) LogGroups(projectName, logstoreName string, shardID int) []*sls.LogGroup {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ls := s.getLogstore(projectName, logstoreName)
+	if ls == nil || shardID < 0 || shardID >= len(ls.shards) {
+		return nil
+	}
+	logGroups := make([]*sls.LogGroup, 0, len(ls.shards[shardID].logGroups))
+	for _, stored := range ls.shards[shardID].logGroups {
+		logGroup := &sls.LogGroup{}
+		proto.Unmarshal(stored.data, logGroup)
+		logGroups = append(logGroups, logGroup)
+	}
+	return logGroups
+}
+
+func (s *Server) getLogstore(projectName, logstoreName string) *logstore {
+	p, ok := s.projects[projectName]
+	if !ok {
+		return nil
+	}
+	return p.logstores[logstoreName]
+}
+
+// httpError is returned by handlers and written as a sls error response.
+type httpError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func newError(status int, code, format string, args ...interface{}) *httpError {
+	return &httpError{status: status, code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// response is returned by handlers, body is encoded as json unless it is []byte
+type response struct {
+	header http.Header
+	body   interface{}
+}
+
+func jsonResponse(body interface{}) *response {
+	return &response{body: body}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := fmt.Sprintf("slstest-%d", atomic.AddInt64(&s.requestID, 1))
+	w.Header().Set(sls.RequestIDHeader, requestID)
+
+	resp, err := s.route(r)
+	if err != nil {
+		e, ok := err.(*httpError)
+		if !ok {
+			e = newError(http.StatusInternalServerError, sls.INTERNAL_SERVER_ERROR, "%v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.status)
+		json.NewEncoder(w).Encode(map[string]string{
+			"errorCode":    e.code,
+			"errorMessage": e.message,
+			"requestID":    requestID,
+		})
+		return
+	}
+	for k, v := range resp.header {
+		w.Header()[k] = v
+	}
+	if body, ok := resp.body.([]byte); ok {
+		w.Write(body)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if resp.body == nil {
+		return
+	}
+	json.NewEncoder(w).Encode(resp.body)
+}
+
+func (s *Server) route(r *http.Request) (*response, error) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	endpoint := s.Endpoint
+	if h, _, err := net.SplitHostPort(endpoint); err == nil {
+		endpoint = h
+	}
+	projectName := strings.TrimSuffix(strings.TrimSuffix(host, endpoint), ".")
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "%v", err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if segments[0] == "" {
+		return s.handleProject(r, projectName, body)
+	}
+	if segments[0] != "logstores" {
+		return nil, newError(http.StatusNotImplemented, sls.NOT_SUPPORTED, "%s %s is not supported by slstest", r.Method, r.URL.Path)
+	}
+	p, ok := s.projects[projectName]
+	if !ok {
+		return nil, newError(http.StatusNotFound, sls.PROJECT_NOT_EXIST, "project %s does not exist", projectName)
+	}
+	if len(segments) == 1 {
+		return s.handleLogstores(r, p, body)
+	}
+	ls, ok := p.logstores[segments[1]]
+	if !ok {
+		return nil, newError(http.StatusNotFound, sls.LOGSTORE_NOT_EXIST, "logstore %s does not exist", segments[1])
+	}
+	switch {
+	case len(segments) == 2:
+		return s.handleLogstore(r, p, segments[1], ls, body)
+	case segments[2] == "index" && len(segments) == 3:
+		return s.handleIndex(r, ls, body)
+	case segments[2] == "shards" && len(segments) == 3 && r.Method == http.MethodGet:
+		shards := make([]*sls.Shard, 0, len(ls.shards))
+		for _, sh := range ls.shards {
+			meta := sh.meta
+			shards = append(shards, &meta)
+		}
+		return jsonResponse(shards), nil
+	case segments[2] == "shards" && len(segments) == 4:
+		return s.handleShard(r, ls, segments[3], body)
+	case segments[2] == "consumergroups" && len(segments) == 3:
+		return s.handleConsumerGroups(r, ls, body)
+	case segments[2] == "consumergroups" && len(segments) == 4:
+		return s.handleConsumerGroup(r, ls, segments[3], body)
+	}
+	return nil, newError(http.StatusNotImplemented, sls.NOT_SUPPORTED, "%s %s is not supported by slstest", r.Method, r.URL.Path)
+}
+
+func (s *Server) handleProject(r *http.Request, projectName string, body []byte) (*response, error) {
+	switch r.Method {
+	case http.MethodPost:
+		meta := sls.LogProject{}
+		if err := json.Unmarshal(body, &meta); err != nil {
+			return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "%v", err)
+		}
+		if _, ok := s.projects[meta.Name]; ok {
+			return nil, newError(http.StatusBadRequest, "ProjectAlreadyExist", "project %s already exist", meta.Name)
+		}
+		now := strconv.FormatInt(time.Now().Unix(), 10)
+		s.projects[meta.Name] = &project{
+			meta: sls.LogProject{
+				Name:               meta.Name,
+				Description:        meta.Description,
+				Status:             "Normal",
+				CreateTime:         now,
+				LastModifyTime:     now,
+				DataRedundancyType: meta.DataRedundancyType,
+			},
+			logstores: map[string]*logstore{},
+		}
+		return jsonResponse(nil), nil
+	case http.MethodGet:
+		if projectName == "" {
+			return s.listProjects(r)
+		}
+		p, ok := s.projects[projectName]
+		if !ok {
+			return nil, newError(http.StatusNotFound, sls.PROJECT_NOT_EXIST, "project %s does not exist", projectName)
+		}
+		return jsonResponse(p.meta), nil
+	case http.MethodPut:
+		p, ok := s.projects[projectName]
+		if !ok {
+			return nil, newError(http.StatusNotFound, sls.PROJECT_NOT_EXIST, "project %s does not exist", projectName)
+		}
+		meta := sls.LogProject{}
+		if err := json.Unmarshal(body, &meta); err != nil {
+			return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "%v", err)
+		}
+		p.meta.Description = meta.Description
+		p.meta.LastModifyTime = strconv.FormatInt(time.Now().Unix(), 10)
+		return jsonResponse(nil), nil
+	case http.MethodDelete:
+		if _, ok := s.projects[projectName]; !ok {
+			return nil, newError(http.StatusNotFound, sls.PROJECT_NOT_EXIST, "project %s does not exist", projectName)
+		}
+		delete(s.projects, projectName)
+		return jsonResponse(nil), nil
+	}
+	return nil, newError(http.StatusMethodNotAllowed, sls.NOT_SUPPORTED, "method %s is not supported", r.Method)
+}
+
+func (s *Server) listProjects(r *http.Request) (*response, error) {
+	names := make([]string, 0, len(s.projects))
+	for name := range s.projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	offset, size := pagination(r, len(names))
+	projects := make([]sls.LogProject, 0, size)
+	for _, name := range names[offset : offset+size] {
+		projects = append(projects, s.projects[name].meta)
+	}
+	return jsonResponse(map[string]interface{}{
+		"projects": projects,
+		"count":    len(projects),
+		"total":    len(names),
+	}), nil
+}
+
+// pagination returns the offset and size of a page of total items
+func pagination(r *http.Request, total int) (offset, size int) {
+	offset, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil || size <= 0 {
+		size = 500
+	}
+	if offset < 0 || offset > total {
+		offset = total
+	}
+	if offset+size > total {
+		size = total - offset
+	}
+	return offset, size
+}
+
+func (s *Server) handleLogstores(r *http.Request, p *project, body []byte) (*response, error) {
+	switch r.Method {
+	case http.MethodGet:
+		names := make([]string, 0, len(p.logstores))
+		for name := range p.logstores {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		offset, size := pagination(r, len(names))
+		return jsonResponse(map[string]interface{}{
+			"logstores": names[offset : offset+size],
+			"count":     size,
+			"total":     len(names),
+		}), nil
+	case http.MethodPost:
+		meta := sls.LogStore{}
+		if err := json.Unmarshal(body, &meta); err != nil {
+			return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "%v", err)
+		}
+		if meta.Name == "" {
+			return nil, newError(http.StatusBadRequest, sls.LOGSTORE_INFO_INVALID, "logstore name is empty")
+		}
+		if _, ok := p.logstores[meta.Name]; ok {
+			return nil, newError(http.StatusBadRequest, sls.LOGSTORE_ALREADY_EXIST, "logstore %s already exist", meta.Name)
+		}
+		if meta.ShardCount <= 0 {
+			meta.ShardCount = 2
+		}
+		now := uint32(time.Now().Unix())
+		meta.CreateTime, meta.LastModifyTime = now, now
+		metaJSON, _ := json.Marshal(meta)
+		p.logstores[meta.Name] = &logstore{
+			meta:           metaJSON,
+			shards:         newShards(meta.ShardCount, int(now)),
+			consumerGroups: map[string]*consumerGroup{},
+		}
+		return jsonResponse(nil), nil
+	}
+	return nil, newError(http.StatusMethodNotAllowed, sls.NOT_SUPPORTED, "method %s is not supported", r.Method)
+}
+
+// newShards splits the md5 hash key space evenly into count shards
+func newShards(count, createTime int) []*shard {
+	space := new(big.Int).Lsh(big.NewInt(1), 128)
+	keyOf := func(i int) string {
+		if i == count {
+			return strings.Repeat("f", 32)
+		}
+		key := new(big.Int).Mul(space, big.NewInt(int64(i)))
+		return fmt.Sprintf("%032x", key.Div(key, big.NewInt(int64(count))))
+	}
+	shards := make([]*shard, 0, count)
+	for i := 0; i < count; i++ {
+		shards = append(shards, &shard{meta: sls.Shard{
+			ShardID:           i,
+			Status:            "readwrite",
+			InclusiveBeginKey: keyOf(i),
+			ExclusiveBeginKey: keyOf(i + 1),
+			CreateTime:        createTime,
+		}})
+	}
+	return shards
+}
+
+func (s *Server) handleLogstore(r *http.Request, p *project, name string, ls *logstore, body []byte) (*response, error) {
+	switch r.Method {
+	case http.MethodGet:
+		return &response{body: []byte(ls.meta)}, nil
+	case http.MethodPut:
+		meta := map[string]interface{}{}
+		json.Unmarshal(ls.meta, &meta)
+		if err := json.Unmarshal(body, &meta); err != nil {
+			return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "%v", err)
+		}
+		meta["logstoreName"] = name
+		meta["lastModifyTime"] = time.Now().Unix()
+		ls.meta, _ = json.Marshal(meta)
+		return jsonResponse(nil), nil
+	case http.MethodDelete:
+		delete(p.logstores, name)
+		return jsonResponse(nil), nil
+	case http.MethodPost:
+		return s.postLogs(r, ls, nil, body)
+	}
+	return nil, newError(http.StatusMethodNotAllowed, sls.NOT_SUPPORTED, "method %s is not supported", r.Method)
+}
+
+func (s *Server) handleIndex(r *http.Request, ls *logstore, body []byte) (*response, error) {
+	switch r.Method {
+	case http.MethodGet:
+		if ls.index == nil {
+			return nil, newError(http.StatusNotFound, "IndexConfigNotExist", "index config does not exist")
+		}
+		return &response{body: []byte(ls.index)}, nil
+	case http.MethodPost:
+		if ls.index != nil {
+			return nil, newError(http.StatusBadRequest, "IndexAlreadyExist", "index already exist")
+		}
+		fallthrough
+	case http.MethodPut:
+		if !json.Valid(body) {
+			return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "invalid index json")
+		}
+		ls.index = append(json.RawMessage(nil), body...)
+		return jsonResponse(nil), nil
+	case http.MethodDelete:
+		if ls.index == nil {
+			return nil, newError(http.StatusNotFound, "IndexConfigNotExist", "index config does not exist")
+		}
+		ls.index = nil
+		return jsonResponse(nil), nil
+	}
+	return nil, newError(http.StatusMethodNotAllowed, sls.NOT_SUPPORTED, "method %s is not supported", r.Method)
+}
+
+func (s *Server) handleShard(r *http.Request, ls *logstore, shardPath string, body []byte) (*response, error) {
+	if r.Method == http.MethodPost {
+		switch shardPath {
+		case "lb":
+			return s.postLogs(r, ls, nil, body)
+		case "route":
+			key := r.URL.Query().Get("key")
+			return s.postLogs(r, ls, &key, body)
+		}
+		return nil, newError(http.StatusNotImplemented, sls.NOT_SUPPORTED, "split and merge shards are not supported by slstest")
+	}
+	shardID, err := strconv.Atoi(shardPath)
+	if err != nil || shardID < 0 || shardID >= len(ls.shards) {
+		return nil, newError(http.StatusNotFound, sls.SHARD_NOT_EXIST, "shard %s does not exist", shardPath)
+	}
+	sh := ls.shards[shardID]
+	query := r.URL.Query()
+	switch query.Get("type") {
+	case "cursor":
+		return s.getCursor(sh, query.Get("from"))
+	case "cursor_time":
+		offset, err := parseCursor(sh, query.Get("cursor"))
+		if err != nil {
+			return nil, err
+		}
+		cursorTime := time.Now()
+		if offset < len(sh.logGroups) {
+			cursorTime = sh.logGroups[offset].receiveTime
+		}
+		return jsonResponse(map[string]int64{"cursor_time": cursorTime.Unix()}), nil
+	case "logs":
+		return s.pullLogs(r, sh)
+	}
+	return nil, newError(http.StatusBadRequest, sls.PARAMETER_INVALID, "invalid type %s", query.Get("type"))
+}
+
+// cursors are base64 encoded offsets of log groups in the shard, like the real ones
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func parseCursor(sh *shard, cursor string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, newError(http.StatusBadRequest, sls.INVALID_CURSOR, "invalid cursor %s", cursor)
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 || offset > len(sh.logGroups) {
+		return 0, newError(http.StatusBadRequest, sls.INVALID_CURSOR, "invalid cursor %s", cursor)
+	}
+	return offset, nil
+}
+
+func (s *Server) getCursor(sh *shard, from string) (*response, error) {
+	var offset int
+	switch from {
+	case "begin":
+		offset = 0
+	case "end":
+		offset = len(sh.logGroups)
+	default:
+		ts, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return nil, newError(http.StatusBadRequest, sls.PARAMETER_INVALID, "invalid from %s", from)
+		}
+		offset = sort.Search(len(sh.logGroups), func(i int) bool {
+			return sh.logGroups[i].receiveTime.Unix() >= ts
+		})
+	}
+	return jsonResponse(map[string]string{"cursor": encodeCursor(offset)}), nil
+}
+
+func (s *Server) pullLogs(r *http.Request, sh *shard) (*response, error) {
+	query := r.URL.Query()
+	if query.Get("query") != "" {
+		return nil, newError(http.StatusNotImplemented, sls.NOT_SUPPORTED, "pulling logs with query is not supported by slstest")
+	}
+	begin, err := parseCursor(sh, query.Get("cursor"))
+	if err != nil {
+		return nil, err
+	}
+	end := len(sh.logGroups)
+	if endCursor := query.Get("end_cursor"); endCursor != "" {
+		if end, err = parseCursor(sh, endCursor); err != nil {
+			return nil, err
+		}
+	}
+	count, err := strconv.Atoi(query.Get("count"))
+	if err != nil || count <= 0 {
+		return nil, newError(http.StatusBadRequest, sls.PARAMETER_INVALID, "invalid count %s", query.Get("count"))
+	}
+	if begin+count < end {
+		end = begin + count
+	}
+	if end < begin {
+		end = begin
+	}
+
+	// LogGroupList is a repeated LogGroup field, concat encoded log groups to build it
+	var raw []byte
+	for _, stored := range sh.logGroups[begin:end] {
+		raw = append(append(raw, 0x0a), proto.EncodeVarint(uint64(len(stored.data)))...)
+		raw = append(raw, stored.data...)
+	}
+	header := http.Header{}
+	header.Set("X-Log-Cursor", encodeCursor(end))
+	header.Set("X-Log-Count", strconv.Itoa(end-begin))
+	header.Set("X-Log-Bodyrawsize", strconv.Itoa(len(raw)))
+	if len(raw) == 0 {
+		return &response{header: header, body: []byte{}}, nil
+	}
+
+	if r.Header.Get("Accept-Encoding") != "zstd" {
+		out := make([]byte, lz4.CompressBlockBound(len(raw)))
+		var hashTable [1 << 16]int
+		if n, err := lz4.CompressBlock(raw, out, hashTable[:]); err == nil && n > 0 {
+			header.Set("X-Log-Compresstype", "lz4")
+			return &response{header: header, body: out[:n]}, nil
+		}
+		// incompressible, fallback to zstd which the sdk always understands
+	}
+	out, err := s.zstd.Compress(raw, nil)
+	if err != nil {
+		return nil, err
+	}
+	header.Set("X-Log-Compresstype", "zstd")
+	return &response{header: header, body: out}, nil
+}
+
+func (s *Server) postLogs(r *http.Request, ls *logstore, hashKey *string, body []byte) (*response, error) {
+	rawSize, err := strconv.Atoi(r.Header.Get("x-log-bodyrawsize"))
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, sls.INVALID_BODY_RAW_SIZE, "invalid x-log-bodyrawsize")
+	}
+	raw := body
+	switch r.Header.Get("x-log-compresstype") {
+	case "":
+	case "lz4":
+		raw = make([]byte, rawSize)
+		n, err := lz4.UncompressBlock(body, raw)
+		if err != nil || n != rawSize {
+			return nil, newError(http.StatusBadRequest, sls.POST_BODY_UNCOMPRESS_ERROR, "failed to uncompress lz4 body")
+		}
+	case "zstd":
+		raw, err = s.zstd.Decompress(body, make([]byte, 0, rawSize))
+		if err != nil || len(raw) != rawSize {
+			return nil, newError(http.StatusBadRequest, sls.POST_BODY_UNCOMPRESS_ERROR, "failed to uncompress zstd body")
+		}
+	default:
+		return nil, newError(http.StatusBadRequest, sls.INVALID_COMPRESS_TYPE, "unsupported compress type %s", r.Header.Get("x-log-compresstype"))
+	}
+	logGroup := &sls.LogGroup{}
+	if err := proto.Unmarshal(raw, logGroup); err != nil {
+		return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "invalid log group: %v", err)
+	}
+
+	var sh *shard
+	if hashKey != nil && *hashKey != "" {
+		if sh = ls.routeShard(*hashKey); sh == nil {
+			return nil, newError(http.StatusBadRequest, sls.INVALID_KEY, "invalid hash key %s", *hashKey)
+		}
+	} else {
+		sh = ls.shards[ls.nextShard%len(ls.shards)]
+		ls.nextShard++
+	}
+	sh.logGroups = append(sh.logGroups, &storedLogGroup{
+		receiveTime: time.Now(),
+		data:        append([]byte(nil), raw...),
+	})
+	return jsonResponse(nil), nil
+}
+
+// routeShard returns the shard whose key range contains hashKey
+func (ls *logstore) routeShard(hashKey string) *shard {
+	key := strings.ToLower(hashKey)
+	if len(key) > 32 {
+		return nil
+	}
+	key += strings.Repeat("0", 32-len(key))
+	for _, sh := range ls.shards {
+		if key >= sh.meta.InclusiveBeginKey && key < sh.meta.ExclusiveBeginKey {
+			return sh
+		}
+	}
+	// the exclusive end key of the last shard is ffff...ffff
+	return ls.shards[len(ls.shards)-1]
+}
+
+func (s *Server) handleConsumerGroups(r *http.Request, ls *logstore, body []byte) (*response, error) {
+	switch r.Method {
+	case http.MethodGet:
+		type item struct {
+			Name    string `json:"name"`
+			Timeout int    `json:"timeout"`
+			Order   bool   `json:"order"`
+		}
+		names := make([]string, 0, len(ls.consumerGroups))
+		for name := range ls.consumerGroups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		items := make([]item, 0, len(names))
+		for _, name := range names {
+			cg := ls.consumerGroups[name].meta
+			items = append(items, item{Name: cg.ConsumerGroupName, Timeout: cg.Timeout, Order: cg.InOrder})
+		}
+		return jsonResponse(items), nil
+	case http.MethodPost:
+		cg := sls.ConsumerGroup{}
+		if err := json.Unmarshal(body, &cg); err != nil {
+			return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "%v", err)
+		}
+		if _, ok := ls.consumerGroups[cg.ConsumerGroupName]; ok {
+			return nil, newError(http.StatusBadRequest, "ConsumerGroupAlreadyExist", "consumer group %s already exist", cg.ConsumerGroupName)
+		}
+		ls.consumerGroups[cg.ConsumerGroupName] = &consumerGroup{
+			meta:        cg,
+			checkpoints: map[int]*sls.ConsumerGroupCheckPoint{},
+			heartbeats:  map[string]time.Time{},
+		}
+		return jsonResponse(nil), nil
+	}
+	return nil, newError(http.StatusMethodNotAllowed, sls.NOT_SUPPORTED, "method %s is not supported", r.Method)
+}
+
+func (s *Server) handleConsumerGroup(r *http.Request, ls *logstore, name string, body []byte) (*response, error) {
+	cg, ok := ls.consumerGroups[name]
+	if !ok {
+		return nil, newError(http.StatusNotFound, "ConsumerGroupNotExist", "consumer group %s does not exist", name)
+	}
+	switch r.Method {
+	case http.MethodPut:
+		updates := struct {
+			Timeout *int  `json:"timeout"`
+			InOrder *bool `json:"order"`
+		}{}
+		if err := json.Unmarshal(body, &updates); err != nil {
+			return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "%v", err)
+		}
+		if updates.Timeout != nil {
+			cg.meta.Timeout = *updates.Timeout
+		}
+		if updates.InOrder != nil {
+			cg.meta.InOrder = *updates.InOrder
+		}
+		return jsonResponse(nil), nil
+	case http.MethodDelete:
+		delete(ls.consumerGroups, name)
+		return jsonResponse(nil), nil
+	case http.MethodGet:
+		checkpoints := make([]*sls.ConsumerGroupCheckPoint, 0, len(ls.shards))
+		for _, sh := range ls.shards {
+			if checkpoint, ok := cg.checkpoints[sh.meta.ShardID]; ok {
+				checkpoints = append(checkpoints, checkpoint)
+			} else {
+				checkpoints = append(checkpoints, &sls.ConsumerGroupCheckPoint{ShardID: sh.meta.ShardID})
+			}
+		}
+		return jsonResponse(checkpoints), nil
+	case http.MethodPost:
+		consumer := r.URL.Query().Get("consumer")
+		switch r.URL.Query().Get("type") {
+		case "heartbeat":
+			return jsonResponse(ls.heartbeat(cg, consumer)), nil
+		case "checkpoint":
+			checkpoint := sls.ConsumerGroupCheckPoint{}
+			if err := json.Unmarshal(body, &checkpoint); err != nil {
+				return nil, newError(http.StatusBadRequest, sls.POST_BODY_INVALID, "%v", err)
+			}
+			if checkpoint.ShardID < 0 || checkpoint.ShardID >= len(ls.shards) {
+				return nil, newError(http.StatusNotFound, sls.SHARD_NOT_EXIST, "shard %d does not exist", checkpoint.ShardID)
+			}
+			checkpoint.Consumer = consumer
+			checkpoint.UpdateTime = time.Now().UnixNano() / 1000
+			cg.checkpoints[checkpoint.ShardID] = &checkpoint
+			return jsonResponse(nil), nil
+		}
+	}
+	return nil, newError(http.StatusBadRequest, sls.PARAMETER_INVALID, "invalid consumer group request")
+}
+
+// heartbeat records the heartbeat of consumer, and returns shards assigned to it.
+// Shards are assigned to alive consumers sorted by name in round robin.
+func (ls *logstore) heartbeat(cg *consumerGroup, consumer string) []int {
+	now := time.Now()
+	cg.heartbeats[consumer] = now
+	timeout := time.Duration(cg.meta.Timeout) * time.Second
+	alive := make([]string, 0, len(cg.heartbeats))
+	for name, last := range cg.heartbeats {
+		if timeout > 0 && now.Sub(last) > timeout {
+			delete(cg.heartbeats, name)
+			continue
+		}
+		alive = append(alive, name)
+	}
+	sort.Strings(alive)
+	shards := []int{}
+	for i, sh := range ls.shards {
+		if alive[i%len(alive)] == consumer {
+			shards = append(shards, sh.meta.ShardID)
+		}
+	}
+	return shards
+}
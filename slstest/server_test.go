@@ -0,0 +1,203 @@
+package slstest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	sls "github.com/aliyun/aliyun-log-go-sdk"
+	consumerLibrary "github.com/aliyun/aliyun-log-go-sdk/consumer"
+	"github.com/aliyun/aliyun-log-go-sdk/producer"
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLogGroup(n int) *sls.LogGroup {
+	logGroup := &sls.LogGroup{Topic: proto.String("topic"), Source: proto.String("127.0.0.1")}
+	for i := 0; i < n; i++ {
+		logGroup.Logs = append(logGroup.Logs, &sls.Log{
+			Time: proto.Uint32(uint32(time.Now().Unix())),
+			Contents: []*sls.LogContent{
+				{Key: proto.String("index"), Value: proto.String(fmt.Sprint(i))},
+			},
+		})
+	}
+	return logGroup
+}
+
+func TestProjectAndLogstore(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+
+	_, err := client.CreateProject("my-project", "desc")
+	require.NoError(t, err)
+	exist, err := client.CheckProjectExist("my-project")
+	require.NoError(t, err)
+	assert.True(t, exist)
+	exist, err = client.CheckProjectExist("other-project")
+	require.NoError(t, err)
+	assert.False(t, exist)
+
+	project, err := client.GetProject("my-project")
+	require.NoError(t, err)
+	assert.Equal(t, "desc", project.Description)
+	projects, err := client.ListProject()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"my-project"}, projects)
+
+	require.NoError(t, client.CreateLogStore("my-project", "my-logstore", 30, 4, false, 0))
+	err = client.CreateLogStore("my-project", "my-logstore", 30, 4, false, 0)
+	assert.Equal(t, sls.LOGSTORE_ALREADY_EXIST, err.(*sls.Error).Code)
+	logstores, err := client.ListLogStore("my-project")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"my-logstore"}, logstores)
+	logstore, err := client.GetLogStore("my-project", "my-logstore")
+	require.NoError(t, err)
+	assert.Equal(t, 4, logstore.ShardCount)
+
+	shards, err := client.ListShards("my-project", "my-logstore")
+	require.NoError(t, err)
+	require.Len(t, shards, 4)
+	assert.Equal(t, "00000000000000000000000000000000", shards[0].InclusiveBeginKey)
+	assert.Equal(t, "40000000000000000000000000000000", shards[0].ExclusiveBeginKey)
+	assert.Equal(t, "ffffffffffffffffffffffffffffffff", shards[3].ExclusiveBeginKey)
+
+	index := sls.Index{Line: &sls.IndexLine{Token: []string{" "}}}
+	require.NoError(t, client.CreateIndex("my-project", "my-logstore", index))
+	got, err := client.GetIndex("my-project", "my-logstore")
+	require.NoError(t, err)
+	assert.Equal(t, []string{" "}, got.Line.Token)
+
+	require.NoError(t, client.DeleteLogStore("my-project", "my-logstore"))
+	_, err = client.GetLogStore("my-project", "my-logstore")
+	assert.Equal(t, sls.LOGSTORE_NOT_EXIST, err.(*sls.Error).Code)
+	require.NoError(t, client.DeleteProject("my-project"))
+	_, err = client.GetProject("my-project")
+	assert.Equal(t, sls.PROJECT_NOT_EXIST, err.(*sls.Error).Code)
+}
+
+func TestPostAndPullLogs(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	_, err := client.CreateProject("my-project", "")
+	require.NoError(t, err)
+	require.NoError(t, client.CreateLogStore("my-project", "my-logstore", 30, 2, false, 0))
+
+	for _, compressType := range []int{sls.Compress_LZ4, sls.Compress_ZSTD, sls.Compress_None} {
+		require.NoError(t, client.PostLogStoreLogsV2("my-project", "my-logstore", &sls.PostLogStoreLogsRequest{
+			LogGroup:     newLogGroup(10),
+			HashKey:      proto.String("8f"),
+			CompressType: compressType,
+		}))
+	}
+	assert.Len(t, server.LogGroups("my-project", "my-logstore", 0), 0)
+	assert.Len(t, server.LogGroups("my-project", "my-logstore", 1), 3)
+
+	begin, err := client.GetCursor("my-project", "my-logstore", 1, "begin")
+	require.NoError(t, err)
+	end, err := client.GetCursor("my-project", "my-logstore", 1, "end")
+	require.NoError(t, err)
+	logGroupList, nextCursor, err := client.PullLogsV2(&sls.PullLogRequest{
+		Project:          "my-project",
+		Logstore:         "my-logstore",
+		ShardID:          1,
+		Cursor:           begin,
+		LogGroupMaxCount: 2,
+	})
+	require.NoError(t, err)
+	require.Len(t, logGroupList.LogGroups, 2)
+	assert.Len(t, logGroupList.LogGroups[1].Logs, 10)
+	assert.Equal(t, "topic", logGroupList.LogGroups[0].GetTopic())
+
+	logGroupList, nextCursor, err = client.PullLogsV2(&sls.PullLogRequest{
+		Project:          "my-project",
+		Logstore:         "my-logstore",
+		ShardID:          1,
+		Cursor:           nextCursor,
+		LogGroupMaxCount: 2,
+	})
+	require.NoError(t, err)
+	assert.Len(t, logGroupList.LogGroups, 1)
+	assert.Equal(t, end, nextCursor)
+
+	_, err = client.GetCursor("my-project", "my-logstore", 5, "begin")
+	assert.Equal(t, sls.SHARD_NOT_EXIST, err.(*sls.Error).Code)
+}
+
+func TestProducerAndConsumer(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	_, err := client.CreateProject("my-project", "")
+	require.NoError(t, err)
+	require.NoError(t, client.CreateLogStore("my-project", "my-logstore", 30, 2, false, 0))
+
+	producerConfig := producer.GetDefaultProducerConfig()
+	producerConfig.Endpoint = server.Endpoint
+	producerConfig.HTTPClient = server.HTTPClient()
+	producerConfig.CredentialsProvider = sls.NewStaticCredentialsProvider("slstest", "slstest", "")
+	producerConfig.Logger = log.NewNopLogger()
+	producerConfig.LingerMs = 100
+	p, err := producer.NewProducer(producerConfig)
+	require.NoError(t, err)
+	p.Start()
+	for i := 0; i < 100; i++ {
+		log := producer.GenerateLog(uint32(time.Now().Unix()), map[string]string{"index": fmt.Sprint(i)})
+		require.NoError(t, p.SendLog("my-project", "my-logstore", "topic", "127.0.0.1", log))
+	}
+	p.SafeClose()
+
+	var lock sync.Mutex
+	received := map[string]bool{}
+	worker := consumerLibrary.InitConsumerWorkerWithCheckpointTracker(consumerLibrary.LogHubConfig{
+		Endpoint:                  server.Endpoint,
+		HTTPClient:                server.HTTPClient(),
+		CredentialsProvider:       sls.NewStaticCredentialsProvider("slstest", "slstest", ""),
+		Project:                   "my-project",
+		Logstore:                  "my-logstore",
+		ConsumerGroupName:         "my-group",
+		ConsumerName:              "my-consumer",
+		CursorPosition:            consumerLibrary.BEGIN_CURSOR,
+		HeartbeatIntervalInSecond: 1,
+		DataFetchIntervalInMs:     50,
+		Logger:                    log.NewNopLogger(),
+	}, func(shardID int, logGroupList *sls.LogGroupList, tracker consumerLibrary.CheckPointTracker) (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, logGroup := range logGroupList.LogGroups {
+			for _, log := range logGroup.Logs {
+				received[log.Contents[0].GetValue()] = true
+			}
+		}
+		return "", tracker.SaveCheckPoint(true)
+	})
+	worker.Start()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		lock.Lock()
+		n := len(received)
+		lock.Unlock()
+		if n == 100 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	worker.StopAndWait()
+	assert.Len(t, received, 100)
+
+	checkpoints, err := client.GetCheckpoint("my-project", "my-logstore", "my-group")
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 2)
+	for _, checkpoint := range checkpoints {
+		if len(server.LogGroups("my-project", "my-logstore", checkpoint.ShardID)) == 0 {
+			continue
+		}
+		assert.NotEmpty(t, checkpoint.CheckPoint)
+		assert.Equal(t, "my-consumer", checkpoint.Consumer)
+	}
+}
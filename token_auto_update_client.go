@@ -1,9 +1,11 @@
 package sls
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
@@ -11,6 +13,9 @@ import (
 
 type TokenAutoUpdateClient struct {
 	logClient              ClientInterface
+	credentials            *tokenCredentialsProvider // shared with the clients returned by WithContext
+	ctx                    context.Context           // nil if not bound by WithContext
+	root                   *TokenAutoUpdateClient    // the client fetching tokens for the clients returned by WithContext
 	shutdown               <-chan struct{}
 	closeFlag              bool
 	tokenUpdateFunc        UpdateTokenFunction
@@ -28,6 +33,30 @@ type TokenAutoUpdateClient struct {
 
 var errSTSFetchHighFrequency = errors.New("sts token fetch frequency is too high")
 
+// tokenCredentialsProvider holds the token fetched by TokenAutoUpdateClient, so that
+// the clients bound to a context by WithContext use the token fetched after they are created.
+type tokenCredentialsProvider struct {
+	cred atomic.Value // type Credentials
+}
+
+func newTokenCredentialsProvider(accessKeyID, accessKeySecret, securityToken string) *tokenCredentialsProvider {
+	provider := &tokenCredentialsProvider{}
+	provider.set(accessKeyID, accessKeySecret, securityToken)
+	return provider
+}
+
+func (p *tokenCredentialsProvider) set(accessKeyID, accessKeySecret, securityToken string) {
+	p.cred.Store(Credentials{
+		AccessKeyID:     accessKeyID,
+		AccessKeySecret: accessKeySecret,
+		SecurityToken:   securityToken,
+	})
+}
+
+func (p *tokenCredentialsProvider) GetCredentials() (Credentials, error) {
+	return p.cred.Load().(Credentials), nil
+}
+
 func (c *TokenAutoUpdateClient) flushSTSToken() {
 	for {
 		nowTime := time.Now()
@@ -107,7 +136,7 @@ func (c *TokenAutoUpdateClient) fetchSTSToken() error {
 		c.lastRetryInterval = time.Duration(0)
 		c.nextExpire = expireTime
 		c.lock.Unlock()
-		c.logClient.ResetAccessKeyToken(accessKeyID, accessKeySecret, securityToken)
+		c.ResetAccessKeyToken(accessKeyID, accessKeySecret, securityToken)
 		if IsDebugLevelMatched(1) {
 			level.Info(Logger).Log("msg", "fetch sts token success id : ", accessKeyID)
 		}
@@ -126,7 +155,11 @@ func (c *TokenAutoUpdateClient) processError(err error) (retry bool) {
 		return false
 	}
 	if IsTokenError(err) {
-		if fetchErr := c.fetchSTSToken(); fetchErr != nil {
+		fetcher := c
+		if c.root != nil {
+			fetcher = c.root
+		}
+		if fetchErr := fetcher.fetchSTSToken(); fetchErr != nil {
 			level.Warn(Logger).Log("msg", "operation error : ", err.Error(), "fetch sts token error : ", fetchErr.Error())
 			// if fetch error, return false
 			return false
@@ -141,6 +174,11 @@ func (c *TokenAutoUpdateClient) SetUserAgent(userAgent string) {
 	c.logClient.SetUserAgent(userAgent)
 }
 
+// Use appends interceptors wrapping every request sent by the client
+func (c *TokenAutoUpdateClient) Use(interceptors ...Interceptor) {
+	UseInterceptors(c.logClient, interceptors...)
+}
+
 // SetHTTPClient set a custom http client, all request will send to sls by this client
 func (c *TokenAutoUpdateClient) SetHTTPClient(client *http.Client) {
 	c.logClient.SetHTTPClient(client)
@@ -151,6 +189,13 @@ func (c *TokenAutoUpdateClient) SetRetryTimeout(timeout time.Duration) {
 	c.logClient.SetRetryTimeout(timeout)
 }
 
+// SetRetryPolicy set the policy deciding which failed requests are retried and when
+func (c *TokenAutoUpdateClient) SetRetryPolicy(policy RetryPolicy) {
+	if client, ok := c.logClient.(ClientInterfaceWithRetryPolicy); ok {
+		client.SetRetryPolicy(policy)
+	}
+}
+
 // SetAuthVersion set auth version that the client used
 func (c *TokenAutoUpdateClient) SetAuthVersion(version AuthVersionType) {
 	c.logClient.SetAuthVersion(version)
@@ -167,9 +212,45 @@ func (c *TokenAutoUpdateClient) Close() error {
 }
 
 func (c *TokenAutoUpdateClient) ResetAccessKeyToken(accessKeyID, accessKeySecret, securityToken string) {
+	if c.credentials != nil {
+		c.credentials.set(accessKeyID, accessKeySecret, securityToken)
+		return
+	}
 	c.logClient.ResetAccessKeyToken(accessKeyID, accessKeySecret, securityToken)
 }
 
+// WithContext returns a client whose API calls are bound to ctx, the token is still
+// fetched by the current client, and shared by the returned one.
+func (c *TokenAutoUpdateClient) WithContext(ctx context.Context) ClientInterfaceWithContext {
+	if ctx == nil {
+		panic("nil context")
+	}
+	root := c
+	if c.root != nil {
+		root = c.root
+	}
+	return &TokenAutoUpdateClient{
+		logClient:              BindContext(root.logClient, ctx),
+		credentials:            root.credentials,
+		ctx:                    ctx,
+		root:                   root,
+		shutdown:               root.shutdown,
+		tokenUpdateFunc:        root.tokenUpdateFunc,
+		maxTryTimes:            root.maxTryTimes,
+		waitIntervalMin:        root.waitIntervalMin,
+		waitIntervalMax:        root.waitIntervalMax,
+		updateTokenIntervalMin: root.updateTokenIntervalMin,
+	}
+}
+
+// Context returns the context bound by WithContext, or context.Background().
+func (c *TokenAutoUpdateClient) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
 func (c *TokenAutoUpdateClient) CreateProject(name, description string) (prj *LogProject, err error) {
 	for i := 0; i < c.maxTryTimes; i++ {
 		prj, err = c.logClient.CreateProject(name, description)
@@ -1,11 +1,18 @@
 package sls
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -112,3 +119,44 @@ func (s *TolenAutoUpdateClientTestSuite) TestAutoUpdateSTSToken() {
 	s.Equal(s.tokenUpdateCount, 2)
 
 }
+
+func TestTokenAutoUpdateClientWithContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "new-id") {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errorCode":"Unauthorized","errorMessage":"token expired"}`))
+			return
+		}
+		w.Write([]byte(`[{"shardID":0,"status":"readwrite"}]`))
+	}))
+	defer ts.Close()
+
+	fetched := 0
+	updateToken := func() (accessKeyID, accessKeySecret, securityToken string, expireTime time.Time, err error) {
+		fetched++
+		if fetched == 1 {
+			return "old-id", "secret", "", time.Now().Add(time.Hour), nil
+		}
+		return "new-id", "secret", "", time.Now().Add(time.Hour), nil
+	}
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+	client, err := CreateTokenAutoUpdateClient(ts.URL, updateToken, shutdown)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bound, ok := BindContext(client, ctx).(ClientInterfaceWithContext)
+	require.True(t, ok)
+	assert.Equal(t, ctx, bound.Context())
+	// the token fetched on the bound client is used by both clients
+	_, err = bound.ListShards("", "my-store")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fetched)
+	_, err = client.ListShards("", "my-store")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fetched)
+
+	cancel()
+	_, err = bound.ListShards("", "my-store")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,123 @@
+package sls
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Tracer starts spans. It is the subset of OpenTelemetry trace.Tracer the sdk
+// needs, so the sdk does not depend on OpenTelemetry directly, an adapter
+// takes a few lines:
+//
+//	type otelTracer struct{ tracer trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string, attrs ...sls.Attribute) (context.Context, sls.Span) {
+//		ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		s := otelSpan{span}
+//		s.SetAttributes(attrs...)
+//		return ctx, s
+//	}
+type Tracer interface {
+	// Start creates a span and a context containing it.
+	Start(ctx context.Context, spanName string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a single operation within a trace.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key-value pair describing a span,
+// Value is one of string, int, int64, float64 or bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span attribute keys used by the sdk.
+const (
+	AttrProject   = "sls.project"
+	AttrLogstore  = "sls.logstore"
+	AttrAPI       = "sls.api"
+	AttrRequestID = "sls.request_id"
+	AttrErrorCode = "sls.error_code"
+	AttrShard     = "sls.shard"
+	AttrAttempt   = "sls.attempt"
+	AttrLogCount  = "sls.log_count"
+	AttrBatchSize = "sls.batch_size"
+	AttrHTTPCode  = "http.status_code"
+)
+
+// NewTracingInterceptor returns an interceptor creating a span for every http
+// request sent to SLS, retries of an API call are traced as separate spans.
+// The span is named after the API, eg. "GET /logstores/{}/shards".
+func NewTracingInterceptor(tracer Tracer) Interceptor {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *RoundTripRequest) (*http.Response, error) {
+			api := apiName(req.Method, req.URI)
+			attrs := []Attribute{{AttrProject, req.Project}, {AttrAPI, api}}
+			if logstore := logstoreOfURI(req.URI); logstore != "" {
+				attrs = append(attrs, Attribute{AttrLogstore, logstore})
+			}
+			ctx, span := tracer.Start(req.Context, "sls "+api, attrs...)
+			defer span.End()
+
+			req.Context = ctx
+			resp, err := next(req)
+			if err != nil {
+				RecordSpanError(span, err)
+				return resp, err
+			}
+			span.SetAttributes(
+				Attribute{AttrHTTPCode, resp.StatusCode},
+				Attribute{AttrRequestID, resp.Header.Get(RequestIDHeader)},
+			)
+			return resp, nil
+		}
+	}
+}
+
+// RecordSpanError records err on span, with the http code,
+// error code and request id if err is an *Error.
+func RecordSpanError(span Span, err error) {
+	switch e := err.(type) {
+	case *Error:
+		span.SetAttributes(
+			Attribute{AttrHTTPCode, int(e.HTTPCode)},
+			Attribute{AttrErrorCode, e.Code},
+			Attribute{AttrRequestID, e.RequestID},
+		)
+	case *BadResponseError:
+		span.SetAttributes(Attribute{AttrHTTPCode, e.HTTPCode})
+	}
+	span.RecordError(err)
+}
+
+// apiName returns method and the uri path with resource names replaced by "{}",
+// uri paths of SLS are in the form of /collection/{name}/collection/{name}.
+func apiName(method, uri string) string {
+	path := uri
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 1; i < len(segments); i += 2 {
+		segments[i] = "{}"
+	}
+	return method + " /" + strings.Join(segments, "/")
+}
+
+func logstoreOfURI(uri string) string {
+	segments := strings.Split(strings.TrimPrefix(uri, "/"), "/")
+	if len(segments) < 2 || segments[0] != "logstores" {
+		return ""
+	}
+	name := segments[1]
+	if i := strings.IndexByte(name, '?'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,76 @@
+package sls
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSpan struct {
+	name  string
+	attrs map[string]interface{}
+	errs  []error
+	ended bool
+}
+
+func (s *testSpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *testSpan) RecordError(err error) { s.errs = append(s.errs, err) }
+func (s *testSpan) End()                  { s.ended = true }
+
+type testTracer struct {
+	lock  sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: map[string]interface{}{}}
+	span.SetAttributes(attrs...)
+	t.lock.Lock()
+	t.spans = append(t.spans, span)
+	t.lock.Unlock()
+	return ctx, span
+}
+
+func TestAPIName(t *testing.T) {
+	assert.Equal(t, "GET /", apiName("GET", "/"))
+	assert.Equal(t, "GET /logstores", apiName("GET", "/logstores?offset=0&size=100"))
+	assert.Equal(t, "POST /logstores/{}/shards/{}", apiName("POST", "/logstores/my-store/shards/lb"))
+	assert.Equal(t, "PUT /logstores/{}/index", apiName("PUT", "/logstores/my-store/index"))
+	assert.Equal(t, "my-store", logstoreOfURI("/logstores/my-store/shards/lb"))
+	assert.Equal(t, "my-store", logstoreOfURI("/logstores/my-store?type=log"))
+	assert.Equal(t, "", logstoreOfURI("/dashboards/my-dashboard"))
+}
+
+func TestTracingInterceptor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(RequestIDHeader, "request-id")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errorCode":"Unauthorized","errorMessage":"denied"}`))
+	}))
+	defer ts.Close()
+
+	tracer := &testTracer{}
+	client := CreateNormalInterfaceV2(ts.URL, NewStaticCredentialsProvider("id", "key", ""))
+	client.(ClientInterfaceWithInterceptors).Use(NewTracingInterceptor(tracer))
+
+	_, err := client.ListShards("", "my-store")
+	assert.Error(t, err)
+	assert.Len(t, tracer.spans, 1)
+	span := tracer.spans[0]
+	assert.Equal(t, "sls GET /logstores/{}/shards", span.name)
+	assert.True(t, span.ended)
+	assert.Equal(t, "my-store", span.attrs[AttrLogstore])
+	assert.Equal(t, 403, span.attrs[AttrHTTPCode])
+	assert.Equal(t, "Unauthorized", span.attrs[AttrErrorCode])
+	assert.Equal(t, "request-id", span.attrs[AttrRequestID])
+	assert.Len(t, span.errs, 1)
+}